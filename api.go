@@ -0,0 +1,132 @@
+package chord
+
+import (
+	"context"
+
+	"github.com/cdesiniotis/chord/chordpb"
+)
+
+/*
+ * The methods below let applications embedding a node use the DHT in-process,
+ * without dialing their own gRPC port. Requests for keys stored elsewhere are
+ * forwarded under ctx, so its deadline and cancellation bound the whole request.
+ */
+
+/*
+ * Function:	Get
+ *
+ * Description:
+ *		Read a key. Versions written concurrently are all returned as siblings,
+ * 		see chordpb.Value. Returns ErrKeyNotFound if the key is not stored.
+ */
+func (n *Node) Get(ctx context.Context, key string, consistency chordpb.Consistency) (*chordpb.Value, error) {
+	val, err := n.get(ctx, key, consistency)
+	return val, apiError(ctx, err)
+}
+
+/*
+ * Function:	Put
+ *
+ * Description:
+ *		Write a key, replacing every version of it. Returns once as many
+ * 		replicas as the consistency level requires stored it.
+ */
+func (n *Node) Put(ctx context.Context, key string, value []byte, consistency chordpb.Consistency) error {
+	return apiError(ctx, n.put(ctx, key, value, consistency))
+}
+
+/*
+ * Function:	Delete
+ *
+ * Description:
+ *		Delete a key. Returns ErrKeyNotFound if the key is not stored.
+ */
+func (n *Node) Delete(ctx context.Context, key string) error {
+	return apiError(ctx, n.delete(ctx, key))
+}
+
+/*
+ * Function:	Locate
+ *
+ * Description:
+ *		Return the node responsible for a key.
+ */
+func (n *Node) Locate(ctx context.Context, key string) (*chordpb.Node, error) {
+	node, err := n.locate(ctx, key)
+	return node, apiError(ctx, err)
+}
+
+/*
+ * Function:	Trace
+ *
+ * Description:
+ *		Locate the node responsible for a key iteratively, returning every
+ * 		node asked on the way with its latency. If the lookup fails, the path
+ *		has no successor and its last hops carry the errors.
+ */
+func (n *Node) Trace(ctx context.Context, key string) (*chordpb.Path, error) {
+	path, err := n.trace(ctx, key)
+	return path, apiError(ctx, err)
+}
+
+/*
+ * Function:	Members
+ *
+ * Description:
+ *		Return every member of the ring this process learned of through gossip,
+ * 		including the ones which failed or left, sorted by id.
+ */
+func (n *Node) Members() []*chordpb.Member {
+	return n.host.membership.view()
+}
+
+/*
+ * Function:	Leave
+ *
+ * Description:
+ *		Gracefully leave the Chord ring and shutdown. The keys of every virtual
+ *		node of this process are handed to their successors first, so no data
+ * 		is lost. If no successor takes the keys, the node keeps running and an
+ *		error is returned.
+ */
+func (n *Node) Leave(ctx context.Context) error {
+	return apiError(ctx, n.host.leave(ctx))
+}
+
+/*
+ * Function:	Close
+ *
+ * Description:
+ *		Shutdown the process serving this node, along with all of its virtual
+ * 		nodes, without handing keys over: the ring recovers them from replicas.
+ *		Safe to call more than once.
+ */
+func (n *Node) Close() error {
+	n.shutdown()
+	return nil
+}
+
+/*
+ * Function:	apiError
+ *
+ * Description:
+ *		Translate errors returned by the nodes a request was forwarded to into
+ * 		the errors of this package, and errors caused by ctx into ctx.Err().
+ */
+func apiError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	switch ErrorReason(err) {
+	case ReasonKeyNotFound:
+		return ErrKeyNotFound
+	case ReasonNoRoute:
+		return ErrNoRoute
+	case ReasonStaleContext:
+		return errStaleContext
+	}
+	return err
+}
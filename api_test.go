@@ -0,0 +1,73 @@
+package chord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNodeAPI(t *testing.T) {
+	ctx := context.Background()
+
+	// keys land on every node of the ring, so requests are served both
+	// locally and by forwarding them
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("api-%d", i)
+		assert.Nil(t, n1.Put(ctx, key, []byte("val"), ConsistencyOne), "Put() should not result in error")
+		val, err := n2.Get(ctx, key, ConsistencyOne)
+		assert.Nil(t, err, "Get() should not result in error")
+		assert.Equal(t, "val", string(val.GetValue()))
+
+		node, err := n3.Locate(ctx, key)
+		assert.Nil(t, err, "Locate() should not result in error")
+		owner, err := n1.Locate(ctx, key)
+		assert.Nil(t, err, "Locate() should not result in error")
+		assert.Equal(t, owner.Id, node.Id, "every node should locate %s on the same node", key)
+
+		assert.Nil(t, n3.Delete(ctx, key), "Delete() should not result in error")
+		_, err = n1.Get(ctx, key, ConsistencyOne)
+		assert.Equal(t, ErrKeyNotFound, err, "Get() of a deleted key should return ErrKeyNotFound")
+		assert.Equal(t, ErrKeyNotFound, n2.Delete(ctx, key), "Delete() of a deleted key should return ErrKeyNotFound")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("api-%d", i)
+		owner, _ := n1.Locate(ctx, key)
+		if owner.Port == n1.Port {
+			continue
+		}
+		err := n1.Put(canceled, key, []byte("val"), ConsistencyOne)
+		assert.Equal(t, context.Canceled, err, "a request forwarded under a canceled context should return context.Canceled")
+	}
+}
+
+// Errors are told apart by the reason in their status, whatever their message
+func TestErrorReason(t *testing.T) {
+	ctx := context.Background()
+	for err, reason := range map[error]string{
+		ErrKeyNotFound:    ReasonKeyNotFound,
+		ErrNoRoute:        ReasonNoRoute,
+		errStaleContext:   ReasonStaleContext,
+		errNotResponsible: ReasonNotResponsible,
+	} {
+		s := statusError(err)
+		assert.Equal(t, reason, ErrorReason(s), "the status of %v should carry its reason", err)
+		assert.Equal(t, reason, ErrorReason(fmt.Errorf("forwarded: %w", s)), "the reason of a wrapped status should be found")
+		if reason != ReasonNotResponsible {
+			assert.Equal(t, err, apiError(ctx, s), "apiError() should translate a status back to %v", err)
+		}
+	}
+
+	// a status of the same code and message, but without a reason, is not a missing key
+	s := status.Error(codes.NotFound, ErrKeyNotFound.Error())
+	assert.Equal(t, "", ErrorReason(s))
+	assert.Equal(t, s, apiError(ctx, s))
+	assert.Equal(t, "", ErrorReason(errors.New("not a status")))
+}
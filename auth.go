@@ -0,0 +1,188 @@
+package chord
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC metadata keys identifying the caller of an RPC
+const (
+	ringSecretMetadataKey = "chord-ring-secret"
+	tokenMetadataKey      = "authorization"
+)
+
+// Scopes of the API tokens given to clients
+const (
+	ScopeRead      = "read"      // Get, Locate, Trace, GetRoutingTable and GetMembers
+	ScopeReadWrite = "readwrite" // every read RPC, Put and Delete
+)
+
+// APIToken lets a client call the client-facing RPCs within its scope
+type APIToken struct {
+	Token string
+	Scope string // ScopeRead or ScopeReadWrite
+}
+
+// scope required by each client-facing RPC, every other RPC is internal to the ring
+var clientMethods = map[string]string{
+	"Get":             ScopeRead,
+	"Locate":          ScopeRead,
+	"Trace":           ScopeRead,
+	"GetRoutingTable": ScopeRead,
+	"GetMembers":      ScopeRead,
+	"Put":             ScopeReadWrite,
+	"Delete":          ScopeReadWrite,
+}
+
+/* Function: 	WithRingSecret
+ *
+ * Description:
+ * 		Identify outgoing RPCs as coming from a member of the ring.
+ */
+func WithRingSecret(ctx context.Context, secret string) context.Context {
+	if secret == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, ringSecretMetadataKey, secret)
+}
+
+/* Function: 	WithToken
+ *
+ * Description:
+ * 		Authorize outgoing client RPCs with an API token.
+ */
+func WithToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, tokenMetadataKey, "Bearer "+token)
+}
+
+// authorizer decides which callers may invoke which RPCs
+type authorizer struct {
+	ringSecret string
+	peerNames  map[string]bool
+	tokens     map[string]string
+}
+
+/* Function: 	newAuthorizer
+ *
+ * Description:
+ * 		Create the authorizer of the config's credentials. API tokens without a
+ *		ring secret nor peer names are refused: internal RPCs would stay open to
+ * 		any caller, while requests forwarded between nodes would be rejected.
+ *		So are tokens of an unknown scope, rather than being treated as read-only.
+ */
+func newAuthorizer(config *Config, logger log.FieldLogger) (*authorizer, error) {
+	a := &authorizer{
+		ringSecret: config.RingSecret,
+		peerNames:  make(map[string]bool, len(config.PeerNames)),
+		tokens:     make(map[string]string, len(config.APITokens)),
+	}
+	for _, name := range config.PeerNames {
+		a.peerNames[name] = true
+	}
+	for i, t := range config.APITokens {
+		if t.Scope != ScopeRead && t.Scope != ScopeReadWrite {
+			return nil, fmt.Errorf("API token %d has unknown scope %q, it should be %q or %q", i+1, t.Scope, ScopeRead, ScopeReadWrite)
+		}
+		a.tokens[t.Token] = t.Scope
+	}
+	if a.ringSecret == "" && len(a.peerNames) == 0 {
+		if len(a.tokens) > 0 {
+			return nil, errors.New("API tokens require a ring secret or peer names to authenticate the members of the ring")
+		}
+		logger.Warnf("Neither a ring secret nor peer names are configured: every RPC is open to any caller\n")
+	}
+	return a, nil
+}
+
+/* Function: 	isPeer
+ *
+ * Description:
+ * 		Return true if the caller is a member of the ring: it either knows the
+ *		ring secret, or presented a verified certificate for one of the peer names.
+ */
+func (a *authorizer) isPeer(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if secrets := md.Get(ringSecretMetadataKey); a.ringSecret != "" && len(secrets) > 0 {
+		if subtle.ConstantTimeCompare([]byte(secrets[0]), []byte(a.ringSecret)) == 1 {
+			return true
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && len(a.peerNames) > 0 {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			if a.peerNames[info.State.VerifiedChains[0][0].Subject.CommonName] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// return the scope of the caller's API token
+func (a *authorizer) tokenScope(ctx context.Context) (string, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := md.Get(tokenMetadataKey)
+	if len(auth) == 0 || !strings.HasPrefix(auth[0], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimPrefix(auth[0], "Bearer ")
+	for t, scope := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return scope, true
+		}
+	}
+	return "", false
+}
+
+/* Function: 	authorize
+ *
+ * Description:
+ * 		Check the caller may invoke a method. Internal RPCs are reserved to members
+ *		of the ring, unless no ring secret nor peer names are configured. Client RPCs
+ * 		are open to members of the ring, since nodes forward them to the leader of a
+ *		key, and to API tokens with the required scope, unless no tokens are configured.
+ */
+func (a *authorizer) authorize(ctx context.Context, method string) error {
+	required, isClient := clientMethods[method]
+	open := a.ringSecret == "" && len(a.peerNames) == 0
+	if isClient && len(a.tokens) == 0 || !isClient && open {
+		return nil
+	}
+	if a.isPeer(ctx) {
+		return nil
+	}
+	if !isClient {
+		return status.Errorf(codes.PermissionDenied, "%s is reserved to members of the ring", method)
+	}
+
+	scope, ok := a.tokenScope(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "%s requires an API token", method)
+	}
+	if required == ScopeReadWrite && scope != ScopeReadWrite {
+		return status.Errorf(codes.PermissionDenied, "%s requires a %s token", method, ScopeReadWrite)
+	}
+	return nil
+}
+
+func (a *authorizer) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	if err := a.authorize(ctx, method); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
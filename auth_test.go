@@ -0,0 +1,107 @@
+package chord
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthorization(t *testing.T) {
+	authConfig := func(port int) *Config {
+		cfg := DefaultConfig("127.0.0.1", port)
+		cfg.RingSecret = "ring-secret"
+		cfg.APITokens = []APIToken{
+			{Token: "read-token", Scope: ScopeRead},
+			{Token: "write-token", Scope: ScopeReadWrite},
+		}
+		return cfg
+	}
+
+	a, err := CreateChord(authConfig(8061))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer a.shutdown()
+	b, err := JoinChord(authConfig(8062), "127.0.0.1", 8061)
+	assert.Nil(t, err, "JoinChord() with the ring secret should not result in error")
+	if err != nil {
+		return
+	}
+	defer b.shutdown()
+
+	err = b.put(context.Background(), "auth-key", []byte("val"), ConsistencyOne)
+	assert.Nil(t, err, "put(k,v) between members of the ring should not result in error")
+
+	conn, err := grpc.Dial("127.0.0.1:8061", grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := chordpb.NewChordClient(conn)
+
+	call := func(ctx context.Context, rpc func(context.Context) error) codes.Code {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		return status.Code(rpc(ctx))
+	}
+	get := func(ctx context.Context) error {
+		_, err := client.Get(ctx, &chordpb.Key{Key: "auth-key"})
+		return err
+	}
+	put := func(ctx context.Context) error {
+		_, err := client.Put(ctx, &chordpb.KV{Key: "auth-key", Value: []byte("new")})
+		return err
+	}
+	successors := func(ctx context.Context) error {
+		_, err := client.GetSuccessorList(ctx, &chordpb.Empty{})
+		return err
+	}
+	bg := context.Background()
+
+	assert.Equal(t, codes.Unauthenticated, call(bg, get), "client RPCs should require a token")
+	assert.Equal(t, codes.Unauthenticated, call(WithToken(bg, "bogus"), get), "unknown tokens should be rejected")
+	assert.Equal(t, codes.OK, call(WithToken(bg, "read-token"), get), "a read token should allow Get")
+	assert.Equal(t, codes.PermissionDenied, call(WithToken(bg, "read-token"), put), "a read token should not allow Put")
+	assert.Equal(t, codes.OK, call(WithToken(bg, "write-token"), put), "a readwrite token should allow Put")
+
+	assert.Equal(t, codes.PermissionDenied, call(WithToken(bg, "write-token"), successors), "tokens should not allow internal RPCs")
+	assert.Equal(t, codes.PermissionDenied, call(WithRingSecret(bg, "wrong"), successors), "a wrong ring secret should be rejected")
+	assert.Equal(t, codes.OK, call(WithRingSecret(bg, "ring-secret"), successors), "the ring secret should allow internal RPCs")
+}
+
+func TestAuthorizationDisabled(t *testing.T) {
+	auth, err := newAuthorizer(DefaultConfig("127.0.0.1", 0), newLogger(false))
+	assert.Nil(t, err, "newAuthorizer() should not result in error")
+	if err != nil {
+		return
+	}
+	for _, method := range []string{"Get", "Put", "Leave", "FindSuccessor"} {
+		assert.Nil(t, auth.authorize(context.Background(), method), "every RPC should be open when no credentials are configured")
+	}
+
+	// tokens alone would leave internal RPCs open to any caller
+	cfg := DefaultConfig("127.0.0.1", 0)
+	cfg.APITokens = []APIToken{{Token: "t", Scope: ScopeRead}}
+	_, err = newAuthorizer(cfg, newLogger(false))
+	assert.NotNil(t, err, "API tokens without a ring secret nor peer names should be refused")
+	cfg.KeySize = 32
+	cfg.Transport = NewMemoryNetwork().Transport()
+	_, err = CreateChord(cfg)
+	assert.NotNil(t, err, "a node with API tokens but no ring secret nor peer names should not start")
+
+	// a misspelled scope would only show as failed writes
+	cfg = DefaultConfig("127.0.0.1", 0)
+	cfg.RingSecret = "secret"
+	for _, scope := range []string{"readWrite", "rw", ""} {
+		cfg.APITokens = []APIToken{{Token: "t1", Scope: ScopeRead}, {Token: "t2", Scope: scope}}
+		_, err = newAuthorizer(cfg, newLogger(false))
+		if assert.NotNilf(t, err, "a token of scope %q should be refused", scope) {
+			assert.Contains(t, err.Error(), "API token 2", "the error should name the bad token")
+		}
+	}
+}
@@ -2,10 +2,12 @@ package chord
 
 import (
 	"bytes"
+	"context"
 	"github.com/cdesiniotis/chord/chordpb"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"sort"
 	"testing"
 	"time"
 )
@@ -190,7 +192,7 @@ func TestLocate(t *testing.T) {
 	//t.Logf("key - %s\t hash - %d", key2, hash2)
 	//t.Logf("key - %s\t hash - %d", key3, hash3)
 
-	node, err = n1.locate(key1)
+	node, err = n1.locate(context.Background(), key1)
 	assert.Nil(t, err, "locate(k) should not result in error")
 	if node != nil {
 		res = bytes.Compare(node.Id, []byte{19})
@@ -198,7 +200,7 @@ func TestLocate(t *testing.T) {
 	}
 	//t.Logf("key - %s\t hash - %d\t locate - %d", key1, hash1, node.Id)
 
-	node, err = n1.locate(key2)
+	node, err = n1.locate(context.Background(), key2)
 	assert.Nil(t, err, "locate(k) should not result in error")
 	if node != nil {
 		res = bytes.Compare(node.Id, []byte{19})
@@ -206,7 +208,7 @@ func TestLocate(t *testing.T) {
 	}
 	//t.Logf("key - %s\t hash - %d\t locate - %d", key2, hash2, node.Id)
 
-	node, err = n1.locate(key3)
+	node, err = n1.locate(context.Background(), key3)
 	assert.Nil(t, err, "locate(k) should not result in error")
 	if node != nil {
 		res = bytes.Compare(node.Id, []byte{69})
@@ -224,17 +226,17 @@ func TestPut(t *testing.T) {
 	key2 := "key2"
 	key3 := "key3"
 
-	err = n1.put(key1, []byte("val1"))
+	err = n1.put(context.Background(), key1, []byte("val1"), ConsistencyOne)
 	assert.Nil(t, err, "put(k,v) should not result in error")
-	err = n1.put(key2, []byte("val2"))
+	err = n1.put(context.Background(), key2, []byte("val2"), ConsistencyOne)
 	assert.Nil(t, err, "put(k,v) should not result in error")
-	err = n1.put(key3, []byte("val3"))
+	err = n1.put(context.Background(), key3, []byte("val3"), ConsistencyOne)
 	assert.Nil(t, err, "put(k,v) should not result in error")
 }
 
 func TestGet(t *testing.T) {
 	var res int
-	var val []byte
+	var val *chordpb.Value
 	var err error
 
 	key1 := "key1"
@@ -245,33 +247,115 @@ func TestGet(t *testing.T) {
 	val2 := []byte("val2")
 	val3 := []byte("val3")
 
-	val, err = n1.get(key1)
+	val, err = n1.get(context.Background(), key1, ConsistencyOne)
 	assert.Nil(t, err, "get(k) should not result in error")
-	res = bytes.Compare(val, val1)
+	res = bytes.Compare(val.GetValue(), val1)
 	assert.Equalf(t, 0, res, "n1.get(%s) should return %s\n", key1, string(val1))
 
-	val, err = n1.get(key2)
+	val, err = n1.get(context.Background(), key2, ConsistencyOne)
 	assert.Nil(t, err, "get(k) should not result in error")
-	res = bytes.Compare(val, val2)
+	res = bytes.Compare(val.GetValue(), val2)
 	assert.Equalf(t, 0, res, "n1.get(%s) should return %s\n", key2, string(val2))
 
-	val, err = n1.get(key3)
+	val, err = n1.get(context.Background(), key3, ConsistencyOne)
 	assert.Nil(t, err, "get(k) should not result in error")
-	res = bytes.Compare(val, val3)
+	res = bytes.Compare(val.GetValue(), val3)
 	assert.Equalf(t, 0, res, "n1.get(%s) should return %s\n", key3, string(val3))
 
-	val, err = n1.get("key4")
+	val, err = n1.get(context.Background(), "key4", ConsistencyOne)
 	assert.NotNil(t, err, "get(k) should result in error for key not present in datastore")
 }
 
+func TestDelete(t *testing.T) {
+	var err error
+
+	key3 := "key3"
+
+	err = n1.delete(context.Background(), key3)
+	assert.Nil(t, err, "delete(k) should not result in error")
+
+	_, err = n1.get(context.Background(), key3, ConsistencyOne)
+	assert.NotNil(t, err, "get(k) should result in error for a deleted key")
+
+	// key3 is stored at n2 [69], whose replica group is n3 [19] and n1 [118]
+	leaderId := idKey([]byte{69})
+	for _, n := range []*Node{n1, n3} {
+		n.rgsMtx.RLock()
+		rg, ok := n.rgs[leaderId]
+		if ok {
+			_, ok = rg.data.Get(key3)
+		}
+		n.rgsMtx.RUnlock()
+		assert.Falsef(t, ok, "%d should not hold a replica of a deleted key", n.Id)
+	}
+
+	// re-shipping the leader's data must not bring the key back
+	n2.sendAllReplicas()
+	for _, n := range []*Node{n1, n3} {
+		n.rgsMtx.RLock()
+		rg, ok := n.rgs[leaderId]
+		if ok {
+			_, ok = rg.data.Get(key3)
+		}
+		n.rgsMtx.RUnlock()
+		assert.Falsef(t, ok, "%d should not hold a replica of a deleted key", n.Id)
+	}
+
+	err = n1.delete(context.Background(), key3)
+	assert.NotNil(t, err, "delete(k) should result in error for key not present in datastore")
+
+	// a deleted key can be stored again
+	err = n1.put(context.Background(), key3, []byte("val3"), ConsistencyOne)
+	assert.Nil(t, err, "put(k,v) should not result in error")
+}
+
+// ringSettled returns true once the predecessors and successor lists of the
+// nodes follow the ring, and every node joined the replica groups it is in
+func ringSettled(nodes []*Node) bool {
+	if !ringConverged(nodes) {
+		return false
+	}
+	sorted := append([]*Node(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Id, sorted[j].Id) < 0 })
+	for i, n := range sorted {
+		n.predMtx.RLock()
+		pred := n.predecessor
+		n.predMtx.RUnlock()
+		if pred == nil || !bytes.Equal(pred.Id, sorted[(i+len(sorted)-1)%len(sorted)].Id) {
+			return false
+		}
+		n.succListMtx.RLock()
+		succList := n.successorList
+		n.succListMtx.RUnlock()
+		for j, s := range succList {
+			if s == nil || !bytes.Equal(s.Id, sorted[(i+j+1)%len(sorted)].Id) {
+				return false
+			}
+		}
+		n.rgsMtx.RLock()
+		groups := len(n.rgs)
+		n.rgsMtx.RUnlock()
+		if groups != len(succList)+1 {
+			return false
+		}
+	}
+	return true
+}
+
 func TestMain(m *testing.M) {
 	var err error
 	// Create a few sample nodes
 	// Node 1 with ID: [118]
 	cfg := DefaultConfig("0.0.0.0", 8001)
-	n1 = CreateChord(cfg)
+	cfg.KeySize = 8
+	n1, err = CreateChord(cfg)
+	if err != nil {
+		log.Errorf("Exiting in TestMain()\n")
+		os.Exit(1)
+	}
 	// Node 2 with ID: [69]
 	cfg = DefaultConfig("0.0.0.0", 8002)
+	cfg.KeySize = 8
 	n2, err = JoinChord(cfg, "0.0.0.0", 8001)
 	if err != nil {
 		log.Errorf("Exiting in TestMain()\n")
@@ -281,6 +365,7 @@ func TestMain(m *testing.M) {
 	}
 	// Node 3 with ID: [19]
 	cfg = DefaultConfig("0.0.0.0", 8003)
+	cfg.KeySize = 8
 	n3, err = JoinChord(cfg, "0.0.0.0", 8001)
 	if err != nil {
 		log.Errorf("Exiting in TestMain()\n")
@@ -290,8 +375,12 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
-	// Sleep for a few seconds so that nodes stabilize and converge
-	time.Sleep(5 * time.Second)
+	// Wait for the nodes to stabilize and converge
+	nodes := []*Node{n1, n2, n3}
+	deadline := time.Now().Add(60 * time.Second)
+	for !ringSettled(nodes) && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
 
 	// Run tests
 	exitStatus := m.Run()
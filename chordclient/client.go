@@ -0,0 +1,280 @@
+// Package chordclient is a Go client for a chord ring. A Client sends each
+// request to one of a list of seed nodes, which routes it to the node
+// responsible for the key. With smart routing the Client learns the ring
+// instead, and sends requests straight to the responsible node.
+package chordclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cdesiniotis/chord"
+	"github.com/cdesiniotis/chord/chordpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Config of a Client
+type Config struct {
+	Seeds   []string                         // ip:port of nodes of the ring
+	Creds   credentials.TransportCredentials // nil to dial without TLS
+	Token   string                           // API token, if the ring requires one
+	Timeout time.Duration                    // deadline of each attempt, unless the context sets an earlier one
+
+	// Learn the members of the ring and send requests straight to the node
+	// responsible for a key, see route
+	SmartRouting bool
+}
+
+/* Function: 	DefaultConfig
+ *
+ * Description:
+ * 		Return a config for a client of the ring reachable at seeds.
+ */
+func DefaultConfig(seeds ...string) *Config {
+	return &Config{
+		Seeds:        seeds,
+		Timeout:      5 * time.Second,
+		SmartRouting: true,
+	}
+}
+
+// Client of a chord ring, safe for concurrent use
+type Client struct {
+	config Config
+
+	conns    map[string]*grpc.ClientConn
+	connsMtx sync.Mutex
+	next     int  // index of the seed to try first: the last one which answered
+	closed   bool // guarded by connsMtx
+
+	ring       *ring     // nil until learnt, or once found to be stale
+	learnt     time.Time // when the ring was last learnt in full, guarded by ringMtx
+	ringMtx    sync.Mutex
+	refreshMtx sync.Mutex // held while learning or repairing the ring
+}
+
+/* Function: 	New
+ *
+ * Description:
+ * 		Create a client. Connections to the seeds are opened on first use
+ *		and kept until Close.
+ */
+func New(config *Config) (*Client, error) {
+	if len(config.Seeds) == 0 {
+		return nil, ErrNoSeeds
+	}
+	c := &Client{
+		config: *config,
+		conns:  make(map[string]*grpc.ClientConn),
+	}
+	if c.config.Timeout <= 0 {
+		c.config.Timeout = 5 * time.Second
+	}
+	return c, nil
+}
+
+/* Function: 	Get
+ *
+ * Description:
+ * 		Read a key. The value holds every concurrent version of the key, see
+ *		chordpb.Value. Returns ErrKeyNotFound if the key is not stored.
+ */
+func (c *Client) Get(ctx context.Context, key string, consistency chordpb.Consistency) (*chordpb.Value, error) {
+	var val *chordpb.Value
+	err := c.route(ctx, "Get", key, func(ctx context.Context, cc chordpb.ChordClient) error {
+		var err error
+		val, err = cc.Get(ctx, &chordpb.Key{Key: key, Consistency: consistency})
+		return err
+	})
+	return val, err
+}
+
+/* Function: 	Put
+ *
+ * Description:
+ * 		Write a key, replacing every version of it.
+ */
+func (c *Client) Put(ctx context.Context, key string, value []byte, consistency chordpb.Consistency) error {
+	return c.route(ctx, "Put", key, func(ctx context.Context, cc chordpb.ChordClient) error {
+		_, err := cc.Put(ctx, &chordpb.KV{Key: key, Value: value, Consistency: consistency})
+		return err
+	})
+}
+
+/* Function: 	Delete
+ *
+ * Description:
+ * 		Delete a key. Returns ErrKeyNotFound if the key is not stored.
+ */
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.route(ctx, "Delete", key, func(ctx context.Context, cc chordpb.ChordClient) error {
+		_, err := cc.Delete(ctx, &chordpb.Key{Key: key})
+		return err
+	})
+}
+
+/* Function: 	Locate
+ *
+ * Description:
+ * 		Return the node responsible for a key. With smart routing the answer
+ *		comes from our view of the ring, which may be stale.
+ */
+func (c *Client) Locate(ctx context.Context, key string) (*chordpb.Node, error) {
+	if c.config.SmartRouting {
+		if r := c.view(ctx); r != nil {
+			return r.lookup(key), nil
+		}
+	}
+
+	var node *chordpb.Node
+	err := c.do(ctx, "Locate", func(ctx context.Context, cc chordpb.ChordClient) error {
+		var err error
+		node, err = cc.Locate(ctx, &chordpb.Key{Key: key})
+		return err
+	})
+	return node, err
+}
+
+/* Function: 	Trace
+ *
+ * Description:
+ * 		Have a seed locate the node responsible for a key iteratively, and
+ *		return every node it asked on the way with its latency.
+ */
+func (c *Client) Trace(ctx context.Context, key string) (*chordpb.Path, error) {
+	var path *chordpb.Path
+	err := c.do(ctx, "Trace", func(ctx context.Context, cc chordpb.ChordClient) error {
+		var err error
+		path, err = cc.Trace(ctx, &chordpb.Key{Key: key})
+		return err
+	})
+	return path, err
+}
+
+/* Function: 	Members
+ *
+ * Description:
+ * 		Return the members of the ring a seed learned of through gossip,
+ *		along with their state.
+ */
+func (c *Client) Members(ctx context.Context) ([]*chordpb.Member, error) {
+	var members *chordpb.Members
+	err := c.do(ctx, "Members", func(ctx context.Context, cc chordpb.ChordClient) error {
+		var err error
+		members, err = cc.GetMembers(ctx, &chordpb.Empty{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members.Members, nil
+}
+
+/* Function: 	Close
+ *
+ * Description:
+ * 		Close the connections to the seeds. The client can not be used afterwards.
+ */
+func (c *Client) Close() error {
+	c.connsMtx.Lock()
+	defer c.connsMtx.Unlock()
+	c.closed = true
+	var err error
+	for addr, conn := range c.conns {
+		if cerr := conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(c.conns, addr)
+	}
+	return err
+}
+
+/* Function: 	do
+ *
+ * Description:
+ * 		Send a request to the seeds in turn, starting from the last one that
+ *		answered, until one serves it. Only failures which may not happen on
+ * 		another seed, such as a missing key, end the loop early.
+ */
+func (c *Client) do(ctx context.Context, op string, rpc func(context.Context, chordpb.ChordClient) error) error {
+	c.connsMtx.Lock()
+	start := c.next
+	c.connsMtx.Unlock()
+
+	var lastErr error
+	for i := 0; i < len(c.config.Seeds); i++ {
+		idx := (start + i) % len(c.config.Seeds)
+		addr := c.config.Seeds[idx]
+
+		conn, err := c.conn(ctx, addr)
+		if err == ErrClosed {
+			return err
+		}
+		if err == nil {
+			attemptCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+			err = rpc(chord.WithToken(attemptCtx, c.config.Token), chordpb.NewChordClient(conn))
+			cancel()
+			if err == nil {
+				c.connsMtx.Lock()
+				c.next = idx
+				c.connsMtx.Unlock()
+				return nil
+			}
+			if !retryable(err) {
+				return &Error{Op: op, Addr: addr, Kind: errorKind(err), Err: err}
+			}
+		}
+		lastErr = &Error{Op: op, Addr: addr, Kind: ErrUnavailable, Err: err}
+
+		// the caller gave up, there is no point trying other seeds
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return lastErr
+}
+
+/* Function: 	conn
+ *
+ * Description:
+ * 		Return the pooled connection to a seed, dialing it if needed.
+ */
+func (c *Client) conn(ctx context.Context, addr string) (*grpc.ClientConn, error) {
+	c.connsMtx.Lock()
+	if c.closed {
+		c.connsMtx.Unlock()
+		return nil, ErrClosed
+	}
+	conn, ok := c.conns[addr]
+	c.connsMtx.Unlock()
+	if ok {
+		return conn, nil
+	}
+
+	creds := grpc.WithInsecure()
+	if c.config.Creds != nil {
+		creds = grpc.WithTransportCredentials(c.config.Creds)
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, addr, creds, grpc.WithBlock(), grpc.FailOnNonTempDialError(true))
+	if err != nil {
+		return nil, err
+	}
+
+	c.connsMtx.Lock()
+	defer c.connsMtx.Unlock()
+	if c.closed {
+		conn.Close()
+		return nil, ErrClosed
+	}
+	// another request may have dialed the seed meanwhile
+	if other, ok := c.conns[addr]; ok {
+		conn.Close()
+		return other, nil
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
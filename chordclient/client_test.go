@@ -0,0 +1,68 @@
+package chordclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord"
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	_, err := chord.CreateChord(chord.DefaultConfig("127.0.0.1", 8071))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	time.Sleep(time.Second)
+
+	_, err = New(DefaultConfig())
+	assert.Equal(t, ErrNoSeeds, err)
+
+	// the first seed is down, requests should fall over to the second
+	cfg := DefaultConfig("127.0.0.1:8079", "127.0.0.1:8071")
+	cfg.Timeout = time.Second
+	c, err := New(cfg)
+	assert.Nil(t, err)
+	defer c.Close()
+	ctx := context.Background()
+
+	err = c.Put(ctx, "client-key", []byte("val"), chordpb.Consistency_ONE)
+	assert.Nil(t, err, "Put() should not result in error")
+	val, err := c.Get(ctx, "client-key", chordpb.Consistency_ONE)
+	assert.Nil(t, err, "Get() should not result in error")
+	assert.Equal(t, "val", string(val.GetValue()))
+
+	node, err := c.Locate(ctx, "client-key")
+	assert.Nil(t, err, "Locate() should not result in error")
+	assert.Equal(t, uint32(8071), node.GetPort())
+
+	members, err := c.Members(ctx)
+	assert.Nil(t, err, "Members() should not result in error")
+	if assert.Equal(t, 1, len(members), "the only member should be listed") {
+		assert.Equal(t, chordpb.MemberState_ALIVE, members[0].GetState())
+	}
+
+	path, err := c.Trace(ctx, "client-key")
+	assert.Nil(t, err, "Trace() should not result in error")
+	if err == nil {
+		assert.Equal(t, 1, len(path.Hops), "a node responsible for the key should be the only hop")
+		assert.Equal(t, uint32(8071), path.Successor.GetPort())
+	}
+
+	assert.Nil(t, c.Delete(ctx, "client-key"), "Delete() should not result in error")
+	_, err = c.Get(ctx, "client-key", chordpb.Consistency_ONE)
+	assert.True(t, errors.Is(err, ErrKeyNotFound), "Get() of a deleted key should return ErrKeyNotFound, got %v", err)
+	err = c.Delete(ctx, "client-key")
+	assert.True(t, errors.Is(err, ErrKeyNotFound), "Delete() of a deleted key should return ErrKeyNotFound, got %v", err)
+
+	// no seed is up
+	down, err := New(&Config{Seeds: []string{"127.0.0.1:8078", "127.0.0.1:8079"}, Timeout: time.Second})
+	assert.Nil(t, err)
+	_, err = down.Locate(ctx, "client-key")
+	assert.True(t, errors.Is(err, ErrUnavailable), "requests should fail with ErrUnavailable when every seed is down, got %v", err)
+
+	down.Close()
+	_, err = down.Locate(ctx, "client-key")
+	assert.Equal(t, ErrClosed, err)
+}
@@ -0,0 +1,71 @@
+package chordclient
+
+import (
+	"errors"
+
+	"github.com/cdesiniotis/chord"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Errors returned by the Client. They can be matched with errors.Is; the
+// underlying gRPC status is kept in the chain.
+var (
+	ErrNoSeeds          = errors.New("chordclient: no seed addresses")
+	ErrClosed           = errors.New("chordclient: client is closed")
+	ErrUnavailable      = errors.New("chordclient: no seed could serve the request")
+	ErrKeyNotFound      = errors.New("chordclient: key not found")
+	ErrStaleContext     = errors.New("chordclient: key has newer versions than the given context")
+	ErrUnauthenticated  = errors.New("chordclient: missing or unknown API token")
+	ErrPermissionDenied = errors.New("chordclient: API token does not allow this request")
+)
+
+// Error describes a failed request to a node of the ring
+type Error struct {
+	Op   string // Get, Put, Delete, Locate, Trace or Members
+	Addr string // seed the request was sent to
+	Kind error  // one of the errors above, nil if the failure is not classified
+	Err  error  // error returned by gRPC
+}
+
+func (e *Error) Error() string {
+	return e.Op + " " + e.Addr + ": " + status.Convert(e.Err).Message()
+}
+
+// Is reports whether the error is of kind target
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// classify an error returned by a node
+func errorKind(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		// only a missing key, not any other resource the node did not find
+		if chord.ErrorReason(err) == chord.ReasonKeyNotFound {
+			return ErrKeyNotFound
+		}
+	case codes.FailedPrecondition:
+		return ErrStaleContext
+	case codes.Unauthenticated:
+		return ErrUnauthenticated
+	case codes.PermissionDenied:
+		return ErrPermissionDenied
+	case codes.Unavailable:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// return true if the request may succeed on another seed
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
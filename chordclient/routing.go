@@ -0,0 +1,355 @@
+package chordclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cdesiniotis/chord"
+	"github.com/cdesiniotis/chord/chordpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stop learning the ring past this many nodes
+const maxRingSize = 4096
+
+// learn the whole ring at most this often; stale views are repaired in between
+const relearnInterval = 30 * time.Second
+
+// ring is the client's view of the members of the ring, sorted by id
+type ring struct {
+	nodes   []*chordpb.Node
+	keySize int
+}
+
+/* Function: 	successor
+ *
+ * Description:
+ * 		Return the node responsible for id: the first node whose id is
+ *		equal to or follows it on the ring.
+ */
+func (r *ring) successor(id []byte) *chordpb.Node {
+	i := sort.Search(len(r.nodes), func(i int) bool {
+		return bytes.Compare(r.nodes[i].Id, id) >= 0
+	})
+	if i == len(r.nodes) {
+		i = 0
+	}
+	return r.nodes[i]
+}
+
+// return the node responsible for a key
+func (r *ring) lookup(key string) *chordpb.Node {
+	return r.successor(chord.GetPeerID(key, r.keySize))
+}
+
+// return the node following node on the ring
+func (r *ring) next(node *chordpb.Node) *chordpb.Node {
+	i := sort.Search(len(r.nodes), func(i int) bool {
+		return bytes.Compare(r.nodes[i].Id, node.Id) > 0
+	})
+	if i == len(r.nodes) {
+		i = 0
+	}
+	return r.nodes[i]
+}
+
+/* Function: 	update
+ *
+ * Description:
+ * 		Return a copy of the view with the stretch of the ring a routing table
+ *		covers, from its predecessor along its successor list, replaced by the
+ * 		nodes the table lists there; if the successor list wraps around the ring,
+ *		the whole view is. failed is left out unless the table lists it.
+ */
+func (r *ring) update(table *chordpb.RoutingTable, failed *chordpb.Node) *ring {
+	var chain []*chordpb.Node
+	listed := map[string]bool{}
+	wrapped := false
+	for _, node := range append([]*chordpb.Node{table.Predecessor, table.Node}, table.Successors...) {
+		if node == nil || len(node.Id) == 0 {
+			continue
+		}
+		if listed[string(node.Id)] {
+			// the successor list wrapped around the ring: it lists every node
+			wrapped = true
+			break
+		}
+		listed[string(node.Id)] = true
+		chain = append(chain, node)
+	}
+
+	covered := func(id []byte) bool {
+		if wrapped {
+			return true
+		}
+		for i := 0; i+1 < len(chain); i++ {
+			if chord.Between(id, chain[i].Id, chain[i+1].Id) {
+				return true
+			}
+		}
+		return false
+	}
+
+	updated := &ring{keySize: r.keySize}
+	for _, node := range r.nodes {
+		if listed[string(node.Id)] || bytes.Equal(node.Id, failed.Id) || covered(node.Id) {
+			continue
+		}
+		updated.nodes = append(updated.nodes, node)
+	}
+	updated.nodes = append(updated.nodes, chain...)
+	sort.Slice(updated.nodes, func(i, j int) bool {
+		return bytes.Compare(updated.nodes[i].Id, updated.nodes[j].Id) < 0
+	})
+	return updated
+}
+
+// nodes listening on a wildcard address can only be reached through the seeds
+func routable(node *chordpb.Node) bool {
+	ip := net.ParseIP(node.Addr)
+	return node.Addr != "" && (ip == nil || !ip.IsUnspecified())
+}
+
+func nodeAddr(node *chordpb.Node) string {
+	return net.JoinHostPort(node.Addr, strconv.Itoa(int(node.Port)))
+}
+
+/* Function: 	route
+ *
+ * Description:
+ * 		Send a request straight to the node responsible for key, as given by our
+ *		view of the ring. If the view is stale, that is the node is down, answers
+ * 		that it is no longer responsible or no longer serves the virtual node, repair
+ *		the view and retry once. If that fails too, or there is no view, go through
+ * 		the seeds.
+ */
+func (c *Client) route(ctx context.Context, op string, key string, rpc func(context.Context, chordpb.ChordClient) error) error {
+	if !c.config.SmartRouting {
+		return c.do(ctx, op, rpc)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		r := c.view(ctx)
+		if r == nil {
+			break
+		}
+		node := r.lookup(key)
+		if !routable(node) {
+			break
+		}
+
+		err := c.call(ctx, node, rpc)
+		if err == ErrClosed {
+			return err
+		}
+		if err == nil {
+			return nil
+		}
+		if !retryable(err) && status.Code(err) != codes.Aborted {
+			return &Error{Op: op, Addr: nodeAddr(node), Kind: errorKind(err), Err: err}
+		}
+		c.repair(ctx, r, node, status.Code(err) == codes.Aborted)
+		if ctx.Err() != nil {
+			return &Error{Op: op, Addr: nodeAddr(node), Kind: ErrUnavailable, Err: err}
+		}
+	}
+	return c.do(ctx, op, rpc)
+}
+
+// send a request to a single node, addressed to its virtual node
+func (c *Client) call(ctx context.Context, node *chordpb.Node, rpc func(context.Context, chordpb.ChordClient) error) error {
+	conn, err := c.conn(ctx, nodeAddr(node))
+	if err != nil {
+		return err
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+	attemptCtx = chord.WithToken(chord.WithTarget(attemptCtx, node.Id), c.config.Token)
+	return rpc(attemptCtx, chordpb.NewChordClient(conn))
+}
+
+/* Function: 	view
+ *
+ * Description:
+ * 		Return our view of the ring, learning it first if we have none.
+ *		Returns nil if the ring could not be learnt, or was learnt less than
+ * 		relearnInterval ago and then found to be stale beyond repair.
+ */
+func (c *Client) view(ctx context.Context) *ring {
+	c.ringMtx.Lock()
+	r := c.ring
+	c.ringMtx.Unlock()
+	if r != nil {
+		return r
+	}
+
+	// only one request learns the ring, the others wait for it
+	c.refreshMtx.Lock()
+	defer c.refreshMtx.Unlock()
+	c.ringMtx.Lock()
+	r = c.ring
+	c.ringMtx.Unlock()
+	if r != nil {
+		return r
+	}
+	c.ringMtx.Lock()
+	learnt := c.learnt
+	c.ringMtx.Unlock()
+	if !learnt.IsZero() && time.Since(learnt) < relearnInterval {
+		return nil
+	}
+
+	r, err := c.learnRing(ctx)
+	if err != nil {
+		return nil
+	}
+	c.ringMtx.Lock()
+	c.ring = r
+	c.learnt = time.Now()
+	c.ringMtx.Unlock()
+	return r
+}
+
+/* Function: 	repair
+ *
+ * Description:
+ * 		Repair a view found stale at node, unless it was already replaced. Ask
+ *		node (or, if it no longer serves the virtual node, its host) and then the
+ * 		node following it for a routing table, and update only the stretch of the
+ *		view the first one to answer covers. If none answers, drop node from the
+ * 		view; the whole ring is learnt again on next use, at most every
+ *		relearnInterval.
+ */
+func (c *Client) repair(ctx context.Context, r *ring, node *chordpb.Node, aborted bool) {
+	c.refreshMtx.Lock()
+	defer c.refreshMtx.Unlock()
+	c.ringMtx.Lock()
+	current := c.ring
+	c.ringMtx.Unlock()
+	if current != r {
+		return
+	}
+
+	var asked []*chordpb.Node
+	if aborted {
+		asked = append(asked, node, &chordpb.Node{Addr: node.Addr, Port: node.Port})
+	}
+	if next := r.next(node); !bytes.Equal(next.Id, node.Id) {
+		asked = append(asked, next)
+	}
+
+	repaired := &ring{keySize: r.keySize}
+	for _, n := range r.nodes {
+		if !bytes.Equal(n.Id, node.Id) {
+			repaired.nodes = append(repaired.nodes, n)
+		}
+	}
+	for _, n := range asked {
+		if !routable(n) {
+			continue
+		}
+		var table *chordpb.RoutingTable
+		err := c.call(ctx, n, func(ctx context.Context, cc chordpb.ChordClient) error {
+			var err error
+			table, err = cc.GetRoutingTable(ctx, &chordpb.Empty{})
+			return err
+		})
+		if err == nil && table.Node != nil && int(table.KeySize) == r.keySize {
+			repaired = r.update(table, node)
+			break
+		}
+		if err == ErrClosed || ctx.Err() != nil {
+			break
+		}
+	}
+	if len(repaired.nodes) == 0 {
+		repaired = nil
+	}
+
+	c.ringMtx.Lock()
+	if c.ring == r {
+		c.ring = repaired
+	}
+	c.ringMtx.Unlock()
+}
+
+/* Function: 	learnRing
+ *
+ * Description:
+ * 		Ask a seed for its routing table, then every node we hear of (through
+ *		predecessors, successor lists and fingers) for theirs, until no new node
+ * 		turns up. Nodes which do not answer are left out.
+ */
+func (c *Client) learnRing(ctx context.Context) (*ring, error) {
+	var first *chordpb.RoutingTable
+	err := c.do(ctx, "GetRoutingTable", func(ctx context.Context, cc chordpb.ChordClient) error {
+		var err error
+		first, err = cc.GetRoutingTable(ctx, &chordpb.Empty{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// keys would be hashed into another space than the ring's
+	if err := chord.CheckKeySize(int(first.KeySize)); err != nil {
+		return nil, fmt.Errorf("chordclient: %s:%d reported an invalid key size: %v", first.Node.Addr, first.Node.Port, err)
+	}
+
+	members := map[string]*chordpb.Node{string(first.Node.Id): first.Node}
+	seen := map[string]bool{string(first.Node.Id): true}
+	var pending []*chordpb.Node
+	learn := func(table *chordpb.RoutingTable) {
+		heard := append(append([]*chordpb.Node{table.Predecessor}, table.Successors...), table.Fingers...)
+		for _, node := range heard {
+			if node == nil || len(node.Id) == 0 || seen[string(node.Id)] {
+				continue
+			}
+			seen[string(node.Id)] = true
+			pending = append(pending, node)
+		}
+	}
+	learn(first)
+
+	for len(pending) > 0 && len(members) < maxRingSize {
+		node := pending[0]
+		pending = pending[1:]
+		if !routable(node) {
+			// we can not ask it, but the node it was heard from says it is alive
+			members[string(node.Id)] = node
+			continue
+		}
+
+		var table *chordpb.RoutingTable
+		err := c.call(ctx, node, func(ctx context.Context, cc chordpb.ChordClient) error {
+			var err error
+			table, err = cc.GetRoutingTable(ctx, &chordpb.Empty{})
+			return err
+		})
+		if err == ErrClosed {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			continue
+		}
+		members[string(node.Id)] = node
+		learn(table)
+	}
+
+	r := &ring{keySize: int(first.KeySize)}
+	for _, node := range members {
+		r.nodes = append(r.nodes, node)
+	}
+	sort.Slice(r.nodes, func(i, j int) bool {
+		return bytes.Compare(r.nodes[i].Id, r.nodes[j].Id) < 0
+	})
+	return r, nil
+}
@@ -0,0 +1,137 @@
+package chordclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord"
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSmartRouting(t *testing.T) {
+	_, err := chord.CreateChord(chord.DefaultConfig("127.0.0.1", 8072))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	_, err = chord.JoinChord(chord.DefaultConfig("127.0.0.1", 8073), "127.0.0.1", 8072)
+	assert.Nil(t, err, "JoinChord() should not result in error")
+	// Sleep so that nodes stabilize and converge
+	time.Sleep(20 * time.Second)
+
+	ctx := context.Background()
+	c, err := New(DefaultConfig("127.0.0.1:8072"))
+	assert.Nil(t, err)
+	defer c.Close()
+
+	r := c.view(ctx)
+	if !assert.NotNil(t, r, "the client should learn the ring") {
+		return
+	}
+	assert.Equal(t, 2, len(r.nodes), "the client should learn every node of the ring")
+
+	// the view agrees with the ring, and the responsible node serves requests directly
+	proxied, err := New(&Config{Seeds: []string{"127.0.0.1:8072"}, Timeout: time.Second})
+	assert.Nil(t, err)
+	defer proxied.Close()
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("routing-%d", i)
+		want, err := proxied.Locate(ctx, key)
+		assert.Nil(t, err, "Locate() should not result in error")
+		got, err := c.Locate(ctx, key)
+		assert.Nil(t, err, "Locate() should not result in error")
+		assert.True(t, bytes.Equal(want.Id, got.Id), "the view of the ring should agree with the ring on %s", key)
+
+		assert.Nil(t, c.Put(ctx, key, []byte("val"), chordpb.Consistency_ONE), "Put() should not result in error")
+		val, err := c.Get(ctx, key, chordpb.Consistency_ONE)
+		assert.Nil(t, err, "Get() should not result in error")
+		assert.Equal(t, "val", string(val.GetValue()))
+	}
+
+	// a node which is not responsible for a key refuses direct requests
+	key := "routing-0"
+	owner := r.lookup(key)
+	var other *chordpb.Node
+	for _, node := range r.nodes {
+		if !bytes.Equal(node.Id, owner.Id) {
+			other = node
+		}
+	}
+	err = c.call(ctx, other, func(ctx context.Context, cc chordpb.ChordClient) error {
+		_, err := cc.Get(ctx, &chordpb.Key{Key: key})
+		return err
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err), "a node should refuse direct requests for keys it is not responsible for")
+
+	// a new node takes over some keys; the stale view is refreshed on first use
+	_, err = chord.JoinChord(chord.DefaultConfig("127.0.0.1", 8074), "127.0.0.1", 8072)
+	assert.Nil(t, err, "JoinChord() should not result in error")
+	time.Sleep(20 * time.Second)
+
+	fresh, err := New(DefaultConfig("127.0.0.1:8073"))
+	assert.Nil(t, err)
+	defer fresh.Close()
+	newRing := fresh.view(ctx)
+	if !assert.NotNil(t, newRing) || !assert.Equal(t, 3, len(newRing.nodes)) {
+		return
+	}
+	moved := ""
+	for i := 0; i < 1000 && moved == ""; i++ {
+		if newRing.lookup(fmt.Sprintf("moved-%d", i)).Port == 8074 {
+			moved = fmt.Sprintf("moved-%d", i)
+		}
+	}
+	c.ringMtx.Lock()
+	learnt := c.learnt
+	c.ringMtx.Unlock()
+	assert.Nil(t, c.Put(ctx, moved, []byte("val"), chordpb.Consistency_ONE), "Put() with a stale view should not result in error")
+	assert.Equal(t, 3, len(c.view(ctx).nodes), "a stale view should be refreshed")
+	c.ringMtx.Lock()
+	assert.Equal(t, learnt, c.learnt, "a stale view should be repaired without learning the ring again")
+	c.ringMtx.Unlock()
+	val, err := fresh.Get(ctx, moved, chordpb.Consistency_ONE)
+	assert.Nil(t, err, "Get() should not result in error")
+	assert.Equal(t, "val", string(val.GetValue()))
+
+	// the virtual node of the view is gone from its host; the view is
+	// refreshed rather than the key reported missing
+	owner = c.view(ctx).lookup(moved)
+	gone := &chordpb.Node{Id: []byte{0}, Addr: owner.Addr, Port: owner.Port}
+	c.ringMtx.Lock()
+	c.ring = &ring{nodes: []*chordpb.Node{gone}, keySize: c.ring.keySize}
+	c.ringMtx.Unlock()
+	val, err = c.Get(ctx, moved, chordpb.Consistency_ONE)
+	assert.Nil(t, err, "Get() through a virtual node which is gone should not result in error")
+	assert.Equal(t, "val", string(val.GetValue()))
+	assert.Equal(t, 3, len(c.view(ctx).nodes), "a view with a virtual node which is gone should be refreshed")
+}
+
+func TestRingUpdate(t *testing.T) {
+	node := func(id byte) *chordpb.Node { return &chordpb.Node{Id: []byte{id}} }
+	ids := func(r *ring) []byte {
+		var ids []byte
+		for _, n := range r.nodes {
+			ids = append(ids, n.Id[0])
+		}
+		return ids
+	}
+	r := &ring{nodes: []*chordpb.Node{node(10), node(20), node(30), node(40), node(50)}, keySize: 8}
+
+	// 30 failed and 25 joined; the view outside of 20..40 is kept
+	table := &chordpb.RoutingTable{Predecessor: node(20), Node: node(25), Successors: []*chordpb.Node{node(40)}}
+	assert.Equal(t, []byte{10, 20, 25, 40, 50}, ids(r.update(table, node(30))))
+
+	// a successor list wrapping around the ring replaces the whole view
+	table = &chordpb.RoutingTable{Predecessor: node(50), Node: node(10), Successors: []*chordpb.Node{node(20), node(50), node(10)}}
+	assert.Equal(t, []byte{10, 20, 50}, ids(r.update(table, node(30))))
+
+	// the failed node stays if the table still lists it
+	table = &chordpb.RoutingTable{Node: node(30), Successors: []*chordpb.Node{node(40)}}
+	assert.Equal(t, []byte{10, 20, 30, 40, 50}, ids(r.update(table, node(30))))
+
+	// the view is not changed in place
+	assert.Equal(t, []byte{10, 20, 30, 40, 50}, ids(r))
+}
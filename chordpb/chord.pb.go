@@ -29,6 +29,109 @@ const (
 // of the legacy proto package is being used.
 const _ = proto.ProtoPackageIsVersion4
 
+type MemberState int32
+
+const (
+	MemberState_ALIVE   MemberState = 0
+	MemberState_SUSPECT MemberState = 1
+	MemberState_DEAD    MemberState = 2
+	// left the ring gracefully
+	MemberState_LEFT MemberState = 3
+)
+
+// Enum value maps for MemberState.
+var (
+	MemberState_name = map[int32]string{
+		0: "ALIVE",
+		1: "SUSPECT",
+		2: "DEAD",
+		3: "LEFT",
+	}
+	MemberState_value = map[string]int32{
+		"ALIVE":   0,
+		"SUSPECT": 1,
+		"DEAD":    2,
+		"LEFT":    3,
+	}
+)
+
+func (x MemberState) Enum() *MemberState {
+	p := new(MemberState)
+	*p = x
+	return p
+}
+
+func (x MemberState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MemberState) Descriptor() protoreflect.EnumDescriptor {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_enumTypes[0].Descriptor()
+}
+
+func (MemberState) Type() protoreflect.EnumType {
+	return &file_github_com_cdesiniotis_chord_chordpb_chord_proto_enumTypes[0]
+}
+
+func (x MemberState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MemberState.Descriptor instead.
+func (MemberState) EnumDescriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{0}
+}
+
+// How many replicas must answer a read or acknowledge a write
+type Consistency int32
+
+const (
+	Consistency_ONE    Consistency = 0
+	Consistency_QUORUM Consistency = 1
+	Consistency_ALL    Consistency = 2
+)
+
+// Enum value maps for Consistency.
+var (
+	Consistency_name = map[int32]string{
+		0: "ONE",
+		1: "QUORUM",
+		2: "ALL",
+	}
+	Consistency_value = map[string]int32{
+		"ONE":    0,
+		"QUORUM": 1,
+		"ALL":    2,
+	}
+)
+
+func (x Consistency) Enum() *Consistency {
+	p := new(Consistency)
+	*p = x
+	return p
+}
+
+func (x Consistency) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Consistency) Descriptor() protoreflect.EnumDescriptor {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_enumTypes[1].Descriptor()
+}
+
+func (Consistency) Type() protoreflect.EnumType {
+	return &file_github_com_cdesiniotis_chord_chordpb_chord_proto_enumTypes[1]
+}
+
+func (x Consistency) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Consistency.Descriptor instead.
+func (Consistency) EnumDescriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{1}
+}
+
 type Empty struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -177,17 +280,20 @@ func (x *SuccessorList) GetSuccessors() []*Node {
 	return nil
 }
 
-type CoordinatorMsg struct {
+type RoutingTable struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	OldLeaderId []byte `protobuf:"bytes,1,opt,name=oldLeaderId,proto3" json:"oldLeaderId,omitempty"`
-	NewLeaderId []byte `protobuf:"bytes,2,opt,name=newLeaderId,proto3" json:"newLeaderId,omitempty"`
+	Node        *Node   `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Predecessor *Node   `protobuf:"bytes,2,opt,name=predecessor,proto3" json:"predecessor,omitempty"`
+	Successors  []*Node `protobuf:"bytes,3,rep,name=successors,proto3" json:"successors,omitempty"`
+	Fingers     []*Node `protobuf:"bytes,4,rep,name=fingers,proto3" json:"fingers,omitempty"`
+	KeySize     uint32  `protobuf:"varint,5,opt,name=keySize,proto3" json:"keySize,omitempty"`
 }
 
-func (x *CoordinatorMsg) Reset() {
-	*x = CoordinatorMsg{}
+func (x *RoutingTable) Reset() {
+	*x = RoutingTable{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -195,13 +301,13 @@ func (x *CoordinatorMsg) Reset() {
 	}
 }
 
-func (x *CoordinatorMsg) String() string {
+func (x *RoutingTable) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CoordinatorMsg) ProtoMessage() {}
+func (*RoutingTable) ProtoMessage() {}
 
-func (x *CoordinatorMsg) ProtoReflect() protoreflect.Message {
+func (x *RoutingTable) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -213,36 +319,60 @@ func (x *CoordinatorMsg) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CoordinatorMsg.ProtoReflect.Descriptor instead.
-func (*CoordinatorMsg) Descriptor() ([]byte, []int) {
+// Deprecated: Use RoutingTable.ProtoReflect.Descriptor instead.
+func (*RoutingTable) Descriptor() ([]byte, []int) {
 	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *CoordinatorMsg) GetOldLeaderId() []byte {
+func (x *RoutingTable) GetNode() *Node {
 	if x != nil {
-		return x.OldLeaderId
+		return x.Node
 	}
 	return nil
 }
 
-func (x *CoordinatorMsg) GetNewLeaderId() []byte {
+func (x *RoutingTable) GetPredecessor() *Node {
 	if x != nil {
-		return x.NewLeaderId
+		return x.Predecessor
 	}
 	return nil
 }
 
-type ReplicaMsg struct {
+func (x *RoutingTable) GetSuccessors() []*Node {
+	if x != nil {
+		return x.Successors
+	}
+	return nil
+}
+
+func (x *RoutingTable) GetFingers() []*Node {
+	if x != nil {
+		return x.Fingers
+	}
+	return nil
+}
+
+func (x *RoutingTable) GetKeySize() uint32 {
+	if x != nil {
+		return x.KeySize
+	}
+	return 0
+}
+
+type Member struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	LeaderId []byte `protobuf:"bytes,1,opt,name=leaderId,proto3" json:"leaderId,omitempty"`
-	Kv       []*KV  `protobuf:"bytes,2,rep,name=kv,proto3" json:"kv,omitempty"`
+	Node  *Node       `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	State MemberState `protobuf:"varint,2,opt,name=state,proto3,enum=chord.MemberState" json:"state,omitempty"`
+	// raised by the member itself to refute a suspicion, an update about a
+	// member only overrides the ones with a lower incarnation
+	Incarnation uint64 `protobuf:"varint,3,opt,name=incarnation,proto3" json:"incarnation,omitempty"`
 }
 
-func (x *ReplicaMsg) Reset() {
-	*x = ReplicaMsg{}
+func (x *Member) Reset() {
+	*x = Member{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -250,13 +380,13 @@ func (x *ReplicaMsg) Reset() {
 	}
 }
 
-func (x *ReplicaMsg) String() string {
+func (x *Member) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ReplicaMsg) ProtoMessage() {}
+func (*Member) ProtoMessage() {}
 
-func (x *ReplicaMsg) ProtoReflect() protoreflect.Message {
+func (x *Member) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -268,35 +398,45 @@ func (x *ReplicaMsg) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ReplicaMsg.ProtoReflect.Descriptor instead.
-func (*ReplicaMsg) Descriptor() ([]byte, []int) {
+// Deprecated: Use Member.ProtoReflect.Descriptor instead.
+func (*Member) Descriptor() ([]byte, []int) {
 	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *ReplicaMsg) GetLeaderId() []byte {
+func (x *Member) GetNode() *Node {
 	if x != nil {
-		return x.LeaderId
+		return x.Node
 	}
 	return nil
 }
 
-func (x *ReplicaMsg) GetKv() []*KV {
+func (x *Member) GetState() MemberState {
 	if x != nil {
-		return x.Kv
+		return x.State
 	}
-	return nil
+	return MemberState_ALIVE
 }
 
-type PeerID struct {
+func (x *Member) GetIncarnation() uint64 {
+	if x != nil {
+		return x.Incarnation
+	}
+	return 0
+}
+
+type GossipMsg struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id []byte `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Members []*Member `protobuf:"bytes,1,rep,name=members,proto3" json:"members,omitempty"`
+	// the members are the whole view of the sender rather than recent updates,
+	// and the reply must be the whole view of the receiver
+	Full bool `protobuf:"varint,2,opt,name=full,proto3" json:"full,omitempty"`
 }
 
-func (x *PeerID) Reset() {
-	*x = PeerID{}
+func (x *GossipMsg) Reset() {
+	*x = GossipMsg{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -304,13 +444,13 @@ func (x *PeerID) Reset() {
 	}
 }
 
-func (x *PeerID) String() string {
+func (x *GossipMsg) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PeerID) ProtoMessage() {}
+func (*GossipMsg) ProtoMessage() {}
 
-func (x *PeerID) ProtoReflect() protoreflect.Message {
+func (x *GossipMsg) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -322,28 +462,35 @@ func (x *PeerID) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PeerID.ProtoReflect.Descriptor instead.
-func (*PeerID) Descriptor() ([]byte, []int) {
+// Deprecated: Use GossipMsg.ProtoReflect.Descriptor instead.
+func (*GossipMsg) Descriptor() ([]byte, []int) {
 	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *PeerID) GetId() []byte {
+func (x *GossipMsg) GetMembers() []*Member {
 	if x != nil {
-		return x.Id
+		return x.Members
 	}
 	return nil
 }
 
-type Key struct {
+func (x *GossipMsg) GetFull() bool {
+	if x != nil {
+		return x.Full
+	}
+	return false
+}
+
+type Members struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Members []*Member `protobuf:"bytes,1,rep,name=members,proto3" json:"members,omitempty"`
 }
 
-func (x *Key) Reset() {
-	*x = Key{}
+func (x *Members) Reset() {
+	*x = Members{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -351,13 +498,13 @@ func (x *Key) Reset() {
 	}
 }
 
-func (x *Key) String() string {
+func (x *Members) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Key) ProtoMessage() {}
+func (*Members) ProtoMessage() {}
 
-func (x *Key) ProtoReflect() protoreflect.Message {
+func (x *Members) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -369,28 +516,32 @@ func (x *Key) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Key.ProtoReflect.Descriptor instead.
-func (*Key) Descriptor() ([]byte, []int) {
+// Deprecated: Use Members.ProtoReflect.Descriptor instead.
+func (*Members) Descriptor() ([]byte, []int) {
 	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *Key) GetKey() string {
+func (x *Members) GetMembers() []*Member {
 	if x != nil {
-		return x.Key
+		return x.Members
 	}
-	return ""
+	return nil
 }
 
-type Value struct {
+type Hop struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Value []byte `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	// node is the successor of the ID, rather than a node preceding it
+	Done bool  `protobuf:"varint,1,opt,name=done,proto3" json:"done,omitempty"`
+	Node *Node `protobuf:"bytes,2,opt,name=node,proto3" json:"node,omitempty"`
+	// other nodes preceding the ID, to ask if node does not answer
+	Alternates []*Node `protobuf:"bytes,3,rep,name=alternates,proto3" json:"alternates,omitempty"`
 }
 
-func (x *Value) Reset() {
-	*x = Value{}
+func (x *Hop) Reset() {
+	*x = Hop{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -398,13 +549,13 @@ func (x *Value) Reset() {
 	}
 }
 
-func (x *Value) String() string {
+func (x *Hop) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Value) ProtoMessage() {}
+func (*Hop) ProtoMessage() {}
 
-func (x *Value) ProtoReflect() protoreflect.Message {
+func (x *Hop) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -416,29 +567,47 @@ func (x *Value) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Value.ProtoReflect.Descriptor instead.
-func (*Value) Descriptor() ([]byte, []int) {
+// Deprecated: Use Hop.ProtoReflect.Descriptor instead.
+func (*Hop) Descriptor() ([]byte, []int) {
 	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *Value) GetValue() []byte {
+func (x *Hop) GetDone() bool {
 	if x != nil {
-		return x.Value
+		return x.Done
+	}
+	return false
+}
+
+func (x *Hop) GetNode() *Node {
+	if x != nil {
+		return x.Node
 	}
 	return nil
 }
 
-type KV struct {
+func (x *Hop) GetAlternates() []*Node {
+	if x != nil {
+		return x.Alternates
+	}
+	return nil
+}
+
+// A node asked during an iterative lookup
+type PathHop struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Node *Node `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	// of the NextHop RPC, in ns
+	Latency int64 `protobuf:"varint,2,opt,name=latency,proto3" json:"latency,omitempty"`
+	// empty if the node answered
+	Error string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
 }
 
-func (x *KV) Reset() {
-	*x = KV{}
+func (x *PathHop) Reset() {
+	*x = PathHop{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -446,13 +615,13 @@ func (x *KV) Reset() {
 	}
 }
 
-func (x *KV) String() string {
+func (x *PathHop) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*KV) ProtoMessage() {}
+func (*PathHop) ProtoMessage() {}
 
-func (x *KV) ProtoReflect() protoreflect.Message {
+func (x *PathHop) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -464,35 +633,45 @@ func (x *KV) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use KV.ProtoReflect.Descriptor instead.
-func (*KV) Descriptor() ([]byte, []int) {
+// Deprecated: Use PathHop.ProtoReflect.Descriptor instead.
+func (*PathHop) Descriptor() ([]byte, []int) {
 	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *KV) GetKey() string {
+func (x *PathHop) GetNode() *Node {
 	if x != nil {
-		return x.Key
+		return x.Node
 	}
-	return ""
+	return nil
 }
 
-func (x *KV) GetValue() []byte {
+func (x *PathHop) GetLatency() int64 {
 	if x != nil {
-		return x.Value
+		return x.Latency
 	}
-	return nil
+	return 0
 }
 
-type KVs struct {
+func (x *PathHop) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type Path struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Kvs []*KV `protobuf:"bytes,1,rep,name=kvs,proto3" json:"kvs,omitempty"`
+	// starting at the node asked for the trace
+	Hops []*PathHop `protobuf:"bytes,1,rep,name=hops,proto3" json:"hops,omitempty"`
+	// unset if the lookup failed, the errors of the last hops telling why
+	Successor *Node `protobuf:"bytes,2,opt,name=successor,proto3" json:"successor,omitempty"`
 }
 
-func (x *KVs) Reset() {
-	*x = KVs{}
+func (x *Path) Reset() {
+	*x = Path{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -500,13 +679,13 @@ func (x *KVs) Reset() {
 	}
 }
 
-func (x *KVs) String() string {
+func (x *Path) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*KVs) ProtoMessage() {}
+func (*Path) ProtoMessage() {}
 
-func (x *KVs) ProtoReflect() protoreflect.Message {
+func (x *Path) ProtoReflect() protoreflect.Message {
 	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -518,162 +697,1282 @@ func (x *KVs) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use KVs.ProtoReflect.Descriptor instead.
-func (*KVs) Descriptor() ([]byte, []int) {
+// Deprecated: Use Path.ProtoReflect.Descriptor instead.
+func (*Path) Descriptor() ([]byte, []int) {
 	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *KVs) GetKvs() []*KV {
+func (x *Path) GetHops() []*PathHop {
 	if x != nil {
-		return x.Kvs
+		return x.Hops
 	}
 	return nil
 }
 
-var File_github_com_cdesiniotis_chord_chordpb_chord_proto protoreflect.FileDescriptor
+func (x *Path) GetSuccessor() *Node {
+	if x != nil {
+		return x.Successor
+	}
+	return nil
+}
 
-var file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDesc = []byte{
-	0x0a, 0x30, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x64, 0x65,
-	0x73, 0x69, 0x6e, 0x69, 0x6f, 0x74, 0x69, 0x73, 0x2f, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2f, 0x63,
-	0x68, 0x6f, 0x72, 0x64, 0x70, 0x62, 0x2f, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x12, 0x05, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x22, 0x07, 0x0a, 0x05, 0x65, 0x6d, 0x70,
-	0x74, 0x79, 0x22, 0x3e, 0x0a, 0x04, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x64,
-	0x64, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x64, 0x64, 0x72, 0x12, 0x12,
-	0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x70, 0x6f,
-	0x72, 0x74, 0x22, 0x3c, 0x0a, 0x0d, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x4c,
-	0x69, 0x73, 0x74, 0x12, 0x2b, 0x0a, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72,
-	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e,
-	0x4e, 0x6f, 0x64, 0x65, 0x52, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73,
-	0x22, 0x54, 0x0a, 0x0e, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x6f, 0x72, 0x4d,
-	0x73, 0x67, 0x12, 0x20, 0x0a, 0x0b, 0x6f, 0x6c, 0x64, 0x4c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49,
-	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x6f, 0x6c, 0x64, 0x4c, 0x65, 0x61, 0x64,
-	0x65, 0x72, 0x49, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x6e, 0x65, 0x77, 0x4c, 0x65, 0x61, 0x64, 0x65,
-	0x72, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x6e, 0x65, 0x77, 0x4c, 0x65,
-	0x61, 0x64, 0x65, 0x72, 0x49, 0x64, 0x22, 0x43, 0x0a, 0x0a, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63,
-	0x61, 0x4d, 0x73, 0x67, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49, 0x64,
-	0x12, 0x19, 0x0a, 0x02, 0x6b, 0x76, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x63,
-	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x52, 0x02, 0x6b, 0x76, 0x22, 0x18, 0x0a, 0x06, 0x50,
-	0x65, 0x65, 0x72, 0x49, 0x44, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0c, 0x52, 0x02, 0x69, 0x64, 0x22, 0x17, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x10, 0x0a, 0x03,
-	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x22, 0x1d,
-	0x0a, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x2c, 0x0a,
-	0x02, 0x4b, 0x56, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x22, 0x0a, 0x03, 0x4b,
-	0x56, 0x73, 0x12, 0x1b, 0x0a, 0x03, 0x6b, 0x76, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
-	0x09, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x52, 0x03, 0x6b, 0x76, 0x73, 0x32,
-	0xaf, 0x04, 0x0a, 0x05, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x12, 0x2d, 0x0a, 0x0d, 0x46, 0x69, 0x6e,
-	0x64, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x12, 0x0d, 0x2e, 0x63, 0x68, 0x6f,
-	0x72, 0x64, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x49, 0x44, 0x1a, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72,
-	0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x22, 0x00, 0x12, 0x2d, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x50,
-	0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x12, 0x0c, 0x2e, 0x63, 0x68, 0x6f,
-	0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64,
-	0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x22, 0x00, 0x12, 0x25, 0x0a, 0x06, 0x4e, 0x6f, 0x74, 0x69, 0x66,
-	0x79, 0x12, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x1a, 0x0c,
-	0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x30,
-	0x0a, 0x10, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x50, 0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73,
-	0x6f, 0x72, 0x12, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79,
-	0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00,
-	0x12, 0x38, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72,
-	0x4c, 0x69, 0x73, 0x74, 0x12, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70,
-	0x74, 0x79, 0x1a, 0x14, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x53, 0x75, 0x63, 0x63, 0x65,
-	0x73, 0x73, 0x6f, 0x72, 0x4c, 0x69, 0x73, 0x74, 0x22, 0x00, 0x12, 0x3b, 0x0a, 0x12, 0x52, 0x65,
-	0x63, 0x76, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x6f, 0x72, 0x4d, 0x73, 0x67,
-	0x12, 0x15, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e,
-	0x61, 0x74, 0x6f, 0x72, 0x4d, 0x73, 0x67, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e,
-	0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x26, 0x0a, 0x07, 0x47, 0x65, 0x74, 0x4b, 0x65,
-	0x79, 0x73, 0x12, 0x0d, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x49,
-	0x44, 0x1a, 0x0a, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x73, 0x22, 0x00, 0x12,
-	0x31, 0x0a, 0x0c, 0x53, 0x65, 0x6e, 0x64, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x73, 0x12,
-	0x11, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x4d,
-	0x73, 0x67, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79,
-	0x22, 0x00, 0x12, 0x33, 0x0a, 0x0e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x52, 0x65, 0x70, 0x6c,
-	0x69, 0x63, 0x61, 0x73, 0x12, 0x11, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x52, 0x65, 0x70,
-	0x6c, 0x69, 0x63, 0x61, 0x4d, 0x73, 0x67, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e,
-	0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x21, 0x0a, 0x03, 0x47, 0x65, 0x74, 0x12, 0x0a,
-	0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x65, 0x79, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f,
-	0x72, 0x64, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x00, 0x12, 0x20, 0x0a, 0x03, 0x50, 0x75,
-	0x74, 0x12, 0x09, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x1a, 0x0c, 0x2e, 0x63,
-	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x23, 0x0a, 0x06,
-	0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x12, 0x0a, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b,
-	0x65, 0x79, 0x1a, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x22,
-	0x00, 0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
-	0x63, 0x64, 0x65, 0x73, 0x69, 0x6e, 0x69, 0x6f, 0x74, 0x69, 0x73, 0x2f, 0x63, 0x68, 0x6f, 0x72,
-	0x64, 0x2f, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
+type CoordinatorMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	OldLeaderId []byte `protobuf:"bytes,1,opt,name=oldLeaderId,proto3" json:"oldLeaderId,omitempty"`
+	NewLeaderId []byte `protobuf:"bytes,2,opt,name=newLeaderId,proto3" json:"newLeaderId,omitempty"`
 }
 
-var (
-	file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescOnce sync.Once
-	file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescData = file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDesc
-)
+func (x *CoordinatorMsg) Reset() {
+	*x = CoordinatorMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
 
-func file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP() []byte {
-	file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescOnce.Do(func() {
-		file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescData = protoimpl.X.CompressGZIP(file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescData)
-	})
-	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescData
+func (x *CoordinatorMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
-var file_github_com_cdesiniotis_chord_chordpb_chord_proto_goTypes = []interface{}{
-	(*Empty)(nil),          // 0: chord.empty
-	(*Node)(nil),           // 1: chord.Node
-	(*SuccessorList)(nil),  // 2: chord.SuccessorList
-	(*CoordinatorMsg)(nil), // 3: chord.CoordinatorMsg
-	(*ReplicaMsg)(nil),     // 4: chord.ReplicaMsg
-	(*PeerID)(nil),         // 5: chord.PeerID
-	(*Key)(nil),            // 6: chord.Key
-	(*Value)(nil),          // 7: chord.Value
-	(*KV)(nil),             // 8: chord.KV
-	(*KVs)(nil),            // 9: chord.KVs
+func (*CoordinatorMsg) ProtoMessage() {}
+
+func (x *CoordinatorMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
-var file_github_com_cdesiniotis_chord_chordpb_chord_proto_depIdxs = []int32{
-	1,  // 0: chord.SuccessorList.successors:type_name -> chord.Node
-	8,  // 1: chord.ReplicaMsg.kv:type_name -> chord.KV
-	8,  // 2: chord.KVs.kvs:type_name -> chord.KV
-	5,  // 3: chord.chord.FindSuccessor:input_type -> chord.PeerID
-	0,  // 4: chord.chord.GetPredecessor:input_type -> chord.empty
-	1,  // 5: chord.chord.Notify:input_type -> chord.Node
-	0,  // 6: chord.chord.CheckPredecessor:input_type -> chord.empty
-	0,  // 7: chord.chord.GetSuccessorList:input_type -> chord.empty
-	3,  // 8: chord.chord.RecvCoordinatorMsg:input_type -> chord.CoordinatorMsg
-	5,  // 9: chord.chord.GetKeys:input_type -> chord.PeerID
-	4,  // 10: chord.chord.SendReplicas:input_type -> chord.ReplicaMsg
-	4,  // 11: chord.chord.RemoveReplicas:input_type -> chord.ReplicaMsg
-	6,  // 12: chord.chord.Get:input_type -> chord.Key
-	8,  // 13: chord.chord.Put:input_type -> chord.KV
-	6,  // 14: chord.chord.Locate:input_type -> chord.Key
-	1,  // 15: chord.chord.FindSuccessor:output_type -> chord.Node
-	1,  // 16: chord.chord.GetPredecessor:output_type -> chord.Node
-	0,  // 17: chord.chord.Notify:output_type -> chord.empty
-	0,  // 18: chord.chord.CheckPredecessor:output_type -> chord.empty
-	2,  // 19: chord.chord.GetSuccessorList:output_type -> chord.SuccessorList
-	0,  // 20: chord.chord.RecvCoordinatorMsg:output_type -> chord.empty
-	9,  // 21: chord.chord.GetKeys:output_type -> chord.KVs
-	0,  // 22: chord.chord.SendReplicas:output_type -> chord.empty
-	0,  // 23: chord.chord.RemoveReplicas:output_type -> chord.empty
-	7,  // 24: chord.chord.Get:output_type -> chord.Value
-	0,  // 25: chord.chord.Put:output_type -> chord.empty
-	1,  // 26: chord.chord.Locate:output_type -> chord.Node
-	15, // [15:27] is the sub-list for method output_type
-	3,  // [3:15] is the sub-list for method input_type
-	3,  // [3:3] is the sub-list for extension type_name
-	3,  // [3:3] is the sub-list for extension extendee
-	0,  // [0:3] is the sub-list for field type_name
+
+// Deprecated: Use CoordinatorMsg.ProtoReflect.Descriptor instead.
+func (*CoordinatorMsg) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{10}
 }
 
-func init() { file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() }
-func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
-	if File_github_com_cdesiniotis_chord_chordpb_chord_proto != nil {
-		return
+func (x *CoordinatorMsg) GetOldLeaderId() []byte {
+	if x != nil {
+		return x.OldLeaderId
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Empty); i {
+	return nil
+}
+
+func (x *CoordinatorMsg) GetNewLeaderId() []byte {
+	if x != nil {
+		return x.NewLeaderId
+	}
+	return nil
+}
+
+type ReplicaMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LeaderId []byte `protobuf:"bytes,1,opt,name=leaderId,proto3" json:"leaderId,omitempty"`
+	Kv       []*KV  `protobuf:"bytes,2,rep,name=kv,proto3" json:"kv,omitempty"`
+}
+
+func (x *ReplicaMsg) Reset() {
+	*x = ReplicaMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplicaMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicaMsg) ProtoMessage() {}
+
+func (x *ReplicaMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicaMsg.ProtoReflect.Descriptor instead.
+func (*ReplicaMsg) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ReplicaMsg) GetLeaderId() []byte {
+	if x != nil {
+		return x.LeaderId
+	}
+	return nil
+}
+
+func (x *ReplicaMsg) GetKv() []*KV {
+	if x != nil {
+		return x.Kv
+	}
+	return nil
+}
+
+type PeerID struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id []byte `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *PeerID) Reset() {
+	*x = PeerID{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeerID) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerID) ProtoMessage() {}
+
+func (x *PeerID) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerID.ProtoReflect.Descriptor instead.
+func (*PeerID) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PeerID) GetId() []byte {
+	if x != nil {
+		return x.Id
+	}
+	return nil
+}
+
+type KeysRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id []byte `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// keys the caller already holds, so only missing or changed keys are sent back
+	Have []*KeyDigest `protobuf:"bytes,2,rep,name=have,proto3" json:"have,omitempty"`
+}
+
+func (x *KeysRequest) Reset() {
+	*x = KeysRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeysRequest) ProtoMessage() {}
+
+func (x *KeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeysRequest.ProtoReflect.Descriptor instead.
+func (*KeysRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *KeysRequest) GetId() []byte {
+	if x != nil {
+		return x.Id
+	}
+	return nil
+}
+
+func (x *KeysRequest) GetHave() []*KeyDigest {
+	if x != nil {
+		return x.Have
+	}
+	return nil
+}
+
+type KeyDigest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// SHA-1 hash of the value
+	Digest []byte `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+}
+
+func (x *KeyDigest) Reset() {
+	*x = KeyDigest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KeyDigest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyDigest) ProtoMessage() {}
+
+func (x *KeyDigest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyDigest.ProtoReflect.Descriptor instead.
+func (*KeyDigest) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *KeyDigest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *KeyDigest) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+type Key struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key         string      `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Consistency Consistency `protobuf:"varint,2,opt,name=consistency,proto3,enum=chord.Consistency" json:"consistency,omitempty"`
+}
+
+func (x *Key) Reset() {
+	*x = Key{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Key) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Key) ProtoMessage() {}
+
+func (x *Key) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Key.ProtoReflect.Descriptor instead.
+func (*Key) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Key) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Key) GetConsistency() Consistency {
+	if x != nil {
+		return x.Consistency
+	}
+	return Consistency_ONE
+}
+
+// One entry of a vector clock: the number of writes of a key coordinated by a node
+type ClockEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Node    []byte `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Counter uint64 `protobuf:"varint,2,opt,name=counter,proto3" json:"counter,omitempty"`
+}
+
+func (x *ClockEntry) Reset() {
+	*x = ClockEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClockEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClockEntry) ProtoMessage() {}
+
+func (x *ClockEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClockEntry.ProtoReflect.Descriptor instead.
+func (*ClockEntry) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ClockEntry) GetNode() []byte {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+func (x *ClockEntry) GetCounter() uint64 {
+	if x != nil {
+		return x.Counter
+	}
+	return 0
+}
+
+// A version of a key written concurrently with its other siblings
+type Sibling struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Value []byte        `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Clock []*ClockEntry `protobuf:"bytes,2,rep,name=clock,proto3" json:"clock,omitempty"`
+}
+
+func (x *Sibling) Reset() {
+	*x = Sibling{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Sibling) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sibling) ProtoMessage() {}
+
+func (x *Sibling) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sibling.ProtoReflect.Descriptor instead.
+func (*Sibling) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Sibling) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Sibling) GetClock() []*ClockEntry {
+	if x != nil {
+		return x.Clock
+	}
+	return nil
+}
+
+type Value struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// only set if the key has a single version
+	Value []byte `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	// every concurrent version of the key, for the caller to resolve
+	Siblings []*Sibling `protobuf:"bytes,2,rep,name=siblings,proto3" json:"siblings,omitempty"`
+	// clock of the key's deletion, if the deletion is not superseded
+	Tombstone []*ClockEntry `protobuf:"bytes,3,rep,name=tombstone,proto3" json:"tombstone,omitempty"`
+	// clock covering every version read, to pass back in KV.clock when
+	// writing the resolved value
+	Context []*ClockEntry `protobuf:"bytes,4,rep,name=context,proto3" json:"context,omitempty"`
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Value) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Value) GetSiblings() []*Sibling {
+	if x != nil {
+		return x.Siblings
+	}
+	return nil
+}
+
+func (x *Value) GetTombstone() []*ClockEntry {
+	if x != nil {
+		return x.Tombstone
+	}
+	return nil
+}
+
+func (x *Value) GetContext() []*ClockEntry {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+type KV struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	// version of the kv. On Put, the context of the versions the write replaces
+	Clock       []*ClockEntry `protobuf:"bytes,3,rep,name=clock,proto3" json:"clock,omitempty"`
+	Consistency Consistency   `protobuf:"varint,4,opt,name=consistency,proto3,enum=chord.Consistency" json:"consistency,omitempty"`
+}
+
+func (x *KV) Reset() {
+	*x = KV{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KV) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KV) ProtoMessage() {}
+
+func (x *KV) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KV.ProtoReflect.Descriptor instead.
+func (*KV) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *KV) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *KV) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *KV) GetClock() []*ClockEntry {
+	if x != nil {
+		return x.Clock
+	}
+	return nil
+}
+
+func (x *KV) GetConsistency() Consistency {
+	if x != nil {
+		return x.Consistency
+	}
+	return Consistency_ONE
+}
+
+type ReplicaKey struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LeaderId []byte `protobuf:"bytes,1,opt,name=leaderId,proto3" json:"leaderId,omitempty"`
+	Key      string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (x *ReplicaKey) Reset() {
+	*x = ReplicaKey{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplicaKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicaKey) ProtoMessage() {}
+
+func (x *ReplicaKey) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicaKey.ProtoReflect.Descriptor instead.
+func (*ReplicaKey) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ReplicaKey) GetLeaderId() []byte {
+	if x != nil {
+		return x.LeaderId
+	}
+	return nil
+}
+
+func (x *ReplicaKey) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+type LeaveMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the departing node
+	Node        *Node `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Predecessor *Node `protobuf:"bytes,2,opt,name=predecessor,proto3" json:"predecessor,omitempty"`
+	Successor   *Node `protobuf:"bytes,3,opt,name=successor,proto3" json:"successor,omitempty"`
+	// primary keys of the departing node, only sent to its successor
+	Kvs        []*KV `protobuf:"bytes,4,rep,name=kvs,proto3" json:"kvs,omitempty"`
+	Tombstones []*KV `protobuf:"bytes,5,rep,name=tombstones,proto3" json:"tombstones,omitempty"`
+}
+
+func (x *LeaveMsg) Reset() {
+	*x = LeaveMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LeaveMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveMsg) ProtoMessage() {}
+
+func (x *LeaveMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveMsg.ProtoReflect.Descriptor instead.
+func (*LeaveMsg) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *LeaveMsg) GetNode() *Node {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+func (x *LeaveMsg) GetPredecessor() *Node {
+	if x != nil {
+		return x.Predecessor
+	}
+	return nil
+}
+
+func (x *LeaveMsg) GetSuccessor() *Node {
+	if x != nil {
+		return x.Successor
+	}
+	return nil
+}
+
+func (x *LeaveMsg) GetKvs() []*KV {
+	if x != nil {
+		return x.Kvs
+	}
+	return nil
+}
+
+func (x *LeaveMsg) GetTombstones() []*KV {
+	if x != nil {
+		return x.Tombstones
+	}
+	return nil
+}
+
+type KVs struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Kvs []*KV `protobuf:"bytes,1,rep,name=kvs,proto3" json:"kvs,omitempty"`
+	// keys deleted by the leader, along with the clock of the deletion
+	Tombstones []*KV `protobuf:"bytes,2,rep,name=tombstones,proto3" json:"tombstones,omitempty"`
+}
+
+func (x *KVs) Reset() {
+	*x = KVs{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KVs) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KVs) ProtoMessage() {}
+
+func (x *KVs) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KVs.ProtoReflect.Descriptor instead.
+func (*KVs) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *KVs) GetKvs() []*KV {
+	if x != nil {
+		return x.Kvs
+	}
+	return nil
+}
+
+func (x *KVs) GetTombstones() []*KV {
+	if x != nil {
+		return x.Tombstones
+	}
+	return nil
+}
+
+type MerkleTree struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LeaderId []byte `protobuf:"bytes,1,opt,name=leaderId,proto3" json:"leaderId,omitempty"`
+	// hashes of the tree nodes in breadth-first order, starting at the root
+	Hashes [][]byte `protobuf:"bytes,2,rep,name=hashes,proto3" json:"hashes,omitempty"`
+}
+
+func (x *MerkleTree) Reset() {
+	*x = MerkleTree{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MerkleTree) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MerkleTree) ProtoMessage() {}
+
+func (x *MerkleTree) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MerkleTree.ProtoReflect.Descriptor instead.
+func (*MerkleTree) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *MerkleTree) GetLeaderId() []byte {
+	if x != nil {
+		return x.LeaderId
+	}
+	return nil
+}
+
+func (x *MerkleTree) GetHashes() [][]byte {
+	if x != nil {
+		return x.Hashes
+	}
+	return nil
+}
+
+type MerkleDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// leaves of the tree, i.e. ranges of key hashes, which differ
+	Ranges     []uint32 `protobuf:"varint,1,rep,packed,name=ranges,proto3" json:"ranges,omitempty"`
+	Kvs        []*KV    `protobuf:"bytes,2,rep,name=kvs,proto3" json:"kvs,omitempty"`
+	Tombstones []*KV    `protobuf:"bytes,3,rep,name=tombstones,proto3" json:"tombstones,omitempty"`
+}
+
+func (x *MerkleDiff) Reset() {
+	*x = MerkleDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MerkleDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MerkleDiff) ProtoMessage() {}
+
+func (x *MerkleDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MerkleDiff.ProtoReflect.Descriptor instead.
+func (*MerkleDiff) Descriptor() ([]byte, []int) {
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *MerkleDiff) GetRanges() []uint32 {
+	if x != nil {
+		return x.Ranges
+	}
+	return nil
+}
+
+func (x *MerkleDiff) GetKvs() []*KV {
+	if x != nil {
+		return x.Kvs
+	}
+	return nil
+}
+
+func (x *MerkleDiff) GetTombstones() []*KV {
+	if x != nil {
+		return x.Tombstones
+	}
+	return nil
+}
+
+var File_github_com_cdesiniotis_chord_chordpb_chord_proto protoreflect.FileDescriptor
+
+var file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDesc = []byte{
+	0x0a, 0x30, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x64, 0x65,
+	0x73, 0x69, 0x6e, 0x69, 0x6f, 0x74, 0x69, 0x73, 0x2f, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2f, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x70, 0x62, 0x2f, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x12, 0x05, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x22, 0x07, 0x0a, 0x05, 0x65, 0x6d, 0x70,
+	0x74, 0x79, 0x22, 0x3e, 0x0a, 0x04, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x64,
+	0x64, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x64, 0x64, 0x72, 0x12, 0x12,
+	0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x70, 0x6f,
+	0x72, 0x74, 0x22, 0x3c, 0x0a, 0x0d, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x4c,
+	0x69, 0x73, 0x74, 0x12, 0x2b, 0x0a, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e,
+	0x4e, 0x6f, 0x64, 0x65, 0x52, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73,
+	0x22, 0xcc, 0x01, 0x0a, 0x0c, 0x52, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x54, 0x61, 0x62, 0x6c,
+	0x65, 0x12, 0x1f, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x6e, 0x6f,
+	0x64, 0x65, 0x12, 0x2d, 0x0a, 0x0b, 0x70, 0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f,
+	0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e,
+	0x4e, 0x6f, 0x64, 0x65, 0x52, 0x0b, 0x70, 0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f,
+	0x72, 0x12, 0x2b, 0x0a, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f,
+	0x64, 0x65, 0x52, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x12, 0x25,
+	0x0a, 0x07, 0x66, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x07, 0x66, 0x69,
+	0x6e, 0x67, 0x65, 0x72, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6b, 0x65, 0x79, 0x53, 0x69, 0x7a, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x6b, 0x65, 0x79, 0x53, 0x69, 0x7a, 0x65, 0x22,
+	0x75, 0x0a, 0x06, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x1f, 0x0a, 0x04, 0x6e, 0x6f, 0x64,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e,
+	0x4e, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x28, 0x0a, 0x05, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x12, 0x2e, 0x63, 0x68, 0x6f, 0x72,
+	0x64, 0x2e, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x69, 0x6e, 0x63, 0x61, 0x72, 0x6e, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x69, 0x6e, 0x63, 0x61, 0x72,
+	0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x48, 0x0a, 0x09, 0x47, 0x6f, 0x73, 0x73, 0x69, 0x70,
+	0x4d, 0x73, 0x67, 0x12, 0x27, 0x0a, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4d, 0x65, 0x6d,
+	0x62, 0x65, 0x72, 0x52, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04,
+	0x66, 0x75, 0x6c, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x66, 0x75, 0x6c, 0x6c,
+	0x22, 0x32, 0x0a, 0x07, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x27, 0x0a, 0x07, 0x6d,
+	0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x52, 0x07, 0x6d, 0x65, 0x6d,
+	0x62, 0x65, 0x72, 0x73, 0x22, 0x67, 0x0a, 0x03, 0x48, 0x6f, 0x70, 0x12, 0x12, 0x0a, 0x04, 0x64,
+	0x6f, 0x6e, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x64, 0x6f, 0x6e, 0x65, 0x12,
+	0x1f, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e,
+	0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65,
+	0x12, 0x2b, 0x0a, 0x0a, 0x61, 0x6c, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x74, 0x65, 0x73, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64,
+	0x65, 0x52, 0x0a, 0x61, 0x6c, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x74, 0x65, 0x73, 0x22, 0x5a, 0x0a,
+	0x07, 0x50, 0x61, 0x74, 0x68, 0x48, 0x6f, 0x70, 0x12, 0x1f, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e,
+	0x6f, 0x64, 0x65, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6c, 0x61, 0x74,
+	0x65, 0x6e, 0x63, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x6c, 0x61, 0x74, 0x65,
+	0x6e, 0x63, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x55, 0x0a, 0x04, 0x50, 0x61, 0x74,
+	0x68, 0x12, 0x22, 0x0a, 0x04, 0x68, 0x6f, 0x70, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x0e, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x50, 0x61, 0x74, 0x68, 0x48, 0x6f, 0x70, 0x52,
+	0x04, 0x68, 0x6f, 0x70, 0x73, 0x12, 0x29, 0x0a, 0x09, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73,
+	0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64,
+	0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x09, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72,
+	0x22, 0x54, 0x0a, 0x0e, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x6f, 0x72, 0x4d,
+	0x73, 0x67, 0x12, 0x20, 0x0a, 0x0b, 0x6f, 0x6c, 0x64, 0x4c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x6f, 0x6c, 0x64, 0x4c, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x49, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x6e, 0x65, 0x77, 0x4c, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x6e, 0x65, 0x77, 0x4c, 0x65,
+	0x61, 0x64, 0x65, 0x72, 0x49, 0x64, 0x22, 0x43, 0x0a, 0x0a, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63,
+	0x61, 0x4d, 0x73, 0x67, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49, 0x64,
+	0x12, 0x19, 0x0a, 0x02, 0x6b, 0x76, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x52, 0x02, 0x6b, 0x76, 0x22, 0x18, 0x0a, 0x06, 0x50,
+	0x65, 0x65, 0x72, 0x49, 0x44, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x02, 0x69, 0x64, 0x22, 0x43, 0x0a, 0x0b, 0x4b, 0x65, 0x79, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x02, 0x69, 0x64, 0x12, 0x24, 0x0a, 0x04, 0x68, 0x61, 0x76, 0x65, 0x18, 0x02, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x10, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x65, 0x79, 0x44, 0x69,
+	0x67, 0x65, 0x73, 0x74, 0x52, 0x04, 0x68, 0x61, 0x76, 0x65, 0x22, 0x35, 0x0a, 0x09, 0x4b, 0x65,
+	0x79, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67,
+	0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73,
+	0x74, 0x22, 0x4d, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x34, 0x0a, 0x0b, 0x63, 0x6f,
+	0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x12, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x43, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65,
+	0x6e, 0x63, 0x79, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79,
+	0x22, 0x3a, 0x0a, 0x0a, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x12,
+	0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x6e, 0x6f,
+	0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x22, 0x48, 0x0a, 0x07,
+	0x53, 0x69, 0x62, 0x6c, 0x69, 0x6e, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x27, 0x0a,
+	0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
+	0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x22, 0xa7, 0x01, 0x0a, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65,
+	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x2a, 0x0a, 0x08, 0x73, 0x69, 0x62, 0x6c, 0x69, 0x6e,
+	0x67, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64,
+	0x2e, 0x53, 0x69, 0x62, 0x6c, 0x69, 0x6e, 0x67, 0x52, 0x08, 0x73, 0x69, 0x62, 0x6c, 0x69, 0x6e,
+	0x67, 0x73, 0x12, 0x2f, 0x0a, 0x09, 0x74, 0x6f, 0x6d, 0x62, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x43, 0x6c,
+	0x6f, 0x63, 0x6b, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x09, 0x74, 0x6f, 0x6d, 0x62, 0x73, 0x74,
+	0x6f, 0x6e, 0x65, 0x12, 0x2b, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x18, 0x04,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x43, 0x6c, 0x6f,
+	0x63, 0x6b, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74,
+	0x22, 0x8b, 0x01, 0x0a, 0x02, 0x4b, 0x56, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12,
+	0x27, 0x0a, 0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11,
+	0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x52, 0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x34, 0x0a, 0x0b, 0x63, 0x6f, 0x6e, 0x73,
+	0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x12, 0x2e,
+	0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x43, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63,
+	0x79, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x22, 0x3a,
+	0x0a, 0x0a, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x4b, 0x65, 0x79, 0x12, 0x1a, 0x0a, 0x08,
+	0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08,
+	0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x22, 0xcd, 0x01, 0x0a, 0x08, 0x4c,
+	0x65, 0x61, 0x76, 0x65, 0x4d, 0x73, 0x67, 0x12, 0x1f, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f,
+	0x64, 0x65, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x2d, 0x0a, 0x0b, 0x70, 0x72, 0x65, 0x64,
+	0x65, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e,
+	0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x0b, 0x70, 0x72, 0x65, 0x64,
+	0x65, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x12, 0x29, 0x0a, 0x09, 0x73, 0x75, 0x63, 0x63, 0x65,
+	0x73, 0x73, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x68, 0x6f,
+	0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x09, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73,
+	0x6f, 0x72, 0x12, 0x1b, 0x0a, 0x03, 0x6b, 0x76, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x09, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x52, 0x03, 0x6b, 0x76, 0x73, 0x12,
+	0x29, 0x0a, 0x0a, 0x74, 0x6f, 0x6d, 0x62, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x73, 0x18, 0x05, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x52, 0x0a,
+	0x74, 0x6f, 0x6d, 0x62, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x73, 0x22, 0x4d, 0x0a, 0x03, 0x4b, 0x56,
+	0x73, 0x12, 0x1b, 0x0a, 0x03, 0x6b, 0x76, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x09,
+	0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x52, 0x03, 0x6b, 0x76, 0x73, 0x12, 0x29,
+	0x0a, 0x0a, 0x74, 0x6f, 0x6d, 0x62, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x09, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x52, 0x0a, 0x74,
+	0x6f, 0x6d, 0x62, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x73, 0x22, 0x40, 0x0a, 0x0a, 0x4d, 0x65, 0x72,
+	0x6b, 0x6c, 0x65, 0x54, 0x72, 0x65, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x61, 0x73, 0x68, 0x65, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x0c, 0x52, 0x06, 0x68, 0x61, 0x73, 0x68, 0x65, 0x73, 0x22, 0x6c, 0x0a, 0x0a, 0x4d,
+	0x65, 0x72, 0x6b, 0x6c, 0x65, 0x44, 0x69, 0x66, 0x66, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x61, 0x6e,
+	0x67, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0d, 0x52, 0x06, 0x72, 0x61, 0x6e, 0x67, 0x65,
+	0x73, 0x12, 0x1b, 0x0a, 0x03, 0x6b, 0x76, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x09,
+	0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x52, 0x03, 0x6b, 0x76, 0x73, 0x12, 0x29,
+	0x0a, 0x0a, 0x74, 0x6f, 0x6d, 0x62, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x09, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x52, 0x0a, 0x74,
+	0x6f, 0x6d, 0x62, 0x73, 0x74, 0x6f, 0x6e, 0x65, 0x73, 0x2a, 0x39, 0x0a, 0x0b, 0x4d, 0x65, 0x6d,
+	0x62, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x09, 0x0a, 0x05, 0x41, 0x4c, 0x49, 0x56,
+	0x45, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x55, 0x53, 0x50, 0x45, 0x43, 0x54, 0x10, 0x01,
+	0x12, 0x08, 0x0a, 0x04, 0x44, 0x45, 0x41, 0x44, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04, 0x4c, 0x45,
+	0x46, 0x54, 0x10, 0x03, 0x2a, 0x2b, 0x0a, 0x0b, 0x43, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65,
+	0x6e, 0x63, 0x79, 0x12, 0x07, 0x0a, 0x03, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06,
+	0x51, 0x55, 0x4f, 0x52, 0x55, 0x4d, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x41, 0x4c, 0x4c, 0x10,
+	0x02, 0x32, 0xa2, 0x08, 0x0a, 0x05, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x12, 0x2d, 0x0a, 0x0d, 0x46,
+	0x69, 0x6e, 0x64, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x12, 0x0d, 0x2e, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x49, 0x44, 0x1a, 0x0b, 0x2e, 0x63, 0x68,
+	0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x22, 0x00, 0x12, 0x2d, 0x0a, 0x0e, 0x47, 0x65,
+	0x74, 0x50, 0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x12, 0x0c, 0x2e, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x0b, 0x2e, 0x63, 0x68, 0x6f,
+	0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x22, 0x00, 0x12, 0x25, 0x0a, 0x06, 0x4e, 0x6f, 0x74,
+	0x69, 0x66, 0x79, 0x12, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65,
+	0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00,
+	0x12, 0x30, 0x0a, 0x10, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x50, 0x72, 0x65, 0x64, 0x65, 0x63, 0x65,
+	0x73, 0x73, 0x6f, 0x72, 0x12, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70,
+	0x74, 0x79, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79,
+	0x22, 0x00, 0x12, 0x24, 0x0a, 0x05, 0x50, 0x72, 0x6f, 0x62, 0x65, 0x12, 0x0b, 0x2e, 0x63, 0x68,
+	0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64,
+	0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x38, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x53,
+	0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x0c, 0x2e, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x14, 0x2e, 0x63, 0x68, 0x6f,
+	0x72, 0x64, 0x2e, 0x53, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x4c, 0x69, 0x73, 0x74,
+	0x22, 0x00, 0x12, 0x3b, 0x0a, 0x12, 0x52, 0x65, 0x63, 0x76, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69,
+	0x6e, 0x61, 0x74, 0x6f, 0x72, 0x4d, 0x73, 0x67, 0x12, 0x15, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64,
+	0x2e, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x6f, 0x72, 0x4d, 0x73, 0x67, 0x1a,
+	0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12,
+	0x2b, 0x0a, 0x07, 0x47, 0x65, 0x74, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x12, 0x2e, 0x63, 0x68, 0x6f,
+	0x72, 0x64, 0x2e, 0x4b, 0x65, 0x79, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0a,
+	0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x73, 0x22, 0x00, 0x12, 0x31, 0x0a, 0x0c,
+	0x53, 0x65, 0x6e, 0x64, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x73, 0x12, 0x11, 0x2e, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x4d, 0x73, 0x67, 0x1a,
+	0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12,
+	0x33, 0x0a, 0x0e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61,
+	0x73, 0x12, 0x11, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63,
+	0x61, 0x4d, 0x73, 0x67, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70,
+	0x74, 0x79, 0x22, 0x00, 0x12, 0x21, 0x0a, 0x03, 0x47, 0x65, 0x74, 0x12, 0x0a, 0x2e, 0x63, 0x68,
+	0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x65, 0x79, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x00, 0x12, 0x20, 0x0a, 0x03, 0x50, 0x75, 0x74, 0x12, 0x09,
+	0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x56, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72,
+	0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x24, 0x0a, 0x06, 0x44, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x12, 0x0a, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x65, 0x79, 0x1a,
+	0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12,
+	0x23, 0x0a, 0x06, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x12, 0x0a, 0x2e, 0x63, 0x68, 0x6f, 0x72,
+	0x64, 0x2e, 0x4b, 0x65, 0x79, 0x1a, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4e, 0x6f,
+	0x64, 0x65, 0x22, 0x00, 0x12, 0x2e, 0x0a, 0x0b, 0x4e, 0x6f, 0x74, 0x69, 0x66, 0x79, 0x4c, 0x65,
+	0x61, 0x76, 0x65, 0x12, 0x0f, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4c, 0x65, 0x61, 0x76,
+	0x65, 0x4d, 0x73, 0x67, 0x1a, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70,
+	0x74, 0x79, 0x22, 0x00, 0x12, 0x25, 0x0a, 0x05, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x12, 0x0c, 0x2e,
+	0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x0c, 0x2e, 0x63, 0x68,
+	0x6f, 0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x2f, 0x0a, 0x0a, 0x47,
+	0x65, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x12, 0x11, 0x2e, 0x63, 0x68, 0x6f, 0x72,
+	0x64, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x4b, 0x65, 0x79, 0x1a, 0x0c, 0x2e, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x00, 0x12, 0x36, 0x0a, 0x0c,
+	0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x73, 0x12, 0x11, 0x2e, 0x63,
+	0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x54, 0x72, 0x65, 0x65, 0x1a,
+	0x11, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x4d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x44, 0x69,
+	0x66, 0x66, 0x22, 0x00, 0x12, 0x36, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x69,
+	0x6e, 0x67, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x0c, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e,
+	0x65, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x13, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x52, 0x6f,
+	0x75, 0x74, 0x69, 0x6e, 0x67, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x22, 0x00, 0x12, 0x2e, 0x0a, 0x06,
+	0x47, 0x6f, 0x73, 0x73, 0x69, 0x70, 0x12, 0x10, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x47,
+	0x6f, 0x73, 0x73, 0x69, 0x70, 0x4d, 0x73, 0x67, 0x1a, 0x10, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64,
+	0x2e, 0x47, 0x6f, 0x73, 0x73, 0x69, 0x70, 0x4d, 0x73, 0x67, 0x22, 0x00, 0x12, 0x2c, 0x0a, 0x0a,
+	0x47, 0x65, 0x74, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x0c, 0x2e, 0x63, 0x68, 0x6f,
+	0x72, 0x64, 0x2e, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x0e, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64,
+	0x2e, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x22, 0x00, 0x12, 0x26, 0x0a, 0x07, 0x4e, 0x65,
+	0x78, 0x74, 0x48, 0x6f, 0x70, 0x12, 0x0d, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x50, 0x65,
+	0x65, 0x72, 0x49, 0x44, 0x1a, 0x0a, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e, 0x48, 0x6f, 0x70,
+	0x22, 0x00, 0x12, 0x22, 0x0a, 0x05, 0x54, 0x72, 0x61, 0x63, 0x65, 0x12, 0x0a, 0x2e, 0x63, 0x68,
+	0x6f, 0x72, 0x64, 0x2e, 0x4b, 0x65, 0x79, 0x1a, 0x0b, 0x2e, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2e,
+	0x50, 0x61, 0x74, 0x68, 0x22, 0x00, 0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x64, 0x65, 0x73, 0x69, 0x6e, 0x69, 0x6f, 0x74, 0x69, 0x73,
+	0x2f, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x2f, 0x63, 0x68, 0x6f, 0x72, 0x64, 0x70, 0x62, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescOnce sync.Once
+	file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescData = file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDesc
+)
+
+func file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescGZIP() []byte {
+	file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescOnce.Do(func() {
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescData = protoimpl.X.CompressGZIP(file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescData)
+	})
+	return file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDescData
+}
+
+var file_github_com_cdesiniotis_chord_chordpb_chord_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
+var file_github_com_cdesiniotis_chord_chordpb_chord_proto_goTypes = []interface{}{
+	(MemberState)(0),       // 0: chord.MemberState
+	(Consistency)(0),       // 1: chord.Consistency
+	(*Empty)(nil),          // 2: chord.empty
+	(*Node)(nil),           // 3: chord.Node
+	(*SuccessorList)(nil),  // 4: chord.SuccessorList
+	(*RoutingTable)(nil),   // 5: chord.RoutingTable
+	(*Member)(nil),         // 6: chord.Member
+	(*GossipMsg)(nil),      // 7: chord.GossipMsg
+	(*Members)(nil),        // 8: chord.Members
+	(*Hop)(nil),            // 9: chord.Hop
+	(*PathHop)(nil),        // 10: chord.PathHop
+	(*Path)(nil),           // 11: chord.Path
+	(*CoordinatorMsg)(nil), // 12: chord.CoordinatorMsg
+	(*ReplicaMsg)(nil),     // 13: chord.ReplicaMsg
+	(*PeerID)(nil),         // 14: chord.PeerID
+	(*KeysRequest)(nil),    // 15: chord.KeysRequest
+	(*KeyDigest)(nil),      // 16: chord.KeyDigest
+	(*Key)(nil),            // 17: chord.Key
+	(*ClockEntry)(nil),     // 18: chord.ClockEntry
+	(*Sibling)(nil),        // 19: chord.Sibling
+	(*Value)(nil),          // 20: chord.Value
+	(*KV)(nil),             // 21: chord.KV
+	(*ReplicaKey)(nil),     // 22: chord.ReplicaKey
+	(*LeaveMsg)(nil),       // 23: chord.LeaveMsg
+	(*KVs)(nil),            // 24: chord.KVs
+	(*MerkleTree)(nil),     // 25: chord.MerkleTree
+	(*MerkleDiff)(nil),     // 26: chord.MerkleDiff
+}
+var file_github_com_cdesiniotis_chord_chordpb_chord_proto_depIdxs = []int32{
+	3,  // 0: chord.SuccessorList.successors:type_name -> chord.Node
+	3,  // 1: chord.RoutingTable.node:type_name -> chord.Node
+	3,  // 2: chord.RoutingTable.predecessor:type_name -> chord.Node
+	3,  // 3: chord.RoutingTable.successors:type_name -> chord.Node
+	3,  // 4: chord.RoutingTable.fingers:type_name -> chord.Node
+	3,  // 5: chord.Member.node:type_name -> chord.Node
+	0,  // 6: chord.Member.state:type_name -> chord.MemberState
+	6,  // 7: chord.GossipMsg.members:type_name -> chord.Member
+	6,  // 8: chord.Members.members:type_name -> chord.Member
+	3,  // 9: chord.Hop.node:type_name -> chord.Node
+	3,  // 10: chord.Hop.alternates:type_name -> chord.Node
+	3,  // 11: chord.PathHop.node:type_name -> chord.Node
+	10, // 12: chord.Path.hops:type_name -> chord.PathHop
+	3,  // 13: chord.Path.successor:type_name -> chord.Node
+	21, // 14: chord.ReplicaMsg.kv:type_name -> chord.KV
+	16, // 15: chord.KeysRequest.have:type_name -> chord.KeyDigest
+	1,  // 16: chord.Key.consistency:type_name -> chord.Consistency
+	18, // 17: chord.Sibling.clock:type_name -> chord.ClockEntry
+	19, // 18: chord.Value.siblings:type_name -> chord.Sibling
+	18, // 19: chord.Value.tombstone:type_name -> chord.ClockEntry
+	18, // 20: chord.Value.context:type_name -> chord.ClockEntry
+	18, // 21: chord.KV.clock:type_name -> chord.ClockEntry
+	1,  // 22: chord.KV.consistency:type_name -> chord.Consistency
+	3,  // 23: chord.LeaveMsg.node:type_name -> chord.Node
+	3,  // 24: chord.LeaveMsg.predecessor:type_name -> chord.Node
+	3,  // 25: chord.LeaveMsg.successor:type_name -> chord.Node
+	21, // 26: chord.LeaveMsg.kvs:type_name -> chord.KV
+	21, // 27: chord.LeaveMsg.tombstones:type_name -> chord.KV
+	21, // 28: chord.KVs.kvs:type_name -> chord.KV
+	21, // 29: chord.KVs.tombstones:type_name -> chord.KV
+	21, // 30: chord.MerkleDiff.kvs:type_name -> chord.KV
+	21, // 31: chord.MerkleDiff.tombstones:type_name -> chord.KV
+	14, // 32: chord.chord.FindSuccessor:input_type -> chord.PeerID
+	2,  // 33: chord.chord.GetPredecessor:input_type -> chord.empty
+	3,  // 34: chord.chord.Notify:input_type -> chord.Node
+	2,  // 35: chord.chord.CheckPredecessor:input_type -> chord.empty
+	3,  // 36: chord.chord.Probe:input_type -> chord.Node
+	2,  // 37: chord.chord.GetSuccessorList:input_type -> chord.empty
+	12, // 38: chord.chord.RecvCoordinatorMsg:input_type -> chord.CoordinatorMsg
+	15, // 39: chord.chord.GetKeys:input_type -> chord.KeysRequest
+	13, // 40: chord.chord.SendReplicas:input_type -> chord.ReplicaMsg
+	13, // 41: chord.chord.RemoveReplicas:input_type -> chord.ReplicaMsg
+	17, // 42: chord.chord.Get:input_type -> chord.Key
+	21, // 43: chord.chord.Put:input_type -> chord.KV
+	17, // 44: chord.chord.Delete:input_type -> chord.Key
+	17, // 45: chord.chord.Locate:input_type -> chord.Key
+	23, // 46: chord.chord.NotifyLeave:input_type -> chord.LeaveMsg
+	2,  // 47: chord.chord.Leave:input_type -> chord.empty
+	22, // 48: chord.chord.GetReplica:input_type -> chord.ReplicaKey
+	25, // 49: chord.chord.SyncReplicas:input_type -> chord.MerkleTree
+	2,  // 50: chord.chord.GetRoutingTable:input_type -> chord.empty
+	7,  // 51: chord.chord.Gossip:input_type -> chord.GossipMsg
+	2,  // 52: chord.chord.GetMembers:input_type -> chord.empty
+	14, // 53: chord.chord.NextHop:input_type -> chord.PeerID
+	17, // 54: chord.chord.Trace:input_type -> chord.Key
+	3,  // 55: chord.chord.FindSuccessor:output_type -> chord.Node
+	3,  // 56: chord.chord.GetPredecessor:output_type -> chord.Node
+	2,  // 57: chord.chord.Notify:output_type -> chord.empty
+	2,  // 58: chord.chord.CheckPredecessor:output_type -> chord.empty
+	2,  // 59: chord.chord.Probe:output_type -> chord.empty
+	4,  // 60: chord.chord.GetSuccessorList:output_type -> chord.SuccessorList
+	2,  // 61: chord.chord.RecvCoordinatorMsg:output_type -> chord.empty
+	24, // 62: chord.chord.GetKeys:output_type -> chord.KVs
+	2,  // 63: chord.chord.SendReplicas:output_type -> chord.empty
+	2,  // 64: chord.chord.RemoveReplicas:output_type -> chord.empty
+	20, // 65: chord.chord.Get:output_type -> chord.Value
+	2,  // 66: chord.chord.Put:output_type -> chord.empty
+	2,  // 67: chord.chord.Delete:output_type -> chord.empty
+	3,  // 68: chord.chord.Locate:output_type -> chord.Node
+	2,  // 69: chord.chord.NotifyLeave:output_type -> chord.empty
+	2,  // 70: chord.chord.Leave:output_type -> chord.empty
+	20, // 71: chord.chord.GetReplica:output_type -> chord.Value
+	26, // 72: chord.chord.SyncReplicas:output_type -> chord.MerkleDiff
+	5,  // 73: chord.chord.GetRoutingTable:output_type -> chord.RoutingTable
+	7,  // 74: chord.chord.Gossip:output_type -> chord.GossipMsg
+	8,  // 75: chord.chord.GetMembers:output_type -> chord.Members
+	9,  // 76: chord.chord.NextHop:output_type -> chord.Hop
+	11, // 77: chord.chord.Trace:output_type -> chord.Path
+	55, // [55:78] is the sub-list for method output_type
+	32, // [32:55] is the sub-list for method input_type
+	32, // [32:32] is the sub-list for extension type_name
+	32, // [32:32] is the sub-list for extension extendee
+	0,  // [0:32] is the sub-list for field type_name
+}
+
+func init() { file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() }
+func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
+	if File_github_com_cdesiniotis_chord_chordpb_chord_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Empty); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -709,7 +2008,7 @@ func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
 			}
 		}
 		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CoordinatorMsg); i {
+			switch v := v.(*RoutingTable); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -721,7 +2020,7 @@ func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
 			}
 		}
 		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ReplicaMsg); i {
+			switch v := v.(*Member); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -733,7 +2032,7 @@ func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
 			}
 		}
 		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PeerID); i {
+			switch v := v.(*GossipMsg); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -745,7 +2044,7 @@ func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
 			}
 		}
 		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Key); i {
+			switch v := v.(*Members); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -757,7 +2056,7 @@ func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
 			}
 		}
 		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Value); i {
+			switch v := v.(*Hop); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -769,7 +2068,7 @@ func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
 			}
 		}
 		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*KV); i {
+			switch v := v.(*PathHop); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -781,6 +2080,162 @@ func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
 			}
 		}
 		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Path); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CoordinatorMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplicaMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerID); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*KeysRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*KeyDigest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Key); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClockEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Sibling); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Value); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*KV); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplicaKey); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LeaveMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*KVs); i {
 			case 0:
 				return &v.state
@@ -792,19 +2247,44 @@ func file_github_com_cdesiniotis_chord_chordpb_chord_proto_init() {
 				return nil
 			}
 		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MerkleTree); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MerkleDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_github_com_cdesiniotis_chord_chordpb_chord_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   10,
+			NumEnums:      2,
+			NumMessages:   25,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_github_com_cdesiniotis_chord_chordpb_chord_proto_goTypes,
 		DependencyIndexes: file_github_com_cdesiniotis_chord_chordpb_chord_proto_depIdxs,
+		EnumInfos:         file_github_com_cdesiniotis_chord_chordpb_chord_proto_enumTypes,
 		MessageInfos:      file_github_com_cdesiniotis_chord_chordpb_chord_proto_msgTypes,
 	}.Build()
 	File_github_com_cdesiniotis_chord_chordpb_chord_proto = out.File
@@ -833,13 +2313,17 @@ type ChordClient interface {
 	Notify(ctx context.Context, in *Node, opts ...grpc.CallOption) (*Empty, error)
 	// Check if predecessor is still alive
 	CheckPredecessor(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	// Check if another node is alive on behalf of a node which can not
+	// reach it, before that node declares it failed
+	Probe(ctx context.Context, in *Node, opts ...grpc.CallOption) (*Empty, error)
 	// Get successor list of a node
 	GetSuccessorList(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*SuccessorList, error)
-	// Receive coordinator messages from nodes who are the cooridinators
+	// TODO: consider changing the names of the below RPCs for replicas. They are not very clear
+	// Receive coordinator messages from nodes who are the coordinators
 	// for replica groups around the chord ring
 	RecvCoordinatorMsg(ctx context.Context, in *CoordinatorMsg, opts ...grpc.CallOption) (*Empty, error)
 	// Get keys we are responsible for from a node (typically a new node calls this on their successor)
-	GetKeys(ctx context.Context, in *PeerID, opts ...grpc.CallOption) (*KVs, error)
+	GetKeys(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*KVs, error)
 	// Receive replica KV pairs from the leader of the replica group
 	SendReplicas(ctx context.Context, in *ReplicaMsg, opts ...grpc.CallOption) (*Empty, error)
 	// Remove replica KV pairs
@@ -848,8 +2332,31 @@ type ChordClient interface {
 	Get(ctx context.Context, in *Key, opts ...grpc.CallOption) (*Value, error)
 	// Create a new key-value pair
 	Put(ctx context.Context, in *KV, opts ...grpc.CallOption) (*Empty, error)
+	// Delete a key-value pair
+	Delete(ctx context.Context, in *Key, opts ...grpc.CallOption) (*Empty, error)
 	// Locate the node containing a key
 	Locate(ctx context.Context, in *Key, opts ...grpc.CallOption) (*Node, error)
+	// A node is leaving the ring and handing over its keys and pointers
+	NotifyLeave(ctx context.Context, in *LeaveMsg, opts ...grpc.CallOption) (*Empty, error)
+	// Ask a node to gracefully leave the ring and shut down
+	Leave(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	// Get the replica of a key held for a replica group leader
+	GetReplica(ctx context.Context, in *ReplicaKey, opts ...grpc.CallOption) (*Value, error)
+	// Compare the Merkle tree of a replica group with ours, returning our
+	// versions of the keys in the ranges that differ
+	SyncReplicas(ctx context.Context, in *MerkleTree, opts ...grpc.CallOption) (*MerkleDiff, error)
+	// Get the neighbours and fingers of a node, so that clients can route
+	// requests to the node responsible for a key themselves
+	GetRoutingTable(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RoutingTable, error)
+	// Exchange membership updates with another process, see GossipMsg
+	Gossip(ctx context.Context, in *GossipMsg, opts ...grpc.CallOption) (*GossipMsg, error)
+	// Get the members of the ring known to a node through gossip
+	GetMembers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Members, error)
+	// Get the next node to ask for the successor of the given ID, for
+	// lookups the caller routes itself, see Hop
+	NextHop(ctx context.Context, in *PeerID, opts ...grpc.CallOption) (*Hop, error)
+	// Locate the node containing a key iteratively, returning every node asked
+	Trace(ctx context.Context, in *Key, opts ...grpc.CallOption) (*Path, error)
 }
 
 type chordClient struct {
@@ -896,6 +2403,15 @@ func (c *chordClient) CheckPredecessor(ctx context.Context, in *Empty, opts ...g
 	return out, nil
 }
 
+func (c *chordClient) Probe(ctx context.Context, in *Node, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/chord.chord/Probe", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *chordClient) GetSuccessorList(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*SuccessorList, error) {
 	out := new(SuccessorList)
 	err := c.cc.Invoke(ctx, "/chord.chord/GetSuccessorList", in, out, opts...)
@@ -914,7 +2430,7 @@ func (c *chordClient) RecvCoordinatorMsg(ctx context.Context, in *CoordinatorMsg
 	return out, nil
 }
 
-func (c *chordClient) GetKeys(ctx context.Context, in *PeerID, opts ...grpc.CallOption) (*KVs, error) {
+func (c *chordClient) GetKeys(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*KVs, error) {
 	out := new(KVs)
 	err := c.cc.Invoke(ctx, "/chord.chord/GetKeys", in, out, opts...)
 	if err != nil {
@@ -959,6 +2475,15 @@ func (c *chordClient) Put(ctx context.Context, in *KV, opts ...grpc.CallOption)
 	return out, nil
 }
 
+func (c *chordClient) Delete(ctx context.Context, in *Key, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/chord.chord/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *chordClient) Locate(ctx context.Context, in *Key, opts ...grpc.CallOption) (*Node, error) {
 	out := new(Node)
 	err := c.cc.Invoke(ctx, "/chord.chord/Locate", in, out, opts...)
@@ -968,6 +2493,87 @@ func (c *chordClient) Locate(ctx context.Context, in *Key, opts ...grpc.CallOpti
 	return out, nil
 }
 
+func (c *chordClient) NotifyLeave(ctx context.Context, in *LeaveMsg, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/chord.chord/NotifyLeave", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chordClient) Leave(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/chord.chord/Leave", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chordClient) GetReplica(ctx context.Context, in *ReplicaKey, opts ...grpc.CallOption) (*Value, error) {
+	out := new(Value)
+	err := c.cc.Invoke(ctx, "/chord.chord/GetReplica", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chordClient) SyncReplicas(ctx context.Context, in *MerkleTree, opts ...grpc.CallOption) (*MerkleDiff, error) {
+	out := new(MerkleDiff)
+	err := c.cc.Invoke(ctx, "/chord.chord/SyncReplicas", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chordClient) GetRoutingTable(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RoutingTable, error) {
+	out := new(RoutingTable)
+	err := c.cc.Invoke(ctx, "/chord.chord/GetRoutingTable", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chordClient) Gossip(ctx context.Context, in *GossipMsg, opts ...grpc.CallOption) (*GossipMsg, error) {
+	out := new(GossipMsg)
+	err := c.cc.Invoke(ctx, "/chord.chord/Gossip", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chordClient) GetMembers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Members, error) {
+	out := new(Members)
+	err := c.cc.Invoke(ctx, "/chord.chord/GetMembers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chordClient) NextHop(ctx context.Context, in *PeerID, opts ...grpc.CallOption) (*Hop, error) {
+	out := new(Hop)
+	err := c.cc.Invoke(ctx, "/chord.chord/NextHop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chordClient) Trace(ctx context.Context, in *Key, opts ...grpc.CallOption) (*Path, error) {
+	out := new(Path)
+	err := c.cc.Invoke(ctx, "/chord.chord/Trace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ChordServer is the server API for Chord service.
 type ChordServer interface {
 	// Find the successor of the given ID
@@ -978,13 +2584,17 @@ type ChordServer interface {
 	Notify(context.Context, *Node) (*Empty, error)
 	// Check if predecessor is still alive
 	CheckPredecessor(context.Context, *Empty) (*Empty, error)
+	// Check if another node is alive on behalf of a node which can not
+	// reach it, before that node declares it failed
+	Probe(context.Context, *Node) (*Empty, error)
 	// Get successor list of a node
 	GetSuccessorList(context.Context, *Empty) (*SuccessorList, error)
-	// Receive coordinator messages from nodes who are the cooridinators
+	// TODO: consider changing the names of the below RPCs for replicas. They are not very clear
+	// Receive coordinator messages from nodes who are the coordinators
 	// for replica groups around the chord ring
 	RecvCoordinatorMsg(context.Context, *CoordinatorMsg) (*Empty, error)
 	// Get keys we are responsible for from a node (typically a new node calls this on their successor)
-	GetKeys(context.Context, *PeerID) (*KVs, error)
+	GetKeys(context.Context, *KeysRequest) (*KVs, error)
 	// Receive replica KV pairs from the leader of the replica group
 	SendReplicas(context.Context, *ReplicaMsg) (*Empty, error)
 	// Remove replica KV pairs
@@ -993,8 +2603,31 @@ type ChordServer interface {
 	Get(context.Context, *Key) (*Value, error)
 	// Create a new key-value pair
 	Put(context.Context, *KV) (*Empty, error)
+	// Delete a key-value pair
+	Delete(context.Context, *Key) (*Empty, error)
 	// Locate the node containing a key
 	Locate(context.Context, *Key) (*Node, error)
+	// A node is leaving the ring and handing over its keys and pointers
+	NotifyLeave(context.Context, *LeaveMsg) (*Empty, error)
+	// Ask a node to gracefully leave the ring and shut down
+	Leave(context.Context, *Empty) (*Empty, error)
+	// Get the replica of a key held for a replica group leader
+	GetReplica(context.Context, *ReplicaKey) (*Value, error)
+	// Compare the Merkle tree of a replica group with ours, returning our
+	// versions of the keys in the ranges that differ
+	SyncReplicas(context.Context, *MerkleTree) (*MerkleDiff, error)
+	// Get the neighbours and fingers of a node, so that clients can route
+	// requests to the node responsible for a key themselves
+	GetRoutingTable(context.Context, *Empty) (*RoutingTable, error)
+	// Exchange membership updates with another process, see GossipMsg
+	Gossip(context.Context, *GossipMsg) (*GossipMsg, error)
+	// Get the members of the ring known to a node through gossip
+	GetMembers(context.Context, *Empty) (*Members, error)
+	// Get the next node to ask for the successor of the given ID, for
+	// lookups the caller routes itself, see Hop
+	NextHop(context.Context, *PeerID) (*Hop, error)
+	// Locate the node containing a key iteratively, returning every node asked
+	Trace(context.Context, *Key) (*Path, error)
 }
 
 // UnimplementedChordServer can be embedded to have forward compatible implementations.
@@ -1013,13 +2646,16 @@ func (*UnimplementedChordServer) Notify(context.Context, *Node) (*Empty, error)
 func (*UnimplementedChordServer) CheckPredecessor(context.Context, *Empty) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckPredecessor not implemented")
 }
+func (*UnimplementedChordServer) Probe(context.Context, *Node) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Probe not implemented")
+}
 func (*UnimplementedChordServer) GetSuccessorList(context.Context, *Empty) (*SuccessorList, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetSuccessorList not implemented")
 }
 func (*UnimplementedChordServer) RecvCoordinatorMsg(context.Context, *CoordinatorMsg) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RecvCoordinatorMsg not implemented")
 }
-func (*UnimplementedChordServer) GetKeys(context.Context, *PeerID) (*KVs, error) {
+func (*UnimplementedChordServer) GetKeys(context.Context, *KeysRequest) (*KVs, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetKeys not implemented")
 }
 func (*UnimplementedChordServer) SendReplicas(context.Context, *ReplicaMsg) (*Empty, error) {
@@ -1034,9 +2670,39 @@ func (*UnimplementedChordServer) Get(context.Context, *Key) (*Value, error) {
 func (*UnimplementedChordServer) Put(context.Context, *KV) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
 }
+func (*UnimplementedChordServer) Delete(context.Context, *Key) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
 func (*UnimplementedChordServer) Locate(context.Context, *Key) (*Node, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Locate not implemented")
 }
+func (*UnimplementedChordServer) NotifyLeave(context.Context, *LeaveMsg) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NotifyLeave not implemented")
+}
+func (*UnimplementedChordServer) Leave(context.Context, *Empty) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Leave not implemented")
+}
+func (*UnimplementedChordServer) GetReplica(context.Context, *ReplicaKey) (*Value, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReplica not implemented")
+}
+func (*UnimplementedChordServer) SyncReplicas(context.Context, *MerkleTree) (*MerkleDiff, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncReplicas not implemented")
+}
+func (*UnimplementedChordServer) GetRoutingTable(context.Context, *Empty) (*RoutingTable, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoutingTable not implemented")
+}
+func (*UnimplementedChordServer) Gossip(context.Context, *GossipMsg) (*GossipMsg, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Gossip not implemented")
+}
+func (*UnimplementedChordServer) GetMembers(context.Context, *Empty) (*Members, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMembers not implemented")
+}
+func (*UnimplementedChordServer) NextHop(context.Context, *PeerID) (*Hop, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NextHop not implemented")
+}
+func (*UnimplementedChordServer) Trace(context.Context, *Key) (*Path, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Trace not implemented")
+}
 
 func RegisterChordServer(s *grpc.Server, srv ChordServer) {
 	s.RegisterService(&_Chord_serviceDesc, srv)
@@ -1114,6 +2780,24 @@ func _Chord_CheckPredecessor_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Chord_Probe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Node)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).Probe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/Probe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).Probe(ctx, req.(*Node))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Chord_GetSuccessorList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Empty)
 	if err := dec(in); err != nil {
@@ -1151,7 +2835,7 @@ func _Chord_RecvCoordinatorMsg_Handler(srv interface{}, ctx context.Context, dec
 }
 
 func _Chord_GetKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PeerID)
+	in := new(KeysRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -1163,7 +2847,7 @@ func _Chord_GetKeys_Handler(srv interface{}, ctx context.Context, dec func(inter
 		FullMethod: "/chord.chord/GetKeys",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChordServer).GetKeys(ctx, req.(*PeerID))
+		return srv.(ChordServer).GetKeys(ctx, req.(*KeysRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -1240,6 +2924,24 @@ func _Chord_Put_Handler(srv interface{}, ctx context.Context, dec func(interface
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Chord_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Key)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).Delete(ctx, req.(*Key))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Chord_Locate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Key)
 	if err := dec(in); err != nil {
@@ -1258,6 +2960,168 @@ func _Chord_Locate_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Chord_NotifyLeave_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaveMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).NotifyLeave(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/NotifyLeave",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).NotifyLeave(ctx, req.(*LeaveMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chord_Leave_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).Leave(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/Leave",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).Leave(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chord_GetReplica_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplicaKey)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).GetReplica(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/GetReplica",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).GetReplica(ctx, req.(*ReplicaKey))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chord_SyncReplicas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MerkleTree)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).SyncReplicas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/SyncReplicas",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).SyncReplicas(ctx, req.(*MerkleTree))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chord_GetRoutingTable_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).GetRoutingTable(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/GetRoutingTable",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).GetRoutingTable(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chord_Gossip_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GossipMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).Gossip(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/Gossip",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).Gossip(ctx, req.(*GossipMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chord_GetMembers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).GetMembers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/GetMembers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).GetMembers(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chord_NextHop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeerID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).NextHop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/NextHop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).NextHop(ctx, req.(*PeerID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chord_Trace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Key)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChordServer).Trace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chord.chord/Trace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChordServer).Trace(ctx, req.(*Key))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Chord_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "chord.chord",
 	HandlerType: (*ChordServer)(nil),
@@ -1278,6 +3142,10 @@ var _Chord_serviceDesc = grpc.ServiceDesc{
 			MethodName: "CheckPredecessor",
 			Handler:    _Chord_CheckPredecessor_Handler,
 		},
+		{
+			MethodName: "Probe",
+			Handler:    _Chord_Probe_Handler,
+		},
 		{
 			MethodName: "GetSuccessorList",
 			Handler:    _Chord_GetSuccessorList_Handler,
@@ -1306,10 +3174,50 @@ var _Chord_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Put",
 			Handler:    _Chord_Put_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _Chord_Delete_Handler,
+		},
 		{
 			MethodName: "Locate",
 			Handler:    _Chord_Locate_Handler,
 		},
+		{
+			MethodName: "NotifyLeave",
+			Handler:    _Chord_NotifyLeave_Handler,
+		},
+		{
+			MethodName: "Leave",
+			Handler:    _Chord_Leave_Handler,
+		},
+		{
+			MethodName: "GetReplica",
+			Handler:    _Chord_GetReplica_Handler,
+		},
+		{
+			MethodName: "SyncReplicas",
+			Handler:    _Chord_SyncReplicas_Handler,
+		},
+		{
+			MethodName: "GetRoutingTable",
+			Handler:    _Chord_GetRoutingTable_Handler,
+		},
+		{
+			MethodName: "Gossip",
+			Handler:    _Chord_Gossip_Handler,
+		},
+		{
+			MethodName: "GetMembers",
+			Handler:    _Chord_GetMembers_Handler,
+		},
+		{
+			MethodName: "NextHop",
+			Handler:    _Chord_NextHop_Handler,
+		},
+		{
+			MethodName: "Trace",
+			Handler:    _Chord_Trace_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "github.com/cdesiniotis/chord/chordpb/chord.proto",
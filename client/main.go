@@ -2,74 +2,42 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"github.com/cdesiniotis/chord"
+	"github.com/cdesiniotis/chord/chordclient"
 	"github.com/cdesiniotis/chord/chordpb"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"google.golang.org/grpc"
 	"time"
 )
 
-func GetChordClient(addr string) (chordpb.ChordClient, error) {
-	//ctx, cancel := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// every command shares one client, its context deadline bounds the whole command
+// including retries on other seeds
+const commandTimeout = 10 * time.Second
 
-	dialOpts := make([]grpc.DialOption, 0, 5)
-	dialOpts = append(dialOpts, grpc.WithInsecure(), grpc.WithBlock(), grpc.FailOnNonTempDialError(true))
-	conn, err := grpc.DialContext(ctx, addr, dialOpts...)
-	//conn, err := grpc.Dial(target, n.grpcOpts.dialOpts...)
-	if err != nil {
-		return nil, err
+func newClient(v *viper.Viper) (*chordclient.Client, error) {
+	seeds := v.GetStringSlice("seeds")
+	if len(seeds) == 0 {
+		seeds = []string{v.GetString("addr")}
 	}
-
-	client := chordpb.NewChordClient(conn)
-	return client, nil
-}
-
-func Get(contact string, key string) (*chordpb.Value, error) {
-	cc, err := GetChordClient(contact)
-	if err != nil {
-		//log.Fatalf("error dialing %s\n", contact)
-		return nil, errors.New(fmt.Sprintf("error dialing %s - %s\n", contact, err))
+	cfg := chordclient.DefaultConfig(seeds...)
+	cfg.Token = v.GetString("token")
+	// learning the ring costs more than the proxy hop it saves for a single command
+	cfg.SmartRouting = v.GetBool("smartrouting")
+	if v.GetString("tlscafile") != "" || v.GetString("tlscertfile") != "" || v.GetString("tlskeyfile") != "" {
+		creds, err := chord.NewTLSCredentials(v.GetString("tlscafile"), v.GetString("tlscertfile"), v.GetString("tlskeyfile"))
+		if err != nil {
+			return nil, fmt.Errorf("error configuring TLS: %v", err)
+		}
+		cfg.Creds = creds
 	}
-
-	req := &chordpb.Key{Key: key}
-
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	val, err := cc.Get(ctx, req)
-	return val, err
-}
-
-func Put(contact string, key string, val []byte) error {
-	cc, err := GetChordClient(contact)
-	if err != nil {
-		//log.Fatalf("error dialing %s\n", contact)
-		return errors.New(fmt.Sprintf("error dialing %s - %s\n", contact, err))
-	}
-
-	req := &chordpb.KV{Key: key, Value: val}
-
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	_, err = cc.Put(ctx, req)
-	return err
+	return chordclient.New(cfg)
 }
 
-func Locate(contact string, key string) (*chordpb.Node, error) {
-	cc, err := GetChordClient(contact)
-	if err != nil {
-		//log.Fatalf("error dialing %s\n", contact)
-		return nil, errors.New(fmt.Sprintf("error dialing %s - %s\n", contact, err))
-	}
-
-	req := &chordpb.Key{Key: key}
-
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	node, err := cc.Locate(ctx, req)
-	return node, err
+func consistencyFlag(cmd *cobra.Command) (chordpb.Consistency, error) {
+	level, _ := cmd.Flags().GetString("consistency")
+	return chord.ParseConsistency(level)
 }
 
 func readConfig(filename string, defaults map[string]interface{}) (*viper.Viper, error) {
@@ -88,6 +56,12 @@ func readConfig(filename string, defaults map[string]interface{}) (*viper.Viper,
 func defaults() map[string]interface{} {
 	return map[string]interface{}{
 		"addr":	"0.0.0.0:8001",
+		"seeds":       []string{},
+		"tlscafile":   "",
+		"tlscertfile": "",
+		"tlskeyfile":  "",
+		"token":       "",
+		"smartrouting": false,
 	}
 }
 
@@ -97,7 +71,13 @@ func main() {
 	if err != nil {
 		log.Fatalf("error when reading config: %v\n", err)
 	}
-	contact := v.GetString("addr")
+	client, err := newClient(v)
+	if err != nil {
+		log.Fatalf("error creating client: %v\n", err)
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
 
 	var cmdPut = &cobra.Command{
 		Use:   "put [key] [value]",
@@ -107,7 +87,11 @@ func main() {
 		Run: func(cmd *cobra.Command, args []string) {
 			key := args[0]
 			val := []byte(args[1])
-			err := Put(contact, key, val)
+			consistency, err := consistencyFlag(cmd)
+			if err != nil {
+				log.Fatalf("%s\n", err)
+			}
+			err = client.Put(ctx, key, val, consistency)
 			if err != nil {
 				log.Fatalf("error calling Put(k,v): %s\n", err)
 			}
@@ -122,14 +106,41 @@ func main() {
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			key := args[0]
-			val, err := Get(contact, key)
+			consistency, err := consistencyFlag(cmd)
+			if err != nil {
+				log.Fatalf("%s\n", err)
+			}
+			val, err := client.Get(ctx, key, consistency)
 			if err != nil {
 				log.Fatalf("error calling Get(k): %s\n", err)
 			}
+			if len(val.Siblings) > 1 {
+				// concurrent writes, a put of the resolved value replaces them all
+				log.Infof("%s has %d concurrent versions:", key, len(val.Siblings))
+				for i, s := range val.Siblings {
+					log.Infof("  [%d] %s", i, string(s.Value))
+				}
+				return
+			}
 			log.Infof("%s --> %s", key, string(val.Value))
 		},
 	}
 
+	var cmdDelete = &cobra.Command{
+		Use:   "delete [key]",
+		Short: "Delete a key from the dht",
+		Long:  `delete is for removing a key-value pair from the distributed hash table`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key := args[0]
+			err := client.Delete(ctx, key)
+			if err != nil {
+				log.Fatalf("error calling Delete(k): %s\n", err)
+			}
+			log.Infof("deleted key: %s from datastore\n", key)
+		},
+	}
+
 	var cmdLocate = &cobra.Command{
 		Use:   "locate [key]",
 		Short: "Locate the node responsible for a key",
@@ -137,7 +148,7 @@ func main() {
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			key := args[0]
-			node, err := Locate(contact, key)
+			node, err := client.Locate(ctx, key)
 			if err != nil {
 				log.Fatalf("error calling Locate(k): %s\n", err)
 			}
@@ -145,7 +156,52 @@ func main() {
 		},
 	}
 
+	var cmdTrace = &cobra.Command{
+		Use:   "trace [key]",
+		Short: "Trace the lookup of a key",
+		Long:  `trace is for listing the nodes asked, one after the other, while locating the node responsible for a key, along with the latency of each`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key := args[0]
+			path, err := client.Trace(ctx, key)
+			if err != nil {
+				log.Fatalf("error calling Trace(k): %s\n", err)
+			}
+			for i, hop := range path.Hops {
+				latency := time.Duration(hop.Latency).Round(time.Microsecond)
+				if hop.Error != "" {
+					fmt.Printf("%d\t%x\t%s:%d\t%v\t%s\n", i, hop.Node.Id, hop.Node.Addr, hop.Node.Port, latency, hop.Error)
+					continue
+				}
+				fmt.Printf("%d\t%x\t%s:%d\t%v\n", i, hop.Node.Id, hop.Node.Addr, hop.Node.Port, latency)
+			}
+			if path.Successor == nil {
+				log.Fatalf("no route to the node responsible for %s\n", key)
+			}
+			chord.PrintNode(path.Successor, false, "Node storing key")
+		},
+	}
+
+	var cmdMembers = &cobra.Command{
+		Use:   "members",
+		Short: "List the members of the ring",
+		Long:  `members is for listing the nodes a node of the chord ring knows of through gossip, and whether they are alive`,
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			members, err := client.Members(ctx)
+			if err != nil {
+				log.Fatalf("error calling Members(): %s\n", err)
+			}
+			for _, m := range members {
+				fmt.Printf("%x\t%s:%d\t%s\t%d\n", m.Node.Id, m.Node.Addr, m.Node.Port, m.State, m.Incarnation)
+			}
+		},
+	}
+
+	cmdPut.Flags().String("consistency", "one", "Replicas that must acknowledge the write (one, quorum, all)")
+	cmdGet.Flags().String("consistency", "one", "Replicas that must answer the read (one, quorum, all)")
+
 	var rootCmd = &cobra.Command{Use: "chord"}
-	rootCmd.AddCommand(cmdGet, cmdPut, cmdLocate)
+	rootCmd.AddCommand(cmdGet, cmdPut, cmdDelete, cmdLocate, cmdTrace, cmdMembers)
 	rootCmd.Execute()
 }
@@ -1,6 +1,9 @@
 package chord
 
 import (
+	"io/ioutil"
+
+	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 )
 
@@ -12,6 +15,19 @@ type Config struct {
 	Timeout    int // in ms
 	ServerOpts []grpc.ServerOption
 	DialOpts   []grpc.DialOption
+	Transport  Transport // nil for gRPC over TCP, using ServerOpts and DialOpts
+	Faults     *Faults   // failures injected into our RPCs to other nodes, nil for none
+
+	// Mutual TLS between ring members, see ConfigureTLS
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Authorization, see authorizer. Internal RPCs require the ring secret or a
+	// certificate for one of the peer names, client RPCs require an API token
+	RingSecret string
+	PeerNames  []string
+	APITokens  []APIToken
 
 	StabilizeInterval        int // in ms
 	FixFingerInterval        int // in ms
@@ -19,7 +35,48 @@ type Config struct {
 
 	SuccessorListSize int
 
+	// Nodes kept per finger table interval, lookups being routed to the one
+	// with the lowest round-trip time, see proximity.go. 1 disables it
+	FingerCandidates int
+
+	// Lookups are forwarded from node to node unless IterativeLookup is set,
+	// the origin then calls every hop itself, see lookupIterative
+	IterativeLookup bool
+	HopTimeout      int // in ms, per hop of iterative lookups and traces, 0 for Timeout
+
+	// Failure detection of the predecessor, see failureDetector. It is
+	// suspected once the phi of its silence exceeds FailureThreshold, then
+	// declared failed unless one of IndirectProbes successors reaches it
+	FailureThreshold float64
+	FailureWindow    int // heartbeat intervals remembered per peer
+	IndirectProbes   int // 0 declares a suspected predecessor failed right away
+
+	// Gossip of joins, failures and departures between processes, see
+	// membership. A member suspected for SuspicionTimeout is declared dead
+	GossipInterval   int // in ms, 0 disables gossip
+	GossipFanout     int // processes gossiped with every round
+	SuspicionTimeout int // in ms
+
+	// Merging of the rings a partition split the ring into, see checkPartition
+	MergeInterval int // in ms, between probes of remembered members, 0 disables merging
+
 	Logging 	bool
+	Logger  log.FieldLogger // defaults to a logger of our own, silent unless Logging is set
+
+	DataDir          string // empty disables persistence
+	SnapshotInterval int    // in ms
+	StorageEngine    string // StorageMemory or StorageLog
+
+	AntiEntropyInterval int // in ms, 0 disables anti-entropy
+	TombstoneTTL        int // in ms, deleted keys are forgotten after it by anti-entropy, 0 keeps them forever
+
+	VirtualNodes int // number of ring positions served by this process
+
+	EnableMetrics    bool
+	MetricsAddr      string // HTTP address of the metrics endpoint, required if EnableMetrics is set
+	PublicFaults     bool   // serve /faults on a MetricsAddr which is not a loopback one as well
+	MetricsOutputDir string // if set, samples are also written there as CSV
+	MetricsInterval  int    // in ms, between CSV samples
 }
 
 func DefaultConfig(addr string, port int) *Config {
@@ -27,7 +84,7 @@ func DefaultConfig(addr string, port int) *Config {
 	dialOpts := make([]grpc.DialOption, 0, 5)
 	dialOpts = append(dialOpts, grpc.WithInsecure(), grpc.WithBlock(), grpc.FailOnNonTempDialError(true)) //grpc.WithTimeout(5*time.Second)
 	return &Config{
-		KeySize:                  8,
+		KeySize:                  160,
 		Addr:                     addr,
 		Port:                     uint32(port),
 		Timeout:                  5000,
@@ -37,7 +94,28 @@ func DefaultConfig(addr string, port int) *Config {
 		FixFingerInterval:        50,
 		CheckPredecessorInterval: 150,
 		SuccessorListSize:        2,
+		FingerCandidates:         4,
+		IterativeLookup:          false,
+		HopTimeout:               1000,
+		FailureThreshold:         8,
+		FailureWindow:            100,
+		IndirectProbes:           2,
+		GossipInterval:           1000,
+		GossipFanout:             3,
+		SuspicionTimeout:         5000,
+		MergeInterval:            5000,
 		Logging:				  true,
+		DataDir:                  "",
+		SnapshotInterval:         60000,
+		StorageEngine:            StorageMemory,
+		AntiEntropyInterval:      30000,
+		TombstoneTTL:             86400000,
+		VirtualNodes:             1,
+		EnableMetrics:            false,
+		MetricsAddr:              "",
+		PublicFaults:             false,
+		MetricsOutputDir:         "",
+		MetricsInterval:          10000,
 	}
 }
 
@@ -49,3 +127,18 @@ func SetDefaultGrpcOpts(cfg *Config) *Config {
 	cfg.ServerOpts = serverOpts
 	return cfg
 }
+
+/* Function: 	newLogger
+ *
+ * Description:
+ * 		Return the logger used when the config does not provide one. It
+ *		discards everything unless logging is enabled.
+ */
+func newLogger(logging bool) log.FieldLogger {
+	logger := log.New()
+	logger.SetFormatter(&log.TextFormatter{TimestampFormat: "2006-01-02 15:04:05", FullTimestamp: true})
+	if !logging {
+		logger.SetOutput(ioutil.Discard)
+	}
+	return logger
+}
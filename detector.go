@@ -0,0 +1,129 @@
+package chord
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// failureDetector is a phi-accrual failure detector (Hayashibara et al.).
+// Rather than declaring a peer failed after one missed heartbeat, it keeps
+// the intervals between the recent heartbeats of every peer and derives phi,
+// the suspicion that the peer failed given how long it has been silent:
+// phi = 1 means the silence would be normal one time in 10, phi = 8 one time
+// in 10^8. A peer is suspected once phi exceeds the threshold, so a slow or
+// lossy link raises the silence the detector tolerates on its own.
+type failureDetector struct {
+	threshold float64
+	window    int           // intervals remembered per peer
+	interval  time.Duration // expected interval, until heartbeats are observed
+
+	now func() time.Time // replaced by the simulator's virtual clock
+
+	peers map[string]*heartbeats
+	mtx   sync.Mutex
+}
+
+// heartbeats are the recent heartbeats of a peer
+type heartbeats struct {
+	last      time.Time
+	intervals []time.Duration // oldest first
+}
+
+func newFailureDetector(threshold float64, window int, interval time.Duration) *failureDetector {
+	if window < 1 {
+		window = 1
+	}
+	return &failureDetector{
+		threshold: threshold,
+		window:    window,
+		interval:  interval,
+		now:       time.Now,
+		peers:     make(map[string]*heartbeats),
+	}
+}
+
+// return the heartbeats of peer, monitoring it from now on if it is new
+func (d *failureDetector) peer(peer string) *heartbeats {
+	hb, ok := d.peers[peer]
+	if !ok {
+		hb = &heartbeats{last: d.now(), intervals: []time.Duration{d.interval}}
+		d.peers[peer] = hb
+	}
+	return hb
+}
+
+/* Function: 	heartbeat
+ *
+ * Description:
+ * 		Record that peer answered just now.
+ */
+func (d *failureDetector) heartbeat(peer string) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	_, known := d.peers[peer]
+	hb := d.peer(peer)
+	if !known {
+		return
+	}
+	now := d.now()
+	hb.intervals = append(hb.intervals, now.Sub(hb.last))
+	if len(hb.intervals) > d.window {
+		hb.intervals = hb.intervals[len(hb.intervals)-d.window:]
+	}
+	hb.last = now
+}
+
+/* Function: 	phi
+ *
+ * Description:
+ * 		Return the suspicion that peer failed. The intervals between
+ *		heartbeats are taken to be normally distributed, with a standard
+ * 		deviation of at least half the expected interval so that the
+ *		regular heartbeats of a healthy link do not make every small
+ * 		delay look suspicious.
+ */
+func (d *failureDetector) phi(peer string) float64 {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	hb := d.peer(peer)
+
+	var sum float64
+	for _, i := range hb.intervals {
+		sum += float64(i)
+	}
+	mean := sum / float64(len(hb.intervals))
+	var squares float64
+	for _, i := range hb.intervals {
+		squares += (float64(i) - mean) * (float64(i) - mean)
+	}
+	stdDev := math.Max(math.Sqrt(squares/float64(len(hb.intervals))), float64(d.interval)/2)
+
+	// the logistic approximation of the normal CDF used by Akka and Cassandra
+	y := (float64(d.now().Sub(hb.last)) - mean) / stdDev
+	e := math.Exp(-y * (1.5976 + 0.070566*y*y))
+	if y > 0 {
+		return -math.Log10(e / (1 + e))
+	}
+	return -math.Log10(1 - 1/(1+e))
+}
+
+/* Function: 	suspected
+ *
+ * Description:
+ * 		Return true if phi of peer exceeds the threshold.
+ */
+func (d *failureDetector) suspected(peer string) bool {
+	return d.phi(peer) > d.threshold
+}
+
+/* Function: 	remove
+ *
+ * Description:
+ * 		Stop monitoring peer, e.g. once it was declared failed.
+ */
+func (d *failureDetector) remove(peer string) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	delete(d.peers, peer)
+}
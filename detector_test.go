@@ -0,0 +1,112 @@
+package chord
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestFailureDetector(t *testing.T) {
+	now := time.Unix(0, 0)
+	d := newFailureDetector(8, 100, 100*time.Millisecond)
+	d.now = func() time.Time { return now }
+
+	// a peer answering regularly is not suspected when a heartbeat is late
+	for i := 0; i < 20; i++ {
+		d.heartbeat("a")
+		now = now.Add(100 * time.Millisecond)
+	}
+	assert.True(t, d.phi("a") < 1, "a heartbeat on time should not raise suspicion")
+	now = now.Add(200 * time.Millisecond)
+	assert.False(t, d.suspected("a"), "a peer should not be suspected after a few lost heartbeats")
+	now = now.Add(time.Second)
+	assert.True(t, d.suspected("a"), "a peer silent for ten intervals should be suspected")
+
+	// a peer answering irregularly is given more time
+	d.heartbeat("b")
+	for i := 0; i < 20; i++ {
+		now = now.Add(time.Duration(50+(i%2)*500) * time.Millisecond)
+		d.heartbeat("b")
+	}
+	now = now.Add(1300 * time.Millisecond)
+	assert.False(t, d.suspected("b"), "the silence of an irregular peer should be tolerated longer")
+	assert.True(t, d.phi("b") < d.phi("a"), "phi should grow with the silence relative to the history of the peer")
+
+	// a new peer is given the expected interval
+	assert.False(t, d.suspected("c"), "a new peer should not be suspected")
+	now = now.Add(2 * time.Second)
+	assert.True(t, d.suspected("c"), "a new peer that never answered should be suspected")
+
+	d.remove("a")
+	assert.False(t, d.suspected("a"), "a peer monitored anew should not be suspected")
+}
+
+// A node that can not reach its predecessor keeps it as long as other nodes
+// can, and declares it failed only once they can not either
+func TestIndirectProbes(t *testing.T) {
+	network := NewMemoryNetwork()
+	faults := make(map[*Node]*Faults) // each node injects failures of its own
+	ports := []int{1, 2, 3}
+	nodes := make([]*Node, 0, len(ports))
+	defer func() {
+		for _, n := range nodes {
+			n.shutdown()
+		}
+	}()
+	for _, port := range ports {
+		cfg := memoryConfig(network, port)
+		cfg.Faults = NewFaults()
+		var n *Node
+		var err error
+		if port == 1 {
+			n, err = CreateChord(cfg)
+		} else {
+			n, err = JoinChord(cfg, "10.0.0.1", 1)
+		}
+		assert.Nil(t, err, "CreateChord()/JoinChord() should not result in error")
+		if err != nil {
+			return
+		}
+		nodes = append(nodes, n)
+		faults[n] = cfg.Faults
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for !ringConverged(nodes) && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
+	assert.True(t, ringConverged(nodes), "the successors of the nodes should form a ring")
+
+	sorted := append([]*Node(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Id, sorted[j].Id) < 0 })
+	pred, n := sorted[0], sorted[1]
+	predecessor := func() *Node {
+		n.predMtx.RLock()
+		defer n.predMtx.RUnlock()
+		if n.predecessor != nil && bytes.Equal(n.predecessor.Id, pred.Id) {
+			return pred
+		}
+		return nil
+	}
+	assert.Equal(t, pred, predecessor(), "the predecessor should be known")
+
+	// only the pings of n fail: the node after n still reaches pred for it
+	faults[n].FailMethod("CheckPredecessor", codes.Unavailable)
+	for end := time.Now().Add(3 * time.Second); time.Now().Before(end); {
+		assert.Equal(t, pred, predecessor(), "a predecessor reachable through other nodes should be kept")
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(t, 0.0, testutil.ToFloat64(n.host.metrics.predecessorFailures), "no failure should be declared")
+
+	faults[n].FailMethod("Probe", codes.Unavailable)
+	deadline = time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(n.host.metrics.predecessorFailures) == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(n.host.metrics.predecessorFailures),
+		"the predecessor should be declared failed once no node reaches it")
+}
@@ -0,0 +1,334 @@
+package chord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AllPeers stands for every peer in the latency and drop rules of Faults
+const AllPeers = "*"
+
+// Faults injects failures into the RPCs nodes make to other nodes, e.g. to
+// exercise the recovery of the ring in integration tests and chaos runs:
+//
+//	faults := chord.NewFaults()
+//	cfg.Faults = faults
+//	...
+//	faults.Partition([]string{"10.0.0.1:8001"}, []string{"10.0.0.1:8002", "10.0.0.1:8003"})
+//	faults.Heal()
+//
+// Rules apply on the calling side and may be changed at any time while the
+// ring runs. Hosts of the same process can share a Faults, so that its
+// rules apply between all of them; a chaos run over several processes
+// configures each one through its /faults HTTP endpoint, see ServeHTTP.
+type Faults struct {
+	mtx sync.Mutex
+
+	latency  map[string]time.Duration // by callee address or AllPeers
+	dropRate map[string]float64       // by callee address or AllPeers
+	groups   map[string]int           // side of the partition of each address
+	methods  map[string]codes.Code    // by RPC name, e.g. "GetPredecessor"
+
+	rng *rand.Rand
+}
+
+func NewFaults() *Faults {
+	f := &Faults{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	f.reset()
+	return f
+}
+
+/* Function: 	SetLatency
+ *
+ * Description:
+ * 		Delay the RPCs to peer (ip:port, or AllPeers) by d. A rule for a
+ *		peer takes precedence over the one for AllPeers; 0 removes it.
+ */
+func (f *Faults) SetLatency(peer string, d time.Duration) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if d <= 0 {
+		delete(f.latency, peer)
+		return
+	}
+	f.latency[peer] = d
+}
+
+/* Function: 	SetDropRate
+ *
+ * Description:
+ * 		Lose the given fraction, between 0 and 1, of the RPCs to peer (ip:port,
+ *		or AllPeers). A rule for a peer takes precedence over the one for
+ *		AllPeers; 0 removes it.
+ */
+func (f *Faults) SetDropRate(peer string, rate float64) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if rate <= 0 {
+		delete(f.dropRate, peer)
+		return
+	}
+	f.dropRate[peer] = rate
+}
+
+/* Function: 	Partition
+ *
+ * Description:
+ * 		Split the network: an address of one group can not reach the addresses
+ *		of the other groups. Addresses in no group still reach everyone. The
+ *		groups replace those of a previous partition.
+ */
+func (f *Faults) Partition(groups ...[]string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.groups = make(map[string]int)
+	for i, group := range groups {
+		for _, addr := range group {
+			f.groups[addr] = i
+		}
+	}
+}
+
+/* Function: 	Heal
+ *
+ * Description:
+ * 		Undo Partition.
+ */
+func (f *Faults) Heal() {
+	f.Partition()
+}
+
+/* Function: 	FailMethod
+ *
+ * Description:
+ * 		Fail every call of the given RPC, e.g. "GetPredecessor" or
+ *		"SendReplicas", with code. codes.OK calls it again.
+ */
+func (f *Faults) FailMethod(method string, code codes.Code) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if code == codes.OK {
+		delete(f.methods, method)
+		return
+	}
+	f.methods[method] = code
+}
+
+/* Function: 	Reset
+ *
+ * Description:
+ * 		Remove every rule.
+ */
+func (f *Faults) Reset() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.reset()
+}
+
+func (f *Faults) reset() {
+	f.latency = make(map[string]time.Duration)
+	f.dropRate = make(map[string]float64)
+	f.groups = make(map[string]int)
+	f.methods = make(map[string]codes.Code)
+}
+
+/* Function: 	inject
+ *
+ * Description:
+ * 		Apply the rules to an RPC from the host at from to the host at to.
+ *		The RPC is first delayed, then a non-nil error fails it before it
+ *		is sent. A lost RPC fails with codes.Unavailable right away rather
+ *		than at its deadline, like a call to a host that is down.
+ */
+func (f *Faults) inject(ctx context.Context, from, to, method string) error {
+	f.mtx.Lock()
+	delay, ok := f.latency[to]
+	if !ok {
+		delay = f.latency[AllPeers]
+	}
+	rate, ok := f.dropRate[to]
+	if !ok {
+		rate = f.dropRate[AllPeers]
+	}
+	dropped := rate > 0 && f.rng.Float64() < rate
+	fromGroup, fromOk := f.groups[from]
+	toGroup, toOk := f.groups[to]
+	code, failed := f.methods[method]
+	f.mtx.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	switch {
+	case fromOk && toOk && fromGroup != toGroup:
+		return status.Errorf(codes.Unavailable, "injected fault: %s is partitioned from %s", to, from)
+	case dropped:
+		return status.Errorf(codes.Unavailable, "injected fault: %s to %s was dropped", method, to)
+	case failed:
+		return status.Errorf(code, "injected fault: %s fails", method)
+	}
+	return nil
+}
+
+// faultConn applies the rules of faults to the RPCs made over a connection
+// from the host at from to the host at to
+type faultConn struct {
+	grpc.ClientConnInterface
+	faults *Faults
+	from   string
+	to     string
+}
+
+func (c *faultConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	if err := c.faults.inject(ctx, c.from, c.to, path.Base(method)); err != nil {
+		return err
+	}
+	return c.ClientConnInterface.Invoke(ctx, method, args, reply, opts...)
+}
+
+// faultRules is the JSON form of the rules of a Faults, e.g.
+//
+//	{"latency": {"*": "20ms", "10.0.0.2:8000": "500ms"},
+//	 "drop": {"10.0.0.3:8000": 0.1},
+//	 "partition": [["10.0.0.1:8000"], ["10.0.0.2:8000", "10.0.0.3:8000"]],
+//	 "fail": {"GetPredecessor": "Unavailable"}}
+type faultRules struct {
+	Latency   map[string]string  `json:"latency,omitempty"`
+	Drop      map[string]float64 `json:"drop,omitempty"`
+	Partition [][]string         `json:"partition,omitempty"`
+	Fail      map[string]string  `json:"fail,omitempty"`
+}
+
+// the codes by name, as printed by codes.Code.String()
+var codesByName = func() map[string]codes.Code {
+	m := make(map[string]codes.Code)
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		m[c.String()] = c
+	}
+	return m
+}()
+
+func (f *Faults) rules() faultRules {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	r := faultRules{
+		Latency: make(map[string]string),
+		Drop:    make(map[string]float64),
+		Fail:    make(map[string]string),
+	}
+	for peer, d := range f.latency {
+		r.Latency[peer] = d.String()
+	}
+	for peer, rate := range f.dropRate {
+		r.Drop[peer] = rate
+	}
+	for addr, i := range f.groups {
+		for len(r.Partition) <= i {
+			r.Partition = append(r.Partition, []string{})
+		}
+		r.Partition[i] = append(r.Partition[i], addr)
+	}
+	for _, group := range r.Partition {
+		sort.Strings(group)
+	}
+	for method, code := range f.methods {
+		r.Fail[method] = code.String()
+	}
+	return r
+}
+
+/* Function: 	setRules
+ *
+ * Description:
+ * 		Replace every rule with r. Nothing changes if r is not valid.
+ */
+func (f *Faults) setRules(r faultRules) error {
+	latency := make(map[string]time.Duration)
+	for peer, s := range r.Latency {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("latency of %s: %v", peer, err)
+		}
+		latency[peer] = d
+	}
+	methods := make(map[string]codes.Code)
+	for method, name := range r.Fail {
+		code, ok := codesByName[name]
+		if !ok {
+			return fmt.Errorf("unknown code %q for %s", name, method)
+		}
+		methods[method] = code
+	}
+
+	groups := make(map[string]int)
+	for i, group := range r.Partition {
+		for _, addr := range group {
+			groups[addr] = i
+		}
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.reset()
+	for peer, d := range latency {
+		if d > 0 {
+			f.latency[peer] = d
+		}
+	}
+	for peer, rate := range r.Drop {
+		if rate > 0 {
+			f.dropRate[peer] = rate
+		}
+	}
+	f.groups = groups
+	f.methods = methods
+	return nil
+}
+
+/* Function: 	ServeHTTP
+ *
+ * Description:
+ * 		Configure the rules at runtime. GET returns them as JSON (see
+ *		faultRules), PUT replaces them and DELETE removes them all. The
+ *		metrics server of a host serves its Faults at /faults.
+ */
+func (f *Faults) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var rules faultRules
+		if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := f.setRules(rules); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		f.Reset()
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f.rules())
+}
@@ -0,0 +1,156 @@
+package chord
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFaultRules(t *testing.T) {
+	ctx := context.Background()
+	f := NewFaults()
+	assert.Nil(t, f.inject(ctx, "a", "b", "Notify"), "an RPC without rules should not fail")
+
+	f.Partition([]string{"a"}, []string{"b", "c"})
+	assert.Equal(t, codes.Unavailable, status.Code(f.inject(ctx, "a", "b", "Notify")), "an RPC across the partition should fail")
+	assert.Equal(t, codes.Unavailable, status.Code(f.inject(ctx, "b", "a", "Notify")), "an RPC across the partition should fail")
+	assert.Nil(t, f.inject(ctx, "b", "c", "Notify"), "an RPC within a side of the partition should not fail")
+	assert.Nil(t, f.inject(ctx, "d", "a", "Notify"), "an address in no group should reach every side")
+	f.Heal()
+	assert.Nil(t, f.inject(ctx, "a", "b", "Notify"), "an RPC should not fail once the partition healed")
+
+	f.FailMethod("GetPredecessor", codes.Internal)
+	assert.Equal(t, codes.Internal, status.Code(f.inject(ctx, "a", "b", "GetPredecessor")), "a failed method should fail with its code")
+	assert.Nil(t, f.inject(ctx, "a", "b", "Notify"), "other methods should not fail")
+	f.FailMethod("GetPredecessor", codes.OK)
+	assert.Nil(t, f.inject(ctx, "a", "b", "GetPredecessor"), "a restored method should not fail")
+
+	f.SetDropRate(AllPeers, 1)
+	f.SetDropRate("c", 0.5)
+	f.SetDropRate("c", 0)
+	assert.Equal(t, codes.Unavailable, status.Code(f.inject(ctx, "a", "c", "Notify")), "every RPC should be dropped")
+
+	f.Reset()
+	f.SetLatency("b", 50*time.Millisecond)
+	start := time.Now()
+	assert.Nil(t, f.inject(ctx, "a", "b", "Notify"), "a delayed RPC should not fail")
+	assert.True(t, time.Since(start) >= 50*time.Millisecond, "the RPC should be delayed")
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(f.inject(short, "a", "b", "Notify")), "the RPC should time out while delayed")
+	start = time.Now()
+	assert.Nil(t, f.inject(ctx, "a", "c", "Notify"), "an RPC to another peer should not fail")
+	assert.True(t, time.Since(start) < 50*time.Millisecond, "an RPC to another peer should not be delayed")
+}
+
+func TestFaultsHTTP(t *testing.T) {
+	f := NewFaults()
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	do := func(method, body string) (int, string) {
+		req, err := http.NewRequest(method, srv.URL, strings.NewReader(body))
+		assert.Nil(t, err, "NewRequest() should not result in error")
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err, "Do() should not result in error")
+		if err != nil {
+			return 0, ""
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	code, _ := do(http.MethodPut, `{"latency": {"*": "1s"}, "fail": {"SendReplicas": "Bogus"}}`)
+	assert.Equal(t, http.StatusBadRequest, code, "an unknown code should be rejected")
+	assert.Nil(t, f.inject(context.Background(), "a", "b", "SendReplicas"), "rejected rules should not be applied")
+
+	code, body := do(http.MethodPut, `{"drop": {"b": 1}, "partition": [["a"], ["c"]], "fail": {"SendReplicas": "Unavailable"}}`)
+	assert.Equal(t, http.StatusOK, code, "valid rules should be accepted")
+	assert.Contains(t, body, `"SendReplicas":"Unavailable"`, "the rules in effect should be returned")
+	ctx := context.Background()
+	assert.NotNil(t, f.inject(ctx, "d", "b", "Notify"), "RPCs to b should be dropped")
+	assert.NotNil(t, f.inject(ctx, "a", "c", "Notify"), "a and c should be partitioned")
+	assert.Equal(t, codes.Unavailable, status.Code(f.inject(ctx, "d", "e", "SendReplicas")), "SendReplicas should fail")
+
+	code, _ = do(http.MethodDelete, "")
+	assert.Equal(t, http.StatusOK, code, "rules should be removed")
+	assert.Nil(t, f.inject(ctx, "a", "c", "SendReplicas"), "no RPC should fail once rules are removed")
+}
+
+// A node cut off from the rest of the ring is routed around through the
+// successor lists and predecessor checks, and rejoins once the partition heals
+func TestFaultsPartition(t *testing.T) {
+	const numNodes = 4
+	network := NewMemoryNetwork()
+	faults := NewFaults()
+	faultConfig := func(port int) *Config {
+		cfg := memoryConfig(network, port)
+		cfg.Faults = faults
+		cfg.SuccessorListSize = 3
+		return cfg
+	}
+
+	first, err := CreateChord(faultConfig(1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	nodes := []*Node{first}
+	defer func() {
+		for _, n := range nodes {
+			n.shutdown()
+		}
+	}()
+	for i := 2; i <= numNodes; i++ {
+		n, err := JoinChord(faultConfig(i), "10.0.0.1", 1)
+		assert.Nil(t, err, "JoinChord() should not result in error")
+		if err != nil {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+
+	converge := func(nodes []*Node) bool {
+		deadline := time.Now().Add(60 * time.Second)
+		for !ringConverged(nodes) && time.Now().Before(deadline) {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return ringConverged(nodes)
+	}
+	assert.True(t, converge(nodes), "the successors of the nodes should form a ring")
+
+	isolated, rest := nodes[numNodes-1], nodes[:numNodes-1]
+	addrs := make([]string, 0, len(rest))
+	for _, n := range rest {
+		addrs = append(addrs, fmt.Sprintf("%s:%d", n.Addr, n.Port))
+	}
+	faults.Partition([]string{fmt.Sprintf("%s:%d", isolated.Addr, isolated.Port)}, addrs)
+	assert.True(t, converge(rest), "the other nodes should form a ring without the isolated one")
+	// the successor of the isolated node drops it as predecessor once its
+	// failure detector suspects it, which may take longer than stabilization
+	isolatedPred := func(n *Node) bool {
+		n.predMtx.RLock()
+		defer n.predMtx.RUnlock()
+		return n.predecessor != nil && n.predecessor.Port == isolated.Port
+	}
+	for _, n := range rest {
+		deadline := time.Now().Add(30 * time.Second)
+		for isolatedPred(n) && time.Now().Before(deadline) {
+			time.Sleep(200 * time.Millisecond)
+		}
+		assert.False(t, isolatedPred(n), "the isolated node should not remain a predecessor")
+	}
+
+	faults.Heal()
+	assert.True(t, converge(nodes), "the isolated node should rejoin the ring once the partition heals")
+}
@@ -1,16 +1,18 @@
 package chord
 
 import (
+	"bytes"
 	"github.com/cdesiniotis/chord/chordpb"
-	log "github.com/sirupsen/logrus"
 	"math/big"
+	"time"
 )
 
 type fingerTable []*fingerEntry
 
 type fingerEntry struct {
-	Id   []byte        // Id calculated by formula
-	Node *chordpb.Node // Closest peer >= Id
+	Id         []byte          // Id calculated by formula
+	Node       *chordpb.Node   // Closest peer >= Id
+	Candidates []*chordpb.Node // Peers of the interval up to the next finger, Node first
 }
 
 /* Function: 	NewFingerTable
@@ -48,6 +50,8 @@ func newFingerEntry(id []byte, n *chordpb.Node) *fingerEntry {
  * Description:
  * 		Calculate the new id in the chord ring based on the following formula:
  *		(n+2^i)mod(2^m)
+ * 		The result is padded to m/8 bytes so it compares
+ * 		correctly against other ids.
  */
 func fingerMath(n []byte, i int, m int) []byte {
 	x := big.NewInt(2)
@@ -59,26 +63,60 @@ func fingerMath(n []byte, i int, m int) []byte {
 	res := &big.Int{}
 	res.SetBytes(n).Add(res, x).Mod(res, y)
 
-	return res.Bytes()
+	return res.FillBytes(make([]byte, m/8))
 }
 
 /* Function: 	fixFinger
  *
  * Description:
- * 		Fix a finger table entry if it is no longer correct.
+ * 		Fix a finger table entry if it is no longer correct, and
+ *		refresh the candidates of its interval, see fingerCandidates.
  */
 func (n *Node) fixFinger(next int) {
+	start := time.Now()
+	defer func() {
+		n.host.metrics.fixFingerDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	nextID := fingerMath(n.Id, next, n.config.KeySize)
 	succ, err := n.findSuccessor(nextID)
 	if err != nil {
 		return
 	}
 	newEntry := newFingerEntry(nextID, succ)
+	if n.config.FingerCandidates > 1 {
+		newEntry.Candidates = n.fingerCandidates(next, succ)
+	}
 	n.ftMtx.Lock()
 	n.fingerTable[next] = newEntry
 	n.ftMtx.Unlock()
 }
 
+/* Function: 	replaceFingers
+ *
+ * Description:
+ * 		Point every finger table entry for node "old" to node "new" instead,
+ *		and drop "old" from the candidates of the others. Used when a node
+ * 		leaves the ring.
+ */
+func (n *Node) replaceFingers(old *chordpb.Node, new *chordpb.Node) {
+	n.ftMtx.Lock()
+	for i, entry := range n.fingerTable {
+		if bytes.Equal(entry.Node.Id, old.Id) {
+			n.fingerTable[i] = newFingerEntry(entry.Id, new)
+		} else if Contains(entry.Candidates, old) {
+			candidates := make([]*chordpb.Node, 0, len(entry.Candidates))
+			for _, c := range entry.Candidates {
+				if !bytes.Equal(c.Id, old.Id) {
+					candidates = append(candidates, c)
+				}
+			}
+			n.fingerTable[i] = &fingerEntry{Id: entry.Id, Node: entry.Node, Candidates: candidates}
+		}
+	}
+	n.ftMtx.Unlock()
+}
+
 /* Function: 	PrintFingerTable
  *
  * Description:
@@ -87,13 +125,13 @@ func (n *Node) fixFinger(next int) {
  */
 func (n *Node) PrintFingerTable(hex bool) {
 	n.ftMtx.Lock()
-	log.Printf("-----FINGER TABLE-----\n")
+	n.logger.Printf("-----FINGER TABLE-----\n")
 	ft := n.fingerTable
 	for i, v := range ft {
 		if hex {
-			log.Infof("FT Entry %d - {id: %x, Node{id: %x, addr: %s, port: %d}}\n", i, v.Id, v.Node.Id, v.Node.Addr, v.Node.Port)
+			n.logger.Infof("FT Entry %d - {id: %x, Node{id: %x, addr: %s, port: %d}}\n", i, v.Id, v.Node.Id, v.Node.Addr, v.Node.Port)
 		} else {
-			log.Infof("FT Entry %d - {id: %d, Node{id: %d, addr: %s, port: %d}}\n", i, v.Id, v.Node.Id, v.Node.Addr, v.Node.Port)
+			n.logger.Infof("FT Entry %d - {id: %d, Node{id: %d, addr: %s, port: %d}}\n", i, v.Id, v.Node.Id, v.Node.Addr, v.Node.Port)
 		}
 	}
 	n.ftMtx.Unlock()
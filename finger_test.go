@@ -21,3 +21,20 @@ func TestFingerMath(t *testing.T) {
 	}
 
 }
+
+func TestFingerMathWide(t *testing.T) {
+	m := 160
+	start := make([]byte, 20)
+	start[0] = 0xff
+
+	// results keep the full width of the identifier space
+	key := fingerMath(start, 0, m)
+	assert.Equal(t, 20, len(key), "finger math should return m/8 bytes")
+	assert.Equal(t, byte(1), key[19])
+
+	// (n + 2^159) mod 2^160 wraps around the ring
+	key = fingerMath(start, m-1, m)
+	ans := make([]byte, 20)
+	ans[0] = 0x7f
+	assert.Equal(t, 0, bytes.Compare(key, ans), "finger math should wrap around a 160-bit ring")
+}
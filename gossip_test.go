@@ -0,0 +1,201 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembershipUpdates(t *testing.T) {
+	now := time.Unix(0, 0)
+	node := func(id byte) *chordpb.Node {
+		return &chordpb.Node{Id: []byte{id}, Addr: "10.0.0.1", Port: uint32(id)}
+	}
+	update := func(id byte, state chordpb.MemberState, incarnation uint64) *chordpb.Member {
+		return &chordpb.Member{Node: node(id), State: state, Incarnation: incarnation}
+	}
+	m := newMembership([]*chordpb.Node{node(0x10)}, time.Second)
+	m.now = func() time.Time { return now }
+
+	assert.True(t, m.apply(update(0x20, chordpb.MemberState_ALIVE, 0)), "a new member should be added")
+	assert.False(t, m.apply(update(0x20, chordpb.MemberState_ALIVE, 0)), "a known update should be ignored")
+	assert.True(t, m.apply(update(0x20, chordpb.MemberState_SUSPECT, 0)), "a suspicion should override the member being alive")
+	assert.False(t, m.apply(update(0x20, chordpb.MemberState_ALIVE, 0)), "a suspicion should only be refuted by a higher incarnation")
+	assert.True(t, m.apply(update(0x20, chordpb.MemberState_ALIVE, 1)), "a higher incarnation should refute the suspicion")
+	assert.True(t, m.apply(update(0x20, chordpb.MemberState_DEAD, 1)), "a failure should override the member being alive")
+	assert.True(t, m.failed([]byte{0x20}), "a dead member should have failed")
+	assert.False(t, m.apply(update(0x20, chordpb.MemberState_SUSPECT, 1)), "a dead member should not be suspected")
+	assert.True(t, m.apply(update(0x20, chordpb.MemberState_ALIVE, 2)), "a member should come back with a higher incarnation")
+	assert.False(t, m.failed([]byte{0x20}), "a member which came back should not have failed")
+
+	// rumours about ourselves are refuted
+	assert.False(t, m.apply(update(0x10, chordpb.MemberState_SUSPECT, 0)), "our own state should not be changed by others")
+	self := m.view()[0]
+	assert.Equal(t, chordpb.MemberState_ALIVE, self.State, "we should remain alive")
+	assert.Equal(t, uint64(1), self.Incarnation, "we should raise our incarnation to refute the suspicion")
+	refuted := false
+	for _, u := range m.pending(gossipMaxUpdates) {
+		refuted = refuted || (bytes.Equal(u.Node.Id, []byte{0x10}) && u.Incarnation == 1)
+	}
+	assert.True(t, refuted, "the refutation should be gossiped")
+
+	// updates are sent a bounded number of times
+	for i := 0; i < 4*gossipRetransmits; i++ {
+		m.pending(gossipMaxUpdates)
+	}
+	assert.Empty(t, m.pending(gossipMaxUpdates), "updates should be dropped once disseminated")
+
+	// a suspected member is declared dead unless it refutes the suspicion in time
+	m.discover(node(0x30))
+	m.discover(node(0x40))
+	m.suspect(node(0x30))
+	now = now.Add(500 * time.Millisecond)
+	assert.Empty(t, m.expire(), "a member should not be declared dead before the timeout")
+	now = now.Add(time.Second)
+	assert.Equal(t, []*chordpb.Node{node(0x30)}, m.expire(), "a member suspected for too long should be declared dead")
+	assert.True(t, m.failed([]byte{0x30}), "an expired member should have failed")
+
+	m.left(node(0x20))
+	assert.Equal(t, []*chordpb.Node{node(0x40), node(0x10)}, m.successors([]byte{0x20}, 3),
+		"only members which did not fail or leave should follow us")
+	assert.Equal(t, []*chordpb.Node{node(0x40)}, m.targets(3, "10.0.0.1:16"), "only live processes other than ours should be gossiped with")
+
+	m.leave()
+	assert.False(t, m.apply(update(0x10, chordpb.MemberState_LEFT, 1)), "our own state should not be changed by others")
+	assert.Equal(t, chordpb.MemberState_LEFT, m.view()[0].State, "a departure should not be refuted")
+}
+
+// Members which failed or left are forgotten after memberRetention, but for
+// the ones declared dead last, which a merge may still probe
+func TestMembershipPruning(t *testing.T) {
+	now := time.Unix(0, 0)
+	node := func(id byte) *chordpb.Node {
+		return &chordpb.Node{Id: []byte{id}, Addr: "10.0.0.1", Port: uint32(id)}
+	}
+	m := newMembership([]*chordpb.Node{node(0)}, time.Second)
+	m.now = func() time.Time { return now }
+
+	m.discover(node(1))
+	m.left(node(1))
+	for i := 0; i < memberRemembered+2; i++ {
+		m.discover(node(byte(2 + i)))
+		m.dead(node(byte(2 + i)))
+		now = now.Add(time.Second)
+	}
+	m.discover(node(0xff))
+	m.leave()
+
+	m.expire()
+	assert.Equal(t, memberRemembered+5, len(m.view()), "members gone for less than memberRetention should be kept")
+
+	now = now.Add(memberRetention)
+	m.expire()
+	kept := make(map[byte]bool)
+	for _, u := range m.view() {
+		kept[u.Node.Id[0]] = true
+	}
+	assert.True(t, kept[0], "our own virtual nodes should be kept")
+	assert.True(t, kept[0xff], "live members should be kept")
+	assert.False(t, kept[1], "members which left should be forgotten")
+	assert.False(t, kept[2] || kept[3], "the members declared dead first should be forgotten")
+	assert.Equal(t, memberRemembered+2, len(kept), "the members declared dead last should be kept")
+	assert.NotNil(t, m.remembered("10.0.0.1:0"), "forgotten members should leave some to probe")
+
+	assert.True(t, m.apply(&chordpb.Member{Node: node(2), State: chordpb.MemberState_ALIVE, Incarnation: 1}),
+		"a forgotten member should be added back if it returns")
+}
+
+// Every process learns of the members of the ring, of the crashed ones and
+// of the ones which left through gossip
+func TestGossipMembership(t *testing.T) {
+	const numNodes = 5
+	network := NewMemoryNetwork()
+	gossipConfig := func(port int) *Config {
+		cfg := memoryConfig(network, port)
+		cfg.GossipInterval = 200
+		cfg.SuspicionTimeout = 1000
+		return cfg
+	}
+
+	first, err := CreateChord(gossipConfig(1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	nodes := []*Node{first}
+	defer func() {
+		for _, n := range nodes {
+			n.shutdown()
+		}
+	}()
+	for i := 2; i <= numNodes; i++ {
+		n, err := JoinChord(gossipConfig(i), "10.0.0.1", 1)
+		assert.Nil(t, err, "JoinChord() should not result in error")
+		if err != nil {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+
+	// wait until every node in live has the given state in the view of every other
+	states := func(live []*Node, want map[*Node]chordpb.MemberState) bool {
+		deadline := time.Now().Add(20 * time.Second)
+		for time.Now().Before(deadline) {
+			agreed := true
+			for _, n := range live {
+				view := make(map[string]chordpb.MemberState)
+				for _, m := range n.Members() {
+					view[idKey(m.Node.Id)] = m.State
+				}
+				for other, state := range want {
+					if s, ok := view[idKey(other.Id)]; !ok || s != state {
+						agreed = false
+					}
+				}
+			}
+			if agreed {
+				return true
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return false
+	}
+	want := make(map[*Node]chordpb.MemberState)
+	for _, n := range nodes {
+		want[n] = chordpb.MemberState_ALIVE
+	}
+	assert.True(t, states(nodes, want), "every node should learn of every member")
+
+	// a crashed node is suspected, then declared dead
+	crashed := nodes[numNodes-1]
+	crashed.shutdown()
+	nodes = nodes[:numNodes-1]
+	want[crashed] = chordpb.MemberState_DEAD
+	assert.True(t, states(nodes, want), "every node should learn that the crashed node is dead")
+
+	// a node which leaves announces it
+	leaver := nodes[len(nodes)-1]
+	err = leaver.Leave(context.Background())
+	assert.Nil(t, err, "Leave() should not result in error")
+	nodes = nodes[:len(nodes)-1]
+	want[leaver] = chordpb.MemberState_LEFT
+	assert.True(t, states(nodes, want), "every node should learn that the node left")
+
+	// clients read the view over RPC
+	conn, err := network.Transport().Client(fmt.Sprintf("%s:%d", first.Addr, first.Port))
+	assert.Nil(t, err, "Client() should not result in error")
+	if err != nil {
+		return
+	}
+	members, err := chordpb.NewChordClient(conn).GetMembers(context.Background(), &chordpb.Empty{})
+	assert.Nil(t, err, "GetMembers() should not result in error")
+	if err != nil {
+		return
+	}
+	assert.Equal(t, numNodes, len(members.Members), "every member ever seen should be listed")
+}
@@ -0,0 +1,556 @@
+package chord
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC metadata key carrying the ID of the virtual node an RPC is meant for
+const vnodeMetadataKey = "chord-vnode"
+
+// gRPC header carrying the number of nodes a FindSuccessor request was forwarded through
+const hopsMetadataKey = "chord-hops"
+
+// gRPC header carrying the round-trip time, in ns, saved by proximity routing on
+// the hops of a FindSuccessor request past the node answering, or by its NextHop
+const rttSavedMetadataKey = "chord-rtt-saved"
+
+// gRPC metadata key set by clients which route requests themselves: the
+// node must serve the request only if it is responsible for the key
+const directMetadataKey = "chord-direct"
+
+/* Function: 	WithTarget
+ *
+ * Description:
+ * 		Address outgoing client RPCs to the virtual node with the given id, which
+ *		must be responsible for the key. Otherwise the request fails with
+ * 		codes.Aborted rather than being forwarded, see GetRoutingTable.
+ */
+func WithTarget(ctx context.Context, id []byte) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, vnodeMetadataKey, idKey(id), directMetadataKey, "1")
+}
+
+// host is a single chord process. It owns the transport, which serves its
+// RPCs and connects it to other hosts, and hosts one or more virtual nodes
+// that each have their own position on the ring.
+type host struct {
+	config *Config
+
+	vnodes []*Node // vnodes[0] handles RPCs that do not name a vnode
+
+	transport Transport
+
+	membership *membership // the ring as learned through gossip
+	rtt        *rttTable   // round-trip times to the other hosts, see proximity.go
+
+	metrics *metrics
+	logger  log.FieldLogger
+
+	stopCh       chan struct{} // stops the threads of the host itself
+	doneCh       chan struct{} // closed once shutdown() has completed
+	shutdownOnce sync.Once
+}
+
+/* Function: 	newHost
+ *
+ * Description:
+ * 		Create the virtual nodes for this process, then start the gRPC server
+ * 		that serves all of them. Returns an error, having released anything
+ *		already set up, if the nodes or the listening socket can not be created.
+ */
+func newHost(config *Config) (*host, error) {
+	h, err := openHost(config)
+	if err != nil {
+		return nil, err
+	}
+	h.start()
+	return h, nil
+}
+
+/* Function: 	openHost
+ *
+ * Description:
+ * 		Create the virtual nodes and serve their RPCs, without starting
+ *		the metrics or the periodic threads of the nodes. The simulator
+ *		drives the protocol of hosts opened this way on its own clock.
+ */
+func openHost(config *Config) (*host, error) {
+	if err := CheckKeySize(config.KeySize); err != nil {
+		return nil, err
+	}
+	if config.EnableMetrics && config.MetricsAddr == "" {
+		return nil, fmt.Errorf("metrics are enabled but no MetricsAddr is set")
+	}
+
+	// Log through the logger we are given, leaving the global one alone
+	logger := config.Logger
+	if logger == nil {
+		logger = newLogger(config.Logging)
+	}
+	auth, err := newAuthorizer(config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &host{
+		config:    config,
+		logger:    logger,
+		transport: config.Transport,
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+	if h.transport == nil {
+		h.transport = newGRPCTransport(grpcOpts{
+			serverOpts: config.ServerOpts,
+			dialOpts:   config.DialOpts,
+			timeout:    time.Duration(config.Timeout) * time.Millisecond}, logger)
+	}
+	h.metrics = newMetrics(h)
+	h.rtt = newRTTTable()
+
+	numVnodes := config.VirtualNodes
+	if numVnodes < 1 {
+		numVnodes = 1
+	}
+	for i := 0; i < numVnodes; i++ {
+		n, err := newNode(h, vnodeConfig(config, i), vnodeKey(config, i))
+		if err != nil {
+			h.closeVnodes()
+			return nil, err
+		}
+		h.vnodes = append(h.vnodes, n)
+	}
+
+	nodes := make([]*chordpb.Node, 0, len(h.vnodes))
+	for _, n := range h.vnodes {
+		nodes = append(nodes, n.Node)
+	}
+	h.membership = newMembership(nodes, time.Duration(config.SuspicionTimeout)*time.Millisecond)
+
+	// Thread 1: serve our RPCs, counting and authorizing every one of them
+	key := config.Addr + ":" + strconv.Itoa(int(config.Port))
+	err = h.transport.Listen(key, h, chainInterceptors(h.metrics.unaryInterceptor, auth.unaryInterceptor))
+	if err != nil {
+		h.closeVnodes()
+		return nil, fmt.Errorf("error creating listening socket: %v", err)
+	}
+
+	h.logger.Infof("Server is listening on %v with %d virtual nodes\n", key, numVnodes)
+
+	return h, nil
+}
+
+/* Function: 	start
+ *
+ * Description:
+ * 		Start the metrics and the periodic threads of every virtual node.
+ */
+func (h *host) start() {
+	config := h.config
+	if config.EnableMetrics {
+		err := h.metrics.start(config.MetricsAddr, config.MetricsOutputDir, config.MetricsInterval)
+		if err != nil {
+			h.logger.Errorf("error starting metrics: %v\n", err)
+		}
+	}
+
+	// Thread 2: the periodic threads of every virtual node
+	for _, n := range h.vnodes {
+		n.start()
+	}
+
+	// Thread 3: gossip membership events with other processes
+	if config.GossipInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Duration(config.GossipInterval) * time.Millisecond)
+			for {
+				select {
+				case <-ticker.C:
+					h.gossip()
+				case <-h.stopCh:
+					ticker.Stop()
+					return
+				}
+			}
+		}()
+	}
+
+	// Thread 4: look for a disjoint ring to merge with, once a partition healed
+	if config.MergeInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Duration(config.MergeInterval) * time.Millisecond)
+			for {
+				select {
+				case <-ticker.C:
+					h.checkPartition()
+				case <-h.stopCh:
+					ticker.Stop()
+					return
+				}
+			}
+		}()
+	}
+}
+
+/* Function: 	chainInterceptors
+ *
+ * Description:
+ * 		Return an interceptor running first, then second, then the handler.
+ */
+func chainInterceptors(first grpc.UnaryServerInterceptor, second grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return first(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return second(ctx, req, info, handler)
+		})
+	}
+}
+
+/* Function: 	closeVnodes
+ *
+ * Description:
+ * 		Close the storage of the virtual nodes created so far, when the
+ *		host fails to start.
+ */
+func (h *host) closeVnodes() {
+	for _, n := range h.vnodes {
+		n.close()
+	}
+}
+
+/* Function: 	vnodeKey
+ *
+ * Description:
+ * 		Return the string hashed into the peer ID of virtual node i.
+ *		The first virtual node keeps the plain ip:port.
+ */
+func vnodeKey(config *Config, i int) string {
+	key := config.Addr + ":" + strconv.Itoa(int(config.Port))
+	if i == 0 {
+		return key
+	}
+	return key + "#" + strconv.Itoa(i)
+}
+
+/* Function: 	vnodeConfig
+ *
+ * Description:
+ * 		Return the config of virtual node i. Every virtual node past the first
+ *		persists its data in its own sub-directory of DataDir.
+ */
+func vnodeConfig(config *Config, i int) *Config {
+	if i == 0 || config.DataDir == "" {
+		return config
+	}
+	c := *config
+	c.DataDir = filepath.Join(config.DataDir, fmt.Sprintf("vnode-%d", i))
+	return &c
+}
+
+/* Function: 	vnode
+ *
+ * Description:
+ * 		Return the virtual node an incoming RPC is meant for. A virtual node
+ *		we do not serve (anymore) fails the RPC with codes.Aborted, which tells
+ * 		a client routing by a stale view of the ring to learn it again.
+ */
+func (h *host) vnode(ctx context.Context) (*Node, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ids := md.Get(vnodeMetadataKey)
+	if len(ids) == 0 || ids[0] == "" {
+		return h.vnodes[0], nil
+	}
+	for _, n := range h.vnodes {
+		if idKey(n.Id) == ids[0] {
+			return n, nil
+		}
+	}
+	return nil, status.Errorf(codes.Aborted, "no virtual node with id %s", ids[0])
+}
+
+/* Function: 	leave
+ *
+ * Description:
+ *		Hand the keys of every virtual node over to its successor, then shutdown.
+ */
+func (h *host) leave(ctx context.Context) error {
+	err := h.handOff(ctx)
+	if err != nil {
+		return err
+	}
+	h.announceLeave()
+	h.shutdown()
+	return nil
+}
+
+/* Function: 	announceLeave
+ *
+ * Description:
+ *		Gossip that our virtual nodes left the ring, once their keys were
+ * 		handed over, rather than letting other processes suspect them.
+ */
+func (h *host) announceLeave() {
+	h.membership.leave()
+	if h.config.GossipInterval > 0 {
+		h.gossip()
+	}
+}
+
+func (h *host) handOff(ctx context.Context) error {
+	for _, n := range h.vnodes {
+		err := n.handOff(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * Function:	shutdown
+ *
+ * Description:
+ *		Stop every virtual node and release the resources they share.
+ *		Safe to call more than once.
+ */
+func (h *host) shutdown() {
+	h.shutdownOnce.Do(h.doShutdown)
+}
+
+func (h *host) doShutdown() {
+	h.logger.Infof("In shutdown()\n")
+	close(h.stopCh)
+	for _, n := range h.vnodes {
+		n.stop()
+	}
+
+	h.logger.Infof("Closing grpc server...\n")
+	h.transport.Stop()
+	h.metrics.stop()
+
+	h.closeVnodes()
+	close(h.doneCh)
+}
+
+/*
+ * The methods below implement the Chord gRPC server interface by handing
+ * each RPC to the virtual node it targets.
+ */
+
+func (h *host) FindSuccessor(ctx context.Context, peerID *chordpb.PeerID) (*chordpb.Node, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.FindSuccessor(ctx, peerID)
+}
+
+func (h *host) GetPredecessor(ctx context.Context, empty *chordpb.Empty) (*chordpb.Node, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.GetPredecessor(ctx, empty)
+}
+
+func (h *host) Notify(ctx context.Context, node *chordpb.Node) (*chordpb.Empty, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.Notify(ctx, node)
+}
+
+func (h *host) CheckPredecessor(ctx context.Context, empty *chordpb.Empty) (*chordpb.Empty, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.CheckPredecessor(ctx, empty)
+}
+
+func (h *host) Probe(ctx context.Context, target *chordpb.Node) (*chordpb.Empty, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.Probe(ctx, target)
+}
+
+func (h *host) GetSuccessorList(ctx context.Context, empty *chordpb.Empty) (*chordpb.SuccessorList, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.GetSuccessorList(ctx, empty)
+}
+
+func (h *host) RecvCoordinatorMsg(ctx context.Context, msg *chordpb.CoordinatorMsg) (*chordpb.Empty, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.RecvCoordinatorMsg(ctx, msg)
+}
+
+func (h *host) GetKeys(ctx context.Context, req *chordpb.KeysRequest) (*chordpb.KVs, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.GetKeys(ctx, req)
+}
+
+func (h *host) SendReplicas(ctx context.Context, replicaMsg *chordpb.ReplicaMsg) (*chordpb.Empty, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.SendReplicas(ctx, replicaMsg)
+}
+
+func (h *host) RemoveReplicas(ctx context.Context, replicaMsg *chordpb.ReplicaMsg) (*chordpb.Empty, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.RemoveReplicas(ctx, replicaMsg)
+}
+
+func (h *host) Get(ctx context.Context, key *chordpb.Key) (*chordpb.Value, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.serveGet(ctx, key)
+}
+
+func (h *host) Put(ctx context.Context, kv *chordpb.KV) (*chordpb.Empty, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.servePut(ctx, kv)
+}
+
+func (h *host) Delete(ctx context.Context, key *chordpb.Key) (*chordpb.Empty, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.serveDelete(ctx, key)
+}
+
+func (h *host) Locate(ctx context.Context, key *chordpb.Key) (*chordpb.Node, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.serveLocate(ctx, key)
+}
+
+func (h *host) GetReplica(ctx context.Context, req *chordpb.ReplicaKey) (*chordpb.Value, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.GetReplica(ctx, req)
+}
+
+func (h *host) SyncReplicas(ctx context.Context, tree *chordpb.MerkleTree) (*chordpb.MerkleDiff, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.SyncReplicas(ctx, tree)
+}
+
+func (h *host) GetRoutingTable(ctx context.Context, empty *chordpb.Empty) (*chordpb.RoutingTable, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.GetRoutingTable(ctx, empty)
+}
+
+func (h *host) NextHop(ctx context.Context, peerID *chordpb.PeerID) (*chordpb.Hop, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.NextHop(ctx, peerID)
+}
+
+func (h *host) Trace(ctx context.Context, key *chordpb.Key) (*chordpb.Path, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.serveTrace(ctx, key)
+}
+
+/* Function: 	Gossip
+ *
+ * Description:
+ * 		Implementation of Gossip RPC. Merge the updates of another process into
+ *		our view, and answer with our own updates, or our whole view if it
+ * 		sent its own.
+ */
+func (h *host) Gossip(ctx context.Context, msg *chordpb.GossipMsg) (*chordpb.GossipMsg, error) {
+	for _, u := range msg.Members {
+		h.membership.apply(u)
+	}
+	if msg.Full {
+		return &chordpb.GossipMsg{Members: h.membership.view(), Full: true}, nil
+	}
+	return &chordpb.GossipMsg{Members: h.membership.pending(gossipMaxUpdates)}, nil
+}
+
+/* Function: 	GetMembers
+ *
+ * Description:
+ * 		Implementation of GetMembers RPC. Return every member of the ring we
+ *		know of, along with its state.
+ */
+func (h *host) GetMembers(ctx context.Context, empty *chordpb.Empty) (*chordpb.Members, error) {
+	return &chordpb.Members{Members: h.membership.view()}, nil
+}
+
+func (h *host) NotifyLeave(ctx context.Context, msg *chordpb.LeaveMsg) (*chordpb.Empty, error) {
+	n, err := h.vnode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return n.NotifyLeave(ctx, msg)
+}
+
+/* Function: 	Leave
+ *
+ * Description:
+ * 		Implementation of Leave RPC. Every virtual node hands its keys over and leaves
+ *		the ring, then the process shuts down once this RPC has returned.
+ */
+func (h *host) Leave(ctx context.Context, empty *chordpb.Empty) (*chordpb.Empty, error) {
+	err := h.handOff(ctx)
+	if err != nil {
+		return &chordpb.Empty{}, err
+	}
+
+	go func() {
+		h.announceLeave()
+		// let the response to this RPC go out first
+		h.transport.GracefulStop()
+		h.shutdown()
+	}()
+	return &chordpb.Empty{}, nil
+}
@@ -0,0 +1,52 @@
+package chord
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateChordErrors(t *testing.T) {
+	// the port is taken by the ring of TestMain
+	_, err := CreateChord(DefaultConfig("0.0.0.0", 8001))
+	assert.NotNil(t, err, "CreateChord() on a port in use should result in error")
+
+	cfg := DefaultConfig("0.0.0.0", 8081)
+	cfg.KeySize = 12
+	_, err = CreateChord(cfg)
+	assert.NotNil(t, err, "CreateChord() with an invalid key size should result in error")
+
+	_, err = JoinChord(cfg, "0.0.0.0", 8001)
+	assert.NotNil(t, err, "JoinChord() with an invalid key size should result in error")
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&buf)
+
+	cfg := DefaultConfig("0.0.0.0", 8082)
+	cfg.Logger = logger
+	n, err := CreateChord(cfg)
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	n.shutdown()
+	assert.True(t, strings.Contains(buf.String(), "Server is listening on 0.0.0.0:8082"), "the node should log through the configured logger")
+
+	// a node with logging disabled leaves the global logger alone
+	cfg = DefaultConfig("0.0.0.0", 8082)
+	cfg.Logging = false
+	n, err = CreateChord(cfg)
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	n.shutdown()
+	assert.NotEqual(t, ioutil.Discard, log.StandardLogger().Out, "the global logger should not be silenced")
+}
@@ -0,0 +1,72 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeave(t *testing.T) {
+	// Build a separate ring so the nodes in chord_test.go are not disturbed
+	a, err := CreateChord(DefaultConfig("0.0.0.0", 8021))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer a.shutdown()
+	b, err := JoinChord(DefaultConfig("0.0.0.0", 8022), "0.0.0.0", 8021)
+	assert.Nil(t, err, "JoinChord() should not result in error")
+	defer b.shutdown()
+	c, err := JoinChord(DefaultConfig("0.0.0.0", 8023), "0.0.0.0", 8021)
+	assert.Nil(t, err, "JoinChord() should not result in error")
+	defer c.shutdown()
+
+	// Sleep so that nodes stabilize and converge
+	time.Sleep(20 * time.Second)
+
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("leave-key%d", i)
+		err = a.put(context.Background(), keys[i], []byte(keys[i]), ConsistencyOne)
+		assert.Nil(t, err, "put(k,v) should not result in error")
+	}
+
+	// b leaves, its neighbours should relink to each other right away
+	pred := b.predecessor
+	succ := b.successor
+	err = b.Leave(context.Background())
+	assert.Nil(t, err, "leave() should not result in error")
+
+	var p, s *Node
+	for _, n := range []*Node{a, c} {
+		if bytes.Equal(n.Id, pred.Id) {
+			p = n
+		}
+		if bytes.Equal(n.Id, succ.Id) {
+			s = n
+		}
+	}
+	assert.NotNil(t, p, "b's predecessor should be one of the remaining nodes")
+	assert.NotNil(t, s, "b's successor should be one of the remaining nodes")
+	assert.Equal(t, 0, bytes.Compare(p.successor.Id, s.Id), "b's predecessor should relink to b's successor")
+	if s.predecessor != nil {
+		assert.Equal(t, 0, bytes.Compare(s.predecessor.Id, p.Id), "b's successor should relink to b's predecessor")
+	}
+
+	_, ok := s.rgs[idKey(b.Id)]
+	assert.False(t, ok, "b's successor should not be a member of b's replica group anymore")
+
+	// Let the remaining nodes fix their finger tables
+	time.Sleep(5 * time.Second)
+
+	// no key should be lost
+	for _, k := range keys {
+		val, err := a.get(context.Background(), k, ConsistencyOne)
+		assert.Nilf(t, err, "get(%s) should not result in error after a node left", k)
+		assert.Equal(t, 0, bytes.Compare(val.GetValue(), []byte(k)))
+	}
+}
@@ -0,0 +1,607 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	linDuration    = flag.Duration("lin.duration", 16*time.Second, "how long the clients of TestLinearizability run")
+	linConsistency = flag.String("lin.consistency", "quorum", "consistency level of the reads and writes of TestLinearizability")
+	linCrash       = flag.Bool("lin.crash", false, "crash a node in TestLinearizability instead of having it leave")
+)
+
+type requestKind int
+
+const (
+	requestGet requestKind = iota
+	requestPut
+	requestDelete
+)
+
+// a write that failed may or may not have taken effect: it returns at the
+// end of time, so that it can be linearized anywhere after its call
+const unknownReturn = time.Duration(math.MaxInt64)
+
+// the most steps the checker takes on a history before giving up
+const linearizeBudget = 5000000
+
+// historyOp is a request of a client, from its invocation to its response
+type historyOp struct {
+	client int
+	kind   requestKind
+	key    string
+	value  string        // written by a put, or read by a get
+	found  bool          // false if a get or delete did not find the key
+	call   time.Duration // since the start of the history
+	ret    time.Duration // unknownReturn if the outcome of a write is unknown
+}
+
+func (op historyOp) String() string {
+	ret := "?"
+	if op.ret != unknownReturn {
+		ret = fmt.Sprintf("%.3fms", float64(op.ret)/float64(time.Millisecond))
+	}
+	prefix := fmt.Sprintf("[%10.3fms, %10s] client %d: ", float64(op.call)/float64(time.Millisecond), ret, op.client)
+	switch {
+	case op.kind == requestPut:
+		return prefix + fmt.Sprintf("put(%s, %q)", op.key, op.value)
+	case op.kind == requestDelete && op.ret == unknownReturn:
+		return prefix + fmt.Sprintf("delete(%s)", op.key)
+	case op.kind == requestDelete:
+		return prefix + fmt.Sprintf("delete(%s) -> found=%v", op.key, op.found)
+	case !op.found:
+		return prefix + fmt.Sprintf("get(%s) -> not found", op.key)
+	}
+	return prefix + fmt.Sprintf("get(%s) -> %q", op.key, op.value)
+}
+
+// history records the requests of concurrent clients
+type history struct {
+	start time.Time
+	ops   []historyOp
+	mtx   sync.Mutex
+}
+
+func newHistory() *history {
+	return &history{start: time.Now()}
+}
+
+func (h *history) now() time.Duration {
+	return time.Since(h.start)
+}
+
+func (h *history) add(op historyOp) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.ops = append(h.ops, op)
+}
+
+/* Function: 	byKey
+ *
+ * Description:
+ * 		Split the history into the histories of every key. Keys are
+ *		independent registers, so the history is linearizable if and only
+ * 		if the history of every key is.
+ */
+func (h *history) byKey() map[string][]historyOp {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	keys := make(map[string][]historyOp)
+	for _, op := range h.ops {
+		keys[op.key] = append(keys[op.key], op)
+	}
+	return keys
+}
+
+// register is the state of a key in the sequential model of the DHT
+type register struct {
+	value   string
+	present bool
+}
+
+/* Function: 	step
+ *
+ * Description:
+ * 		Apply op to the register. Returns false if the register could not
+ *		have given the response of op.
+ */
+func (r register) step(op historyOp) (bool, register) {
+	switch op.kind {
+	case requestPut:
+		return true, register{value: op.value, present: true}
+	case requestDelete:
+		if op.ret == unknownReturn {
+			return true, register{}
+		}
+		if op.found != r.present {
+			return false, r
+		}
+		return true, register{}
+	}
+	return op.found == r.present && (!op.found || op.value == r.value), r
+}
+
+// linEntry is the call or the return of an operation, in a list ordered by time
+type linEntry struct {
+	op   int
+	call bool
+	time time.Duration
+
+	match *linEntry // the return of a call
+	prev  *linEntry
+	next  *linEntry
+}
+
+// remove a call and its return from the list
+func (e *linEntry) lift() {
+	e.prev.next = e.next
+	if e.next != nil {
+		e.next.prev = e.prev
+	}
+	m := e.match
+	m.prev.next = m.next
+	if m.next != nil {
+		m.next.prev = m.prev
+	}
+}
+
+// put back a call and its return, in the reverse order of lift()
+func (e *linEntry) unlift() {
+	m := e.match
+	m.prev.next = m
+	if m.next != nil {
+		m.next.prev = m
+	}
+	e.prev.next = e
+	if e.next != nil {
+		e.next.prev = e
+	}
+}
+
+/* Function: 	linearizable
+ *
+ * Description:
+ * 		Check whether the history of a key is linearizable. decided is
+ *		false if the checker gave up, see search.
+ */
+func linearizable(ops []historyOp) (ok bool, decided bool) {
+	// A write whose outcome is unknown took effect, if at all, before the
+	// first read of its value returned. If nobody read its value, it can
+	// take effect after every other operation: when the history without
+	// such writes is linearizable, so is the history. Failed writes come
+	// in bursts while the ring changes, and trying every subset of them
+	// would make the search blow up
+	readBy := make(map[string]time.Duration)
+	for _, op := range ops {
+		if op.kind == requestGet && op.found {
+			if ret, ok := readBy[op.value]; !ok || op.ret < ret {
+				readBy[op.value] = op.ret
+			}
+		}
+	}
+	bounded := make([]historyOp, 0, len(ops))
+	pruned := make([]historyOp, 0, len(ops))
+	for _, op := range ops {
+		ret, read := readBy[op.value]
+		if op.kind == requestPut && op.ret == unknownReturn && read {
+			op.ret = ret
+		}
+		bounded = append(bounded, op)
+		if op.kind != requestPut || op.ret != unknownReturn {
+			pruned = append(pruned, op)
+		}
+	}
+	if len(pruned) < len(bounded) {
+		if ok, decided := search(pruned); ok && decided {
+			return true, true
+		}
+	}
+	return search(bounded)
+}
+
+/* Function: 	search
+ *
+ * Description:
+ * 		Check whether a history is linearizable with the algorithm of Wing
+ *		and Gong, as refined by Lowe and used by Porcupine: linearize calls
+ * 		in a depth-first search, backtracking when an operation returned
+ *		before being linearized, and skipping the states already explored.
+ * 		decided is false if the search gave up after linearizeBudget steps.
+ */
+func search(ops []historyOp) (ok bool, decided bool) {
+	entries := make([]*linEntry, 0, 2*len(ops))
+	for i, op := range ops {
+		ret := &linEntry{op: i, time: op.ret}
+		entries = append(entries, &linEntry{op: i, call: true, time: op.call, match: ret}, ret)
+	}
+	// an operation called as another returns is taken to be concurrent with it
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].time != entries[j].time {
+			return entries[i].time < entries[j].time
+		}
+		return entries[i].call && !entries[j].call
+	})
+	head := &linEntry{}
+	prev := head
+	for _, e := range entries {
+		prev.next = e
+		e.prev = prev
+		prev = e
+	}
+
+	type frame struct {
+		e     *linEntry
+		state register
+		hash  uint64
+	}
+	type explored struct {
+		linearized []byte
+		state      register
+	}
+	// the sets of linearized operations are told apart by the xor of
+	// random numbers of their operations, and compared on collisions
+	rng := rand.New(rand.NewSource(1))
+	opHash := make([]uint64, len(ops))
+	for i := range opHash {
+		opHash[i] = rng.Uint64()
+	}
+	var stack []frame
+	linearized := make([]byte, (len(ops)+7)/8)
+	seen := make(map[uint64][]explored)
+	state := register{}
+	hash := uint64(0)
+
+	e := head.next
+	for steps := 0; head.next != nil; steps++ {
+		if steps > linearizeBudget {
+			return false, false
+		}
+		if !e.call {
+			// e returned before being linearized
+			if len(stack) == 0 {
+				return false, true
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			state, hash = f.state, f.hash
+			linearized[f.e.op/8] &^= 1 << (f.e.op % 8)
+			f.e.unlift()
+			e = f.e.next
+			continue
+		}
+		if ok, next := state.step(ops[e.op]); ok {
+			linearized[e.op/8] |= 1 << (e.op % 8)
+			nextHash := hash ^ opHash[e.op]
+			known := false
+			for _, x := range seen[nextHash] {
+				if x.state == next && bytes.Equal(x.linearized, linearized) {
+					known = true
+					break
+				}
+			}
+			if !known {
+				seen[nextHash] = append(seen[nextHash], explored{append([]byte(nil), linearized...), next})
+				stack = append(stack, frame{e, state, hash})
+				state, hash = next, nextHash
+				e.lift()
+				e = head.next
+				continue
+			}
+			linearized[e.op/8] &^= 1 << (e.op % 8)
+		}
+		e = e.next
+	}
+	return true, true
+}
+
+/* Function: 	minimalViolation
+ *
+ * Description:
+ * 		Shrink a history that is not linearizable to a sub-history that is
+ *		not linearizable either, but becomes so without any one of its
+ * 		operations other than the writes its reads saw. Those are kept, so
+ *		that no read of a value nobody wrote shows up as a violation.
+ */
+func minimalViolation(ops []historyOp) []historyOp {
+	violates := func(ops []historyOp) bool {
+		ok, decided := linearizable(ops)
+		return decided && !ok
+	}
+	keep := func(ops []historyOp, i int) bool {
+		if ops[i].kind != requestPut {
+			return false
+		}
+		for _, op := range ops {
+			if op.kind == requestGet && op.found && op.value == ops[i].value {
+				return true
+			}
+		}
+		return false
+	}
+
+	sub := append([]historyOp(nil), ops...)
+	sort.Slice(sub, func(i, j int) bool { return sub[i].call < sub[j].call })
+
+	// drop chunks of operations, halving the chunks until single operations
+	for size := len(sub) / 2; size >= 1; size /= 2 {
+		for start := 0; start < len(sub); {
+			end := start + size
+			if end > len(sub) {
+				end = len(sub)
+			}
+			candidate := append([]historyOp(nil), sub[:start]...)
+			for i := start; i < end; i++ {
+				if keep(sub, i) {
+					candidate = append(candidate, sub[i])
+				}
+			}
+			kept := len(candidate) - start
+			candidate = append(candidate, sub[end:]...)
+			if kept < end-start && violates(candidate) {
+				sub = candidate
+				start += kept
+				continue
+			}
+			start = end
+		}
+	}
+	return sub
+}
+
+/* Function: 	checkHistory
+ *
+ * Description:
+ * 		Return the minimal offending sub-history of every key whose history
+ *		is not linearizable, and the keys the checker could not decide.
+ */
+func checkHistory(h *history) (violations map[string][]historyOp, undecided []string) {
+	violations = make(map[string][]historyOp)
+	for key, ops := range h.byKey() {
+		ok, decided := linearizable(ops)
+		switch {
+		case !decided:
+			undecided = append(undecided, key)
+		case !ok:
+			violations[key] = minimalViolation(ops)
+		}
+	}
+	return violations, undecided
+}
+
+func formatHistory(ops []historyOp) string {
+	lines := make([]string, 0, len(ops))
+	for _, op := range ops {
+		lines = append(lines, "\t"+op.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestLinearizabilityChecker(t *testing.T) {
+	ms := time.Millisecond
+	put := func(client int, value string, call, ret time.Duration) historyOp {
+		return historyOp{client: client, kind: requestPut, key: "k", value: value, call: call * ms, ret: ret * ms}
+	}
+	get := func(client int, value string, call, ret time.Duration) historyOp {
+		return historyOp{client: client, kind: requestGet, key: "k", value: value, found: value != "", call: call * ms, ret: ret * ms}
+	}
+	del := func(client int, found bool, call, ret time.Duration) historyOp {
+		return historyOp{client: client, kind: requestDelete, key: "k", found: found, call: call * ms, ret: ret * ms}
+	}
+	lost := func(op historyOp) historyOp {
+		op.ret = unknownReturn
+		return op
+	}
+	check := func(ops ...historyOp) bool {
+		ok, decided := linearizable(ops)
+		assert.True(t, decided, "the checker should decide small histories")
+		return ok
+	}
+
+	assert.True(t, check(), "the empty history is linearizable")
+	assert.True(t, check(put(1, "a", 0, 10), get(2, "a", 20, 30)), "a read after a write should see it")
+	assert.False(t, check(put(1, "a", 0, 10), get(2, "", 20, 30)), "a read after a write should not miss it")
+	assert.True(t, check(put(1, "a", 0, 10), get(2, "", 5, 30)), "a read concurrent with a write may miss it")
+	assert.True(t, check(put(1, "a", 0, 10), put(2, "b", 5, 15), get(3, "a", 20, 30)), "concurrent writes take effect in any order")
+	assert.False(t, check(put(1, "a", 0, 10), put(2, "b", 5, 15), get(3, "a", 20, 30), get(3, "b", 40, 50)),
+		"a register should not go back to an older value")
+	assert.False(t, check(put(1, "a", 0, 10), put(2, "b", 20, 30), get(3, "a", 40, 50)), "a read should not see an overwritten value")
+	assert.True(t, check(put(1, "a", 0, 10), del(2, true, 20, 30), get(3, "", 40, 50), del(3, false, 60, 70)), "deletes remove the key")
+	assert.False(t, check(del(1, true, 0, 10)), "deleting a key that was never written should not find it")
+
+	// a write whose outcome is unknown may take effect at any time after its call, or never
+	assert.True(t, check(put(1, "a", 0, 10), lost(put(2, "b", 20, 30)), get(3, "a", 40, 50), get(3, "b", 60, 70)),
+		"a write with an unknown outcome may take effect late")
+	assert.True(t, check(put(1, "a", 0, 10), lost(put(2, "b", 20, 30)), get(3, "a", 40, 50)),
+		"a write with an unknown outcome may never take effect")
+
+	// the minimal sub-history keeps the stale read and the writes it is about
+	h := newHistory()
+	ops := []historyOp{
+		put(1, "a", 0, 10),
+		get(2, "a", 12, 14),
+		put(3, "x", 15, 16),
+		put(1, "b", 20, 30),
+		get(2, "b", 31, 35),
+		get(3, "b", 32, 33),
+		get(2, "a", 40, 50),
+		put(3, "c", 60, 70),
+		get(1, "c", 80, 90),
+	}
+	for _, op := range ops {
+		h.add(op)
+	}
+	violations, undecided := checkHistory(h)
+	assert.Empty(t, undecided, "the checker should decide small histories")
+	assert.Equal(t, []historyOp{put(1, "a", 0, 10), put(1, "b", 20, 30), get(2, "a", 40, 50)}, violations["k"],
+		"the minimal sub-history should be the overwritten write, the write and the stale read")
+}
+
+/* Function: 	runClient
+ *
+ * Description:
+ * 		Make random requests on the given keys through a random node of the
+ *		ring until stop is closed, recording them in h. Every put writes a
+ * 		value of its own, so that reads tell which write they saw.
+ */
+func runClient(id int, h *history, keys []string, nodes func() []*Node, consistency string, stop chan struct{}) {
+	level, _ := ParseConsistency(consistency)
+	rng := rand.New(rand.NewSource(int64(id)))
+	for seq := 0; ; seq++ {
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Duration(5+rng.Intn(20)) * time.Millisecond):
+		}
+		live := nodes()
+		n := live[rng.Intn(len(live))]
+		op := historyOp{client: id, key: keys[rng.Intn(len(keys))]}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+
+		var err error
+		op.call = h.now()
+		switch r := rng.Intn(10); {
+		case r < 5:
+			op.kind = requestGet
+			var val *chordpb.Value
+			val, err = n.Get(ctx, op.key, level)
+			if err == nil {
+				values := make([]string, 0, len(val.GetSiblings()))
+				for _, s := range val.GetSiblings() {
+					values = append(values, string(s.GetValue()))
+				}
+				// concurrent versions read back as one value no write wrote
+				op.value, op.found = strings.Join(values, "|"), true
+			}
+		case r < 9:
+			op.kind = requestPut
+			op.value = fmt.Sprintf("%d-%d", id, seq)
+			err = n.Put(ctx, op.key, []byte(op.value), level)
+		default:
+			op.kind = requestDelete
+			err = n.Delete(ctx, op.key)
+			op.found = err == nil
+		}
+		op.ret = h.now()
+		cancel()
+
+		switch {
+		case errors.Is(err, ErrKeyNotFound):
+		case err != nil && op.kind == requestGet:
+			// a failed read tells nothing
+			continue
+		case err != nil:
+			op.ret = unknownReturn
+		}
+		h.add(op)
+	}
+}
+
+func TestLinearizability(t *testing.T) {
+	const (
+		numClients = 6
+		numKeys    = 4
+	)
+	network := NewMemoryNetwork()
+
+	first, err := CreateChord(memoryConfig(network, 1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	var (
+		nodes    = []*Node{first}
+		nodesMtx sync.Mutex
+		stopped  []*Node
+	)
+	defer func() {
+		for _, n := range append(nodes, stopped...) {
+			n.shutdown()
+		}
+	}()
+	live := func() []*Node {
+		nodesMtx.Lock()
+		defer nodesMtx.Unlock()
+		return append([]*Node(nil), nodes...)
+	}
+	join := func(port int) bool {
+		n, err := JoinChord(memoryConfig(network, port), "10.0.0.1", 1)
+		assert.Nil(t, err, "JoinChord() should not result in error")
+		if err != nil {
+			return false
+		}
+		nodesMtx.Lock()
+		nodes = append(nodes, n)
+		nodesMtx.Unlock()
+		return true
+	}
+	for port := 2; port <= 3; port++ {
+		if !join(port) {
+			return
+		}
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for !ringConverged(live()) && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("lin-%d", i)
+	}
+	h := newHistory()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 1; i <= numClients; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			runClient(id, h, keys, live, *linConsistency, stop)
+		}(i)
+	}
+
+	// churn while the clients run: two nodes join, then one leaves or crashes
+	step := *linDuration / 4
+	time.Sleep(step)
+	join(4)
+	time.Sleep(step)
+	join(5)
+	time.Sleep(step)
+	nodesMtx.Lock()
+	leaving := nodes[1]
+	nodes = append(nodes[:1], nodes[2:]...)
+	stopped = append(stopped, leaving)
+	nodesMtx.Unlock()
+	if *linCrash {
+		leaving.shutdown()
+	} else {
+		err = leaving.Leave(context.Background())
+		assert.Nil(t, err, "Leave() should not result in error")
+	}
+	time.Sleep(step)
+
+	close(stop)
+	wg.Wait()
+
+	violations, undecided := checkHistory(h)
+	for key, ops := range violations {
+		t.Errorf("history of %s is not linearizable, minimal offending sub-history:\n%s", key, formatHistory(ops))
+	}
+	if len(undecided) > 0 {
+		t.Logf("the checker gave up on the histories of %v", undecided)
+	}
+	t.Logf("checked %d operations on %d keys", len(h.ops), numKeys)
+}
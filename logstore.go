@@ -0,0 +1,264 @@
+package chord
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logStoreDirName = "rgs"
+	logStoreExt     = ".log"
+
+	// compact once the log is at least this big and mostly garbage
+	logStoreCompactMinSize = 1 << 20
+)
+
+// logStore is a disk-backed storage engine. Every write is appended to a
+// log file using the same record format as the write-ahead log; only the
+// offset of each live value is kept in memory. The log is compacted once
+// overwritten and deleted records take up more than half of it.
+type logStore struct {
+	path    string
+	f       *os.File
+	keySize int
+	logger  log.FieldLogger
+
+	index map[string]logEntry
+	size  int64 // total bytes in the log
+	dead  int64 // bytes taken up by overwritten or deleted records
+}
+
+type logEntry struct {
+	off    int64 // offset of the value in the log
+	len    int   // length of the value
+	recLen int64 // length of the whole record
+}
+
+/* Function: 	logStorePath
+ *
+ * Description:
+ * 		Return the path of the log file for store name inside dataDir.
+ */
+func logStorePath(dataDir string, name string) string {
+	return filepath.Join(dataDir, logStoreDirName, name+logStoreExt)
+}
+
+/* Function: 	listLogStores
+ *
+ * Description:
+ * 		Return the names of all log stores inside dataDir.
+ */
+func listLogStores(dataDir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dataDir, logStoreDirName, "*"+logStoreExt))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = strings.TrimSuffix(filepath.Base(f), logStoreExt)
+	}
+	return names, nil
+}
+
+/* Function: 	openLogStore
+ *
+ * Description:
+ * 		Open (or create) the log at path and rebuild the in-memory index.
+ *		A torn record at the end of the log is truncated away.
+ */
+func openLogStore(path string, keySize int, logger log.FieldLogger) (*logStore, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &logStore{path: path, f: f, keySize: keySize, logger: logger, index: make(map[string]logEntry)}
+
+	var start int64
+	good, err := scanRecords(f, logger, func(rec walRecord, end int64) {
+		s.apply(rec, start, end)
+		start = end
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// drop a torn record so new records are not appended after garbage
+	if err = f.Truncate(good); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.size = good
+	return s, nil
+}
+
+/* Function: 	apply
+ *
+ * Description:
+ * 		Update the index for a record stored at [start, end) in the log.
+ */
+func (s *logStore) apply(rec walRecord, start int64, end int64) {
+	if old, ok := s.index[rec.key]; ok {
+		s.dead += old.recLen
+		delete(s.index, rec.key)
+	}
+
+	if rec.op == opPut {
+		s.index[rec.key] = logEntry{off: end - int64(len(rec.value)), len: len(rec.value), recLen: end - start}
+	} else {
+		// the delete record itself is garbage once the key is gone
+		s.dead += end - start
+	}
+}
+
+/* Function: 	append
+ *
+ * Description:
+ * 		Append a record to the log, flush it to stable storage and
+ *		update the index.
+ */
+func (s *logStore) append(rec walRecord) error {
+	buf := encodeRecord(rec)
+	_, err := s.f.WriteAt(buf, s.size)
+	if err != nil {
+		return err
+	}
+	if err = s.f.Sync(); err != nil {
+		return err
+	}
+
+	start := s.size
+	s.size += int64(len(buf))
+	s.apply(rec, start, s.size)
+
+	if s.size >= logStoreCompactMinSize && s.dead > s.size/2 {
+		if err = s.compact(); err != nil {
+			s.logger.Errorf("error compacting %s: %v\n", s.path, err)
+		}
+	}
+	return nil
+}
+
+/* Function: 	compact
+ *
+ * Description:
+ * 		Rewrite the log so that it only contains live values.
+ */
+func (s *logStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]logEntry, len(s.index))
+	var size int64
+	for k, e := range s.index {
+		v := make([]byte, e.len)
+		if _, err = s.f.ReadAt(v, e.off); err != nil {
+			break
+		}
+		buf := encodeRecord(walRecord{op: opPut, key: k, value: v})
+		if _, err = tmp.Write(buf); err != nil {
+			break
+		}
+		size += int64(len(buf))
+		index[k] = logEntry{off: size - int64(e.len), len: e.len, recLen: int64(len(buf))}
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err = os.Rename(tmpPath, s.path); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	s.f.Close()
+	s.f = tmp
+	s.index = index
+	s.size = size
+	s.dead = 0
+	return nil
+}
+
+func (s *logStore) Get(key string) ([]byte, bool) {
+	e, ok := s.index[key]
+	if !ok {
+		return nil, false
+	}
+	v := make([]byte, e.len)
+	_, err := s.f.ReadAt(v, e.off)
+	if err != nil && err != io.EOF {
+		s.logger.Errorf("error reading key %s from %s: %v\n", key, s.path, err)
+		return nil, false
+	}
+	return v, true
+}
+
+func (s *logStore) Put(key string, value []byte) error {
+	return s.append(walRecord{op: opPut, key: key, value: value})
+}
+
+func (s *logStore) Delete(key string) error {
+	if _, ok := s.index[key]; !ok {
+		return nil
+	}
+	return s.append(walRecord{op: opRemove, key: key})
+}
+
+func (s *logStore) Iterate(fn func(key string, value []byte) bool) {
+	for k := range s.index {
+		v, ok := s.Get(k)
+		if !ok {
+			continue
+		}
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+func (s *logStore) RangeByHash(from, to []byte, fn func(key string, value []byte) bool) {
+	for k := range s.index {
+		if !BetweenRightIncl(GetPeerID(k, s.keySize), from, to) {
+			continue
+		}
+		v, ok := s.Get(k)
+		if !ok {
+			continue
+		}
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+func (s *logStore) Len() int {
+	return len(s.index)
+}
+
+func (s *logStore) Close() error {
+	return s.f.Close()
+}
+
+func (s *logStore) Destroy() error {
+	s.f.Close()
+	s.index = make(map[string]logEntry)
+	return os.Remove(s.path)
+}
@@ -0,0 +1,146 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+)
+
+/*
+ * findSuccessor forwards a lookup recursively: every node calls the next one
+ * and waits for the answer, so a slow node anywhere on the way stalls the
+ * whole chain, and the caller only learns the number of hops. In an iterative
+ * lookup the origin asks each node on the way for its closest preceding node
+ * instead, and calls the next one itself. It bounds each call by HopTimeout,
+ * falls back to the alternates the previous node gave if a node does not
+ * answer, and records every node asked along with its latency.
+ */
+
+/* Function: 	nextHop
+ *
+ * Description:
+ * 		Return our successor if it is the successor of id. Otherwise return
+ *		the node we would forward a lookup of id to, along with up to
+ * 		SuccessorListSize other nodes preceding id to fall back to. Also
+ *		returns the round-trip time saved by choosing that node rather than
+ * 		the one preceding id the most, see rttTable.saved.
+ */
+func (n *Node) nextHop(id []byte) (*chordpb.Hop, time.Duration) {
+	n.succMtx.RLock()
+	succ := n.successor
+	n.succMtx.RUnlock()
+	if BetweenRightIncl(id, n.Id, succ.Id) {
+		return &chordpb.Hop{Done: true, Node: succ}, 0
+	}
+
+	node, byID := n.closestPrecedingNodes(id)
+	saved, _ := n.host.rtt.saved(byID, node)
+	hop := &chordpb.Hop{Node: node}
+	exclude := []*chordpb.Node{hop.Node}
+	for len(hop.Alternates) < n.config.SuccessorListSize {
+		alt := n.closestPrecedingNode(id, exclude...)
+		if bytes.Equal(alt.Id, n.Id) {
+			break
+		}
+		hop.Alternates = append(hop.Alternates, alt)
+		exclude = append(exclude, alt)
+	}
+	return hop, saved
+}
+
+/* Function: 	lookupIterative
+ *
+ * Description:
+ * 		Find the successor of id by asking the nodes on the way for the next
+ *		hop ourselves. Each node is asked at most once, so the lookup ends
+ * 		with ErrNoRoute once a node and its alternates all failed or led
+ *		back to nodes already asked. Returns the nodes asked, in order, and
+ * 		the round-trip time saved on the hops to nodes chosen by proximity.
+ */
+func (n *Node) lookupIterative(ctx context.Context, id []byte) (*chordpb.Node, []*chordpb.PathHop, time.Duration, error) {
+	var path []*chordpb.PathHop
+	var saved time.Duration
+	asked := []*chordpb.Node{n.Node}
+	hop, hopSaved := n.nextHop(id)
+	for !hop.Done {
+		var next *chordpb.Hop
+		var nextSaved time.Duration
+		for _, node := range append([]*chordpb.Node{hop.Node}, hop.Alternates...) {
+			if Contains(asked, node) {
+				continue
+			}
+			asked = append(asked, node)
+
+			hopCtx, cancel := context.WithTimeout(ctx, n.hopTimeout())
+			start := time.Now()
+			res, resSaved, err := n.NextHopRPC(hopCtx, node, id)
+			cancel()
+			step := &chordpb.PathHop{Node: node, Latency: int64(time.Since(start))}
+			path = append(path, step)
+			if err != nil {
+				step.Error = err.Error()
+				if ctx.Err() != nil {
+					return nil, path, saved, ctx.Err()
+				}
+				continue
+			}
+			// the time was only saved if the node chosen answered
+			if bytes.Equal(node.Id, hop.Node.Id) {
+				saved += hopSaved
+			}
+			next, nextSaved = res, resSaved
+			break
+		}
+		if next == nil {
+			return nil, path, saved, ErrNoRoute
+		}
+		hop, hopSaved = next, nextSaved
+	}
+	return hop.Node, path, saved, nil
+}
+
+/* Function: 	trace
+ *
+ * Description:
+ * 		Locate the node responsible for a key iteratively, whatever the lookup
+ *		mode, and return the path taken starting with ourselves. A failed
+ * 		lookup returns the path without a successor rather than an error,
+ *		unless ctx expired.
+ */
+func (n *Node) trace(ctx context.Context, key string) (*chordpb.Path, error) {
+	path := &chordpb.Path{Hops: []*chordpb.PathHop{{Node: n.Node}}}
+	hash := GetPeerID(key, n.config.KeySize)
+	if n.responsible(hash) {
+		path.Successor = n.Node
+		return path, nil
+	}
+
+	succ, hops, _, err := n.lookupIterative(ctx, hash)
+	path.Hops = append(path.Hops, hops...)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	path.Successor = succ
+	return path, nil
+}
+
+// hopTimeout bounds each call of an iterative lookup
+func (n *Node) hopTimeout() time.Duration {
+	if n.config.HopTimeout > 0 {
+		return time.Duration(n.config.HopTimeout) * time.Millisecond
+	}
+	return n.grpcOpts.timeout
+}
+
+// answered returns the number of nodes of a path which answered
+func answered(path []*chordpb.PathHop) int {
+	hops := 0
+	for _, step := range path {
+		if step.Error == "" {
+			hops++
+		}
+	}
+	return hops
+}
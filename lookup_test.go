@@ -0,0 +1,136 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Iterative lookups find the same nodes as recursive ones, report the path
+// they took, and route around a node which is too slow to answer
+func TestIterativeLookup(t *testing.T) {
+	const numNodes = 8
+	network := NewMemoryNetwork()
+	faults := NewFaults()
+	iterativeConfig := func(port int) *Config {
+		cfg := memoryConfig(network, port)
+		cfg.Faults = faults
+		cfg.SuccessorListSize = 3
+		cfg.IterativeLookup = true
+		cfg.HopTimeout = 200
+		// route by id alone, so that the path of a lookup is known in advance
+		cfg.FingerCandidates = 1
+		return cfg
+	}
+
+	first, err := CreateChord(iterativeConfig(1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	nodes := []*Node{first}
+	defer func() {
+		for _, n := range nodes {
+			n.shutdown()
+		}
+	}()
+	for i := 2; i <= numNodes; i++ {
+		n, err := JoinChord(iterativeConfig(i), "10.0.0.1", 1)
+		assert.Nil(t, err, "JoinChord() should not result in error")
+		if err != nil {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+	sorted := append([]*Node(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Id, sorted[j].Id) < 0 })
+	// alternates come from the successor lists, which lag behind the successors
+	listsConverged := func() bool {
+		for i, n := range sorted {
+			n.succListMtx.RLock()
+			for j, s := range n.successorList {
+				if !bytes.Equal(s.Id, sorted[(i+j+1)%numNodes].Id) {
+					n.succListMtx.RUnlock()
+					return false
+				}
+			}
+			n.succListMtx.RUnlock()
+		}
+		return true
+	}
+	deadline := time.Now().Add(60 * time.Second)
+	for !listsConverged() && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
+	assert.True(t, listsConverged(), "the successor lists of the nodes should follow the ring")
+	// let the fingers catch up with the ring
+	time.Sleep(time.Duration(first.config.KeySize*first.config.FixFingerInterval) * time.Millisecond)
+	owner := func(key string) *Node {
+		id := GetPeerID(key, first.config.KeySize)
+		for _, n := range sorted {
+			if bytes.Compare(n.Id, id) >= 0 {
+				return n
+			}
+		}
+		return sorted[0]
+	}
+
+	ctx := context.Background()
+	var slow *Node
+	var slowKey string
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		node, err := first.Locate(ctx, key)
+		assert.Nil(t, err, "Locate() should not result in error")
+		if err != nil {
+			return
+		}
+		assert.Equal(t, owner(key).Id, node.Id, "iterative lookups should find the node responsible for the key")
+
+		path, err := first.Trace(ctx, key)
+		assert.Nil(t, err, "Trace() should not result in error")
+		if err != nil {
+			return
+		}
+		assert.Equal(t, first.Id, path.Hops[0].Node.Id, "a trace should start at the node asked for it")
+		assert.Equal(t, owner(key).Id, path.Successor.Id, "a trace should end at the node responsible for the key")
+		for _, hop := range path.Hops[1:] {
+			assert.Empty(t, hop.Error, "every node should answer")
+			assert.True(t, hop.Latency > 0, "the latency of every node asked should be measured")
+		}
+
+		// a node in the middle of the path, which others can route around
+		if slow == nil && len(path.Hops) > 2 {
+			for _, n := range nodes {
+				if bytes.Equal(n.Id, path.Hops[1].Node.Id) {
+					slow, slowKey = n, key
+				}
+			}
+		}
+	}
+	if !assert.NotNil(t, slow, "some lookup should take several hops") {
+		return
+	}
+
+	faults.SetLatency(fmt.Sprintf("%s:%d", slow.Addr, slow.Port), time.Second)
+	path, err := first.Trace(ctx, slowKey)
+	assert.Nil(t, err, "Trace() should not result in error")
+	if err != nil {
+		return
+	}
+	timedOut := false
+	for _, hop := range path.Hops {
+		if bytes.Equal(hop.Node.Id, slow.Id) {
+			timedOut = hop.Error != "" && hop.Latency < int64(time.Second)
+		}
+	}
+	assert.True(t, timedOut, "the slow node should be given up on after the hop timeout")
+	if assert.NotNil(t, path.Successor, "the lookup should fall back to other nodes") {
+		assert.Equal(t, owner(slowKey).Id, path.Successor.Id, "the lookup should still end at the node responsible for the key")
+	}
+}
@@ -0,0 +1,509 @@
+package chord
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+)
+
+const (
+	// an update is piggybacked on gossipRetransmits*log10(members+1) messages
+	// before it is considered disseminated, as in SWIM
+	gossipRetransmits = 4
+	// updates sent per gossip message
+	gossipMaxUpdates = 32
+	// every that many rounds, a process exchanges its whole view rather than
+	// its recent updates, so that updates which were dropped still spread
+	gossipFullSyncRounds = 10
+	// members which failed or left are forgotten after memberRetention, so
+	// that the view does not grow with churn. The memberRemembered of them
+	// declared dead last are kept for remembered to probe
+	memberRetention  = 10 * time.Minute
+	memberRemembered = 16
+)
+
+// membership is the view of the ring a process learns through gossip, in the
+// style of SWIM (Das et al.). Each member is a virtual node, in one of the
+// states of chordpb.MemberState along with its incarnation number. Joins,
+// suspicions, failures and departures are queued as updates, which are
+// piggybacked on the gossip messages exchanged with a few random processes
+// every round. A member that hears it is suspected raises its incarnation
+// and gossips that it is alive, overriding the suspicion.
+type membership struct {
+	self    map[string]bool // ids of our own virtual nodes
+	members map[string]*member
+	queue   []*broadcast
+	leaving bool // our own virtual nodes left the ring, do not refute it
+	rounds  int
+
+	suspicionTimeout time.Duration
+
+	now func() time.Time // replaced by the simulator's virtual clock
+	rng *rand.Rand
+
+	mtx sync.Mutex
+}
+
+type member struct {
+	node        *chordpb.Node
+	state       chordpb.MemberState
+	incarnation uint64
+	since       time.Time // when the member entered its state
+}
+
+// broadcast is an update waiting to be piggybacked on gossip messages
+type broadcast struct {
+	update    *chordpb.Member
+	transmits int
+}
+
+func newMembership(vnodes []*chordpb.Node, suspicionTimeout time.Duration) *membership {
+	m := &membership{
+		self:             make(map[string]bool),
+		members:          make(map[string]*member),
+		suspicionTimeout: suspicionTimeout,
+		now:              time.Now,
+		rng:              rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, node := range vnodes {
+		id := idKey(node.Id)
+		m.self[id] = true
+		m.members[id] = &member{node: node, state: chordpb.MemberState_ALIVE, since: m.now()}
+		m.enqueue(m.members[id].proto())
+	}
+	return m
+}
+
+func (mb *member) proto() *chordpb.Member {
+	return &chordpb.Member{Node: mb.node, State: mb.state, Incarnation: mb.incarnation}
+}
+
+// a member which failed or left is out of the ring until it comes back
+// with a higher incarnation
+func gone(state chordpb.MemberState) bool {
+	return state == chordpb.MemberState_DEAD || state == chordpb.MemberState_LEFT
+}
+
+/* Function: 	supersedes
+ *
+ * Description:
+ * 		Return true if update u overrides what we know of a member. A higher
+ *		incarnation always does, so that a member can refute any rumour about
+ * 		itself. At the same incarnation, a suspicion overrides the member being
+ *		alive and a failure or departure overrides both.
+ */
+func supersedes(u *chordpb.Member, mb *member) bool {
+	if u.Incarnation != mb.incarnation {
+		return u.Incarnation > mb.incarnation
+	}
+	switch u.State {
+	case chordpb.MemberState_SUSPECT:
+		return mb.state == chordpb.MemberState_ALIVE
+	case chordpb.MemberState_DEAD, chordpb.MemberState_LEFT:
+		return !gone(mb.state)
+	}
+	return false
+}
+
+/* Function: 	apply
+ *
+ * Description:
+ * 		Merge an update received through gossip into our view, queueing it to
+ *		be gossiped further if it was news to us. Rumours that one of our own
+ * 		virtual nodes is not alive are refuted. Returns true if the view changed.
+ */
+func (m *membership) apply(u *chordpb.Member) bool {
+	if u.Node == nil || len(u.Node.Id) == 0 {
+		return false
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	id := idKey(u.Node.Id)
+	mb, ok := m.members[id]
+	if m.self[id] {
+		if !m.leaving && u.Incarnation >= mb.incarnation && (u.State != chordpb.MemberState_ALIVE || u.Incarnation > mb.incarnation) {
+			mb.incarnation = u.Incarnation + 1
+			m.enqueue(mb.proto())
+		}
+		return false
+	}
+	if !ok {
+		mb = &member{node: u.Node}
+		m.members[id] = mb
+	} else if !supersedes(u, mb) {
+		return false
+	}
+	if !ok || mb.state != u.State {
+		mb.since = m.now()
+	}
+	mb.node, mb.state, mb.incarnation = u.Node, u.State, u.Incarnation
+	m.enqueue(mb.proto())
+	return true
+}
+
+/* Function: 	discover
+ *
+ * Description:
+ * 		Add a node we learned of through the Chord protocol to our view. It
+ *		announces itself, so it is not gossiped about.
+ */
+func (m *membership) discover(node *chordpb.Node) {
+	if node == nil || len(node.Id) == 0 {
+		return
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	id := idKey(node.Id)
+	if _, ok := m.members[id]; !ok {
+		m.members[id] = &member{node: node, state: chordpb.MemberState_ALIVE, since: m.now()}
+	}
+}
+
+// change the state of a member other than ourselves on our own evidence,
+// keeping its incarnation, if the new state overrides its current one
+func (m *membership) mark(node *chordpb.Node, state chordpb.MemberState) {
+	if node == nil || len(node.Id) == 0 {
+		return
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	id := idKey(node.Id)
+	if m.self[id] {
+		return
+	}
+	mb, ok := m.members[id]
+	if !ok {
+		mb = &member{node: node, state: chordpb.MemberState_ALIVE}
+		m.members[id] = mb
+	}
+	if !supersedes(&chordpb.Member{State: state, Incarnation: mb.incarnation}, mb) {
+		return
+	}
+	mb.state, mb.since = state, m.now()
+	m.enqueue(mb.proto())
+}
+
+/* Function: 	suspect
+ *
+ * Description:
+ * 		Suspect a member that did not answer us. It is declared dead unless it
+ *		refutes the suspicion within the suspicion timeout.
+ */
+func (m *membership) suspect(node *chordpb.Node) {
+	m.mark(node, chordpb.MemberState_SUSPECT)
+}
+
+/* Function: 	suspectAddr
+ *
+ * Description:
+ * 		Suspect every member served by the process at addr.
+ */
+func (m *membership) suspectAddr(addr string) {
+	for _, mb := range m.view() {
+		if memberAddr(mb.Node) == addr && mb.State == chordpb.MemberState_ALIVE {
+			m.suspect(mb.Node)
+		}
+	}
+}
+
+/* Function: 	dead
+ *
+ * Description:
+ * 		Declare a member failed, e.g. once the Chord protocol gave up on it.
+ */
+func (m *membership) dead(node *chordpb.Node) {
+	m.mark(node, chordpb.MemberState_DEAD)
+}
+
+/* Function: 	left
+ *
+ * Description:
+ * 		Record that a member left the ring gracefully.
+ */
+func (m *membership) left(node *chordpb.Node) {
+	m.mark(node, chordpb.MemberState_LEFT)
+}
+
+/* Function: 	leave
+ *
+ * Description:
+ * 		Announce that our own virtual nodes are leaving the ring.
+ */
+func (m *membership) leave() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.leaving = true
+	for _, id := range m.ids() {
+		if !m.self[id] {
+			continue
+		}
+		mb := m.members[id]
+		mb.state, mb.since = chordpb.MemberState_LEFT, m.now()
+		m.enqueue(mb.proto())
+	}
+}
+
+/* Function: 	expire
+ *
+ * Description:
+ * 		Declare dead the members suspected for longer than the suspicion
+ *		timeout, returning them, and forget the members gone for longer
+ * 		than memberRetention.
+ */
+func (m *membership) expire() []*chordpb.Node {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	var expired []*chordpb.Node
+	for _, id := range m.ids() {
+		mb := m.members[id]
+		if mb.state == chordpb.MemberState_SUSPECT && m.now().Sub(mb.since) >= m.suspicionTimeout {
+			mb.state, mb.since = chordpb.MemberState_DEAD, m.now()
+			m.enqueue(mb.proto())
+			expired = append(expired, mb.node)
+		}
+	}
+	m.prune()
+	return expired
+}
+
+/* Function: 	prune
+ *
+ * Description:
+ * 		Forget the members which failed or left more than memberRetention
+ *		ago, but for the memberRemembered declared dead last. A member we
+ * 		forgot is added back if we hear of it again. Called with m.mtx held.
+ */
+func (m *membership) prune() {
+	var dead []string
+	for id, mb := range m.members {
+		if m.self[id] || !gone(mb.state) || m.now().Sub(mb.since) < memberRetention {
+			continue
+		}
+		if mb.state == chordpb.MemberState_DEAD {
+			dead = append(dead, id)
+			continue
+		}
+		delete(m.members, id)
+	}
+	if len(dead) <= memberRemembered {
+		return
+	}
+	sort.Slice(dead, func(i, j int) bool { return m.members[dead[i]].since.After(m.members[dead[j]].since) })
+	for _, id := range dead[memberRemembered:] {
+		delete(m.members, id)
+	}
+}
+
+/* Function: 	failed
+ *
+ * Description:
+ * 		Return true if the member with the given id is known to have failed
+ *		or left the ring.
+ */
+func (m *membership) failed(id []byte) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	mb, ok := m.members[idKey(id)]
+	return ok && gone(mb.state)
+}
+
+/* Function: 	successors
+ *
+ * Description:
+ * 		Return up to k members following id on the ring which are not known
+ *		to have failed, closest first.
+ */
+func (m *membership) successors(id []byte, k int) []*chordpb.Node {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	ids := m.ids()
+	start := sort.Search(len(ids), func(i int) bool { return ids[i] > idKey(id) })
+	var succs []*chordpb.Node
+	for i := 0; i < len(ids) && len(succs) < k; i++ {
+		mb := m.members[ids[(start+i)%len(ids)]]
+		if !gone(mb.state) && !bytes.Equal(mb.node.Id, id) {
+			succs = append(succs, mb.node)
+		}
+	}
+	return succs
+}
+
+/* Function: 	targets
+ *
+ * Description:
+ * 		Pick up to k random processes to gossip with among the members not
+ *		known to have failed, other than the one at self.
+ */
+func (m *membership) targets(k int, self string) []*chordpb.Node {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	var candidates []*chordpb.Node
+	seen := map[string]bool{self: true}
+	for _, id := range m.ids() {
+		mb := m.members[id]
+		addr := memberAddr(mb.node)
+		if !gone(mb.state) && !seen[addr] {
+			seen[addr] = true
+			candidates = append(candidates, mb.node)
+		}
+	}
+	m.rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates
+}
+
+/* Function: 	remembered
+ *
+ * Description:
+ * 		Pick a random member of another process than the one at self to probe
+ *		for a disjoint ring. The members declared dead are picked first: when
+ * 		a partition heals, they are the ones on the other side. Members which
+ *		left the ring are not picked.
+ */
+func (m *membership) remembered(self string) *chordpb.Node {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	var dead, live []*chordpb.Node
+	for _, id := range m.ids() {
+		mb := m.members[id]
+		switch {
+		case memberAddr(mb.node) == self || mb.state == chordpb.MemberState_LEFT:
+		case mb.state == chordpb.MemberState_DEAD:
+			dead = append(dead, mb.node)
+		default:
+			live = append(live, mb.node)
+		}
+	}
+	if len(dead) > 0 {
+		return dead[m.rng.Intn(len(dead))]
+	}
+	if len(live) > 0 {
+		return live[m.rng.Intn(len(live))]
+	}
+	return nil
+}
+
+/* Function: 	round
+ *
+ * Description:
+ * 		Start a gossip round. Returns true if the round exchanges whole views.
+ */
+func (m *membership) round() bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.rounds++
+	return m.rounds%gossipFullSyncRounds == 1
+}
+
+/* Function: 	view
+ *
+ * Description:
+ * 		Return every member we know of, sorted by id.
+ */
+func (m *membership) view() []*chordpb.Member {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	view := make([]*chordpb.Member, 0, len(m.members))
+	for _, id := range m.ids() {
+		view = append(view, m.members[id].proto())
+	}
+	return view
+}
+
+/* Function: 	pending
+ *
+ * Description:
+ * 		Return up to max queued updates to piggyback on a gossip message, the
+ *		least transmitted first. An update is dropped from the queue once it
+ * 		was sent often enough to have reached every member with high probability.
+ */
+func (m *membership) pending(max int) []*chordpb.Member {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	limit := gossipRetransmits * int(math.Ceil(math.Log10(float64(len(m.members)+1))))
+	sort.SliceStable(m.queue, func(i, j int) bool { return m.queue[i].transmits < m.queue[j].transmits })
+
+	var updates []*chordpb.Member
+	kept := m.queue[:0]
+	for _, b := range m.queue {
+		if len(updates) < max {
+			updates = append(updates, b.update)
+			b.transmits++
+		}
+		if b.transmits < limit {
+			kept = append(kept, b)
+		}
+	}
+	m.queue = kept
+	return updates
+}
+
+// queue an update, replacing any older one about the same member. Called
+// with m.mtx held
+func (m *membership) enqueue(u *chordpb.Member) {
+	for i, b := range m.queue {
+		if bytes.Equal(b.update.Node.Id, u.Node.Id) {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			break
+		}
+	}
+	m.queue = append(m.queue, &broadcast{update: u})
+}
+
+// the ids of the members in ring order. Called with m.mtx held
+func (m *membership) ids() []string {
+	ids := make([]string, 0, len(m.members))
+	for id := range m.members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// the address of the process serving a member
+func memberAddr(node *chordpb.Node) string {
+	return node.Addr + ":" + strconv.Itoa(int(node.Port))
+}
+
+/* Function: 	gossip
+ *
+ * Description:
+ * 		Run a round of gossip: declare dead the members whose suspicion timed
+ *		out, then exchange updates with GossipFanout random processes. A process
+ * 		which does not answer is suspected. Every few rounds, starting with the
+ *		first one, whole views are exchanged instead.
+ */
+func (h *host) gossip() {
+	for _, node := range h.membership.expire() {
+		h.logger.Infof("gossip(): %s is dead, it did not refute its suspicion\n", memberAddr(node))
+	}
+
+	full := h.membership.round()
+	self := memberAddr(h.vnodes[0].Node)
+	for _, node := range h.membership.targets(h.config.GossipFanout, self) {
+		msg := &chordpb.GossipMsg{Full: full}
+		if full {
+			msg.Members = h.membership.view()
+		} else {
+			msg.Members = h.membership.pending(gossipMaxUpdates)
+		}
+		reply, err := h.vnodes[0].GossipRPC(node, msg)
+		if err != nil {
+			h.logger.Debugf("gossip(): %s did not answer - %v\n", memberAddr(node), err)
+			h.membership.suspectAddr(memberAddr(node))
+			continue
+		}
+		for _, u := range reply.Members {
+			h.membership.apply(u)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package chord
+
+import (
+	"context"
+	"path"
+	"sync"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MemoryNetwork connects hosts running in the same process without any
+// sockets, e.g. to run hundreds of nodes in one test. Every host of the
+// ring uses a transport of its own, see Transport():
+//
+//	network := chord.NewMemoryNetwork()
+//	cfg.Transport = network.Transport()
+//
+// RPCs behave as they do over gRPC: the callee sees the metadata of the
+// caller, messages are copied, and the caller gives up once its context is
+// done even if the callee is still serving the RPC.
+type MemoryNetwork struct {
+	hosts    map[string]*memoryHost
+	hostsMtx sync.RWMutex
+
+	// drop, if set, loses the RPCs for which it returns true before they
+	// reach the callee, e.g. for the simulator to inject message loss
+	drop func(from, to, method string) bool
+}
+
+// the RPCs served at an address of the network
+type memoryHost struct {
+	srv         chordpb.ChordServer
+	interceptor grpc.UnaryServerInterceptor
+	wg          sync.WaitGroup // RPCs being served
+}
+
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{hosts: make(map[string]*memoryHost)}
+}
+
+/* Function: 	Transport
+ *
+ * Description:
+ * 		Return a new transport on the network, to be used by a single host.
+ */
+func (nw *MemoryNetwork) Transport() Transport {
+	return &memoryTransport{network: nw, clients: make(map[string]*memoryClient)}
+}
+
+/* Function: 	setDrop
+ *
+ * Description:
+ * 		Lose the RPCs for which drop returns true. nil delivers every RPC.
+ */
+func (nw *MemoryNetwork) setDrop(drop func(from, to, method string) bool) {
+	nw.hostsMtx.Lock()
+	defer nw.hostsMtx.Unlock()
+	nw.drop = drop
+}
+
+// return the host at addr serving an RPC from the host at from, counting
+// the RPC until h.wg.Done(). nil if nothing listens at addr or the RPC is lost
+func (nw *MemoryNetwork) acquire(from, addr, method string) *memoryHost {
+	nw.hostsMtx.RLock()
+	defer nw.hostsMtx.RUnlock()
+	if nw.drop != nil && nw.drop(from, addr, method) {
+		return nil
+	}
+	h := nw.hosts[addr]
+	if h != nil {
+		h.wg.Add(1)
+	}
+	return h
+}
+
+// memoryTransport is the transport of one host on a MemoryNetwork
+type memoryTransport struct {
+	network *MemoryNetwork
+	addr    string // the address we listen at, if any
+
+	clients map[string]*memoryClient // nil once stopped
+	mtx     sync.Mutex
+}
+
+func (t *memoryTransport) Listen(addr string, srv chordpb.ChordServer, interceptor grpc.UnaryServerInterceptor) error {
+	t.network.hostsMtx.Lock()
+	defer t.network.hostsMtx.Unlock()
+	if _, ok := t.network.hosts[addr]; ok {
+		return status.Errorf(codes.AlreadyExists, "address %s is already in use", addr)
+	}
+	t.network.hosts[addr] = &memoryHost{srv: srv, interceptor: interceptor}
+	t.addr = addr
+	return nil
+}
+
+func (t *memoryTransport) Client(addr string) (grpc.ClientConnInterface, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.clients == nil {
+		return nil, errTransportStopped
+	}
+	c, ok := t.clients[addr]
+	if !ok {
+		c = &memoryClient{network: t.network, from: t.addr, addr: addr}
+		t.clients[addr] = c
+	}
+	return c, nil
+}
+
+func (t *memoryTransport) Forget(addr string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	delete(t.clients, addr)
+}
+
+func (t *memoryTransport) Connections() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return len(t.clients)
+}
+
+/* Function: 	GracefulStop
+ *
+ * Description:
+ * 		Leave the network, then wait for the RPCs being served to complete.
+ */
+func (t *memoryTransport) GracefulStop() {
+	if h := t.unlisten(); h != nil {
+		h.wg.Wait()
+	}
+}
+
+func (t *memoryTransport) Stop() {
+	t.unlisten()
+
+	t.mtx.Lock()
+	t.clients = nil
+	t.mtx.Unlock()
+}
+
+// remove our address from the network, returning the host served there
+func (t *memoryTransport) unlisten() *memoryHost {
+	t.network.hostsMtx.Lock()
+	defer t.network.hostsMtx.Unlock()
+	h, ok := t.network.hosts[t.addr]
+	if !ok {
+		return nil
+	}
+	delete(t.network.hosts, t.addr)
+	return h
+}
+
+// memoryClient makes RPCs from the host at from to the host at addr of a
+// MemoryNetwork. It is the in-memory counterpart of a grpc.ClientConn
+type memoryClient struct {
+	network *MemoryNetwork
+	from    string
+	addr    string
+}
+
+// memoryStream collects the header set by an RPC handler with grpc.SetHeader
+type memoryStream struct {
+	method string
+	header metadata.MD
+}
+
+func (s *memoryStream) Method() string {
+	return s.method
+}
+
+func (s *memoryStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *memoryStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *memoryStream) SetTrailer(md metadata.MD) error {
+	return nil
+}
+
+/* Function: 	Invoke
+ *
+ * Description:
+ * 		Serve an RPC on the host at c.addr through its interceptor, as
+ *		grpc.ClientConn does for a remote host.
+ */
+func (c *memoryClient) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	call, ok := memoryMethods[path.Base(method)]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	h := c.network.acquire(c.from, c.addr, path.Base(method))
+	if h == nil {
+		return status.Errorf(codes.Unavailable, "no node reachable at %s", c.addr)
+	}
+
+	// the callee sees our outgoing metadata as incoming, and starts without
+	// outgoing metadata of its own
+	md, _ := metadata.FromOutgoingContext(ctx)
+	sctx := metadata.NewIncomingContext(metadata.NewOutgoingContext(ctx, nil), md.Copy())
+	stream := &memoryStream{method: method}
+	sctx = grpc.NewContextWithServerTransportStream(sctx, stream)
+	info := &grpc.UnaryServerInfo{Server: h.srv, FullMethod: method}
+
+	type result struct {
+		resp interface{}
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer h.wg.Done()
+		resp, err := h.interceptor(sctx, proto.Clone(args.(proto.Message)), info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(h.srv, ctx, req)
+		})
+		done <- result{resp, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	for _, opt := range opts {
+		if o, ok := opt.(grpc.HeaderCallOption); ok {
+			*o.HeaderAddr = stream.header
+		}
+	}
+	if res.err != nil {
+		if _, ok := status.FromError(res.err); !ok {
+			return status.FromContextError(res.err).Err()
+		}
+		return res.err
+	}
+	proto.Reset(reply.(proto.Message))
+	proto.Merge(reply.(proto.Message), res.resp.(proto.Message))
+	return nil
+}
+
+func (c *memoryClient) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, status.Errorf(codes.Unimplemented, "streams are not supported by the memory transport")
+}
+
+// memoryMethods calls the handler of each RPC of the Chord service on a server
+var memoryMethods = map[string]func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error){
+	"FindSuccessor": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.FindSuccessor(ctx, req.(*chordpb.PeerID))
+	},
+	"GetPredecessor": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.GetPredecessor(ctx, req.(*chordpb.Empty))
+	},
+	"Notify": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Notify(ctx, req.(*chordpb.Node))
+	},
+	"CheckPredecessor": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.CheckPredecessor(ctx, req.(*chordpb.Empty))
+	},
+	"Probe": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Probe(ctx, req.(*chordpb.Node))
+	},
+	"GetSuccessorList": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.GetSuccessorList(ctx, req.(*chordpb.Empty))
+	},
+	"RecvCoordinatorMsg": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.RecvCoordinatorMsg(ctx, req.(*chordpb.CoordinatorMsg))
+	},
+	"GetKeys": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.GetKeys(ctx, req.(*chordpb.KeysRequest))
+	},
+	"SendReplicas": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.SendReplicas(ctx, req.(*chordpb.ReplicaMsg))
+	},
+	"RemoveReplicas": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.RemoveReplicas(ctx, req.(*chordpb.ReplicaMsg))
+	},
+	"Get": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Get(ctx, req.(*chordpb.Key))
+	},
+	"Put": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Put(ctx, req.(*chordpb.KV))
+	},
+	"Delete": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Delete(ctx, req.(*chordpb.Key))
+	},
+	"Locate": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Locate(ctx, req.(*chordpb.Key))
+	},
+	"NotifyLeave": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.NotifyLeave(ctx, req.(*chordpb.LeaveMsg))
+	},
+	"Leave": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Leave(ctx, req.(*chordpb.Empty))
+	},
+	"GetReplica": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.GetReplica(ctx, req.(*chordpb.ReplicaKey))
+	},
+	"SyncReplicas": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.SyncReplicas(ctx, req.(*chordpb.MerkleTree))
+	},
+	"GetRoutingTable": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.GetRoutingTable(ctx, req.(*chordpb.Empty))
+	},
+	"Gossip": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Gossip(ctx, req.(*chordpb.GossipMsg))
+	},
+	"GetMembers": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.GetMembers(ctx, req.(*chordpb.Empty))
+	},
+	"NextHop": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.NextHop(ctx, req.(*chordpb.PeerID))
+	},
+	"Trace": func(srv chordpb.ChordServer, ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.Trace(ctx, req.(*chordpb.Key))
+	},
+}
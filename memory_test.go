@@ -0,0 +1,148 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// memoryConfig returns the config of a quiet node on network
+func memoryConfig(network *MemoryNetwork, port int) *Config {
+	cfg := DefaultConfig("10.0.0.1", port)
+	cfg.Logging = false
+	cfg.KeySize = 32
+	cfg.Transport = network.Transport()
+	return cfg
+}
+
+// ringConverged returns true once every node's successor is the next node on the ring
+func ringConverged(nodes []*Node) bool {
+	sorted := append([]*Node(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Id, sorted[j].Id) < 0 })
+	for i, n := range sorted {
+		n.succMtx.RLock()
+		succ := n.successor
+		n.succMtx.RUnlock()
+		if succ == nil || !bytes.Equal(succ.Id, sorted[(i+1)%len(sorted)].Id) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryTransport(t *testing.T) {
+	const numNodes = 200
+	network := NewMemoryNetwork()
+
+	first, err := CreateChord(memoryConfig(network, 1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	nodes := []*Node{first}
+	defer func() {
+		for _, n := range nodes {
+			n.shutdown()
+		}
+	}()
+	for i := 2; i <= numNodes; i++ {
+		n, err := JoinChord(memoryConfig(network, i), "10.0.0.1", 1)
+		assert.Nil(t, err, "JoinChord() should not result in error")
+		if err != nil {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+
+	_, err = CreateChord(memoryConfig(network, 1))
+	assert.NotNil(t, err, "CreateChord() at an address in use should result in error")
+
+	// the hops a lookup was forwarded through are returned in the header
+	_, hops, _, err := nodes[numNodes-1].findSuccessorRPC(context.Background(), first.Node, first.Id)
+	assert.Nil(t, err, "findSuccessorRPC() should not result in error")
+	assert.Equal(t, 0, hops, "we should be our own successor without forwarding")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = nodes[1].GetRPC(canceled, first.Node, "key", ConsistencyOne)
+	assert.Equal(t, codes.Canceled, status.Code(err), "an RPC under a canceled context should fail")
+
+	// a node which left the network can not be reached
+	last := nodes[numNodes-1]
+	last.shutdown()
+	_, err = first.CheckPredecessorRPC(last.Node)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "a node which left the network should not answer RPCs")
+	_, err = last.CheckPredecessorRPC(first.Node)
+	assert.NotNil(t, err, "a node which left the network should not make RPCs")
+}
+
+func TestMemoryRing(t *testing.T) {
+	network := NewMemoryNetwork()
+	secretConfig := func(port int, secret string) *Config {
+		cfg := memoryConfig(network, port)
+		cfg.RingSecret = secret
+		cfg.VirtualNodes = 2
+		return cfg
+	}
+
+	a, err := CreateChord(secretConfig(1, "secret"))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer a.shutdown()
+	b, err := JoinChord(secretConfig(2, "secret"), "10.0.0.1", 1)
+	assert.Nil(t, err, "JoinChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer b.shutdown()
+
+	// RPCs are authorized as they are over gRPC
+	_, err = JoinChord(secretConfig(3, "wrong"), "10.0.0.1", 1)
+	assert.NotNil(t, err, "JoinChord() with a wrong ring secret should result in error")
+
+	nodes := append(a.VirtualNodes(), b.VirtualNodes()...)
+	deadline := time.Now().Add(30 * time.Second)
+	for !ringConverged(nodes) && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
+	assert.True(t, ringConverged(nodes), "the successors of the virtual nodes should form a ring")
+
+	// RPCs reach the virtual node they are meant for
+	for _, n := range nodes {
+		client, err := a.getChordClient(n.Node)
+		assert.Nil(t, err, "getChordClient() should not result in error")
+		ctx, cancel := a.rpcContext(context.Background(), n.Node)
+		table, err := client.GetRoutingTable(ctx, &chordpb.Empty{})
+		cancel()
+		assert.Nil(t, err, "GetRoutingTable() should not result in error")
+		if err == nil {
+			assert.True(t, bytes.Equal(n.Id, table.Node.Id), "the RPC should be served by the virtual node it names")
+		}
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("memory-%d", i)
+		err := a.Put(ctx, key, []byte("val"), ConsistencyOne)
+		assert.Nil(t, err, "Put() should not result in error")
+		val, err := b.Get(ctx, key, ConsistencyOne)
+		assert.Nil(t, err, "Get() should not result in error")
+		assert.Equal(t, "val", string(val.GetValue()))
+
+		owner, err := a.Locate(ctx, key)
+		assert.Nil(t, err, "Locate() should not result in error")
+		other, err := b.LocateRPC(&chordpb.Node{Addr: "10.0.0.1", Port: 1}, key)
+		assert.Nil(t, err, "LocateRPC() should not result in error")
+		assert.True(t, bytes.Equal(owner.Id, other.Id), "every node should locate %s on the same node", key)
+	}
+}
@@ -0,0 +1,99 @@
+package chord
+
+import (
+	"bytes"
+
+	"github.com/cdesiniotis/chord/chordpb"
+)
+
+/*
+ * When a partition splits the ring, each side routes around the nodes it can
+ * no longer reach and stabilizes into a ring of its own. Stabilization only
+ * follows successor pointers, so once the partition heals nothing leads one
+ * ring to the other. The functions below remember the members that were given
+ * up on and probe them: a member which answers again and routes our own id to
+ * another node than ourselves belongs to a disjoint ring, and the rings are
+ * merged by relinking every node whose successor lies in the other ring.
+ */
+
+/* Function: 	checkPartition
+ *
+ * Description:
+ * 		Probe a member we remember, preferably one we declared dead. If it
+ *		answers, exchange whole views with it so that gossip revives the
+ * 		members either side had given up on, then let every virtual node
+ *		merge with the ring of the member.
+ */
+func (h *host) checkPartition() {
+	self := memberAddr(h.vnodes[0].Node)
+	node := h.membership.remembered(self)
+	if node == nil {
+		return
+	}
+	if _, err := h.vnodes[0].CheckPredecessorRPC(node); err != nil {
+		return
+	}
+
+	if h.config.GossipInterval > 0 {
+		reply, err := h.vnodes[0].GossipRPC(node, &chordpb.GossipMsg{Members: h.membership.view(), Full: true})
+		if err == nil {
+			for _, u := range reply.Members {
+				h.membership.apply(u)
+			}
+		}
+	}
+	for _, n := range h.vnodes {
+		n.mergeRing(node)
+	}
+}
+
+/* Function: 	mergeRing
+ *
+ * Description:
+ * 		Ask node "other" for the successor of our id in its ring. In our own
+ *		ring that is us, so another node means the rings are disjoint. If that
+ * 		node is closer to us than our successor, it becomes our successor and
+ *		we notify it. Our new successor then hands us the keys it led which
+ * 		we are now responsible for, merging the versions written on either
+ *		side of the partition, see handOverKeys.
+ */
+func (n *Node) mergeRing(other *chordpb.Node) {
+	n.leaveMtx.RLock()
+	defer n.leaveMtx.RUnlock()
+	if n.leaving {
+		return
+	}
+
+	n.succMtx.RLock()
+	succ := n.successor
+	n.succMtx.RUnlock()
+	if succ == nil {
+		return
+	}
+
+	s, err := n.FindSuccessorRPC(other, n.Id)
+	if err != nil || s == nil || len(s.Id) == 0 || bytes.Equal(s.Id, n.Id) {
+		return
+	}
+	if !Between(s.Id, n.Id, succ.Id) {
+		// another node of our ring lies between us and the other ring
+		return
+	}
+
+	// a stale answer may name a node which failed since
+	succList, err := n.GetSuccessorListRPC(s)
+	if err != nil {
+		return
+	}
+
+	n.logger.Infof("mergeRing(): merging with the ring of %v through %v\n", other, s)
+	n.host.metrics.ringMerges.Inc()
+	n.succMtx.Lock()
+	n.successor = s
+	n.succMtx.Unlock()
+	n.host.metrics.successorChanges.Inc()
+	n.host.membership.discover(s)
+	n.reconcileSuccessorList(succList)
+
+	_ = n.NotifyRPC(s)
+}
@@ -0,0 +1,140 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+)
+
+// A partition splits the ring into two rings, which keep serving writes.
+// Once it heals, the rings merge back into one and every key ends up at the
+// node responsible for it, with the versions written on either side
+func TestPartitionMerge(t *testing.T) {
+	const numNodes = 6
+	network := NewMemoryNetwork()
+	faults := NewFaults()
+	mergeConfig := func(port int) *Config {
+		cfg := memoryConfig(network, port)
+		cfg.Faults = faults
+		cfg.SuccessorListSize = 3
+		cfg.GossipInterval = 200
+		cfg.SuspicionTimeout = 1000
+		cfg.MergeInterval = 1000
+		return cfg
+	}
+
+	first, err := CreateChord(mergeConfig(1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	nodes := []*Node{first}
+	defer func() {
+		for _, n := range nodes {
+			n.shutdown()
+		}
+	}()
+	for i := 2; i <= numNodes; i++ {
+		n, err := JoinChord(mergeConfig(i), "10.0.0.1", 1)
+		assert.Nil(t, err, "JoinChord() should not result in error")
+		if err != nil {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+	converge := func(nodes []*Node) bool {
+		deadline := time.Now().Add(60 * time.Second)
+		for !ringConverged(nodes) && time.Now().Before(deadline) {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return ringConverged(nodes)
+	}
+	assert.True(t, converge(nodes), "the successors of the nodes should form a ring")
+
+	// split the ring into every other node, so that no node keeps its successor
+	sorted := append([]*Node(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Id, sorted[j].Id) < 0 })
+	var sides [2][]*Node
+	var addrs [2][]string
+	for i, n := range sorted {
+		sides[i%2] = append(sides[i%2], n)
+		addrs[i%2] = append(addrs[i%2], fmt.Sprintf("%s:%d", n.Addr, n.Port))
+	}
+	faults.Partition(addrs[0], addrs[1])
+	assert.True(t, converge(sides[0]), "the nodes on one side should form a ring of their own")
+	assert.True(t, converge(sides[1]), "the nodes on the other side should form a ring of their own")
+
+	// both rings serve writes of the same key and of keys of their own
+	put := func(n *Node, key, value string) {
+		deadline := time.Now().Add(10 * time.Second)
+		err := n.Put(context.Background(), key, []byte(value), ConsistencyOne)
+		for err != nil && time.Now().Before(deadline) {
+			time.Sleep(200 * time.Millisecond)
+			err = n.Put(context.Background(), key, []byte(value), ConsistencyOne)
+		}
+		assert.Nil(t, err, "Put() should not result in error")
+	}
+	for side, nodes := range sides {
+		put(nodes[0], "shared", fmt.Sprintf("side-%d", side))
+		for i := 0; i < 10; i++ {
+			put(nodes[0], fmt.Sprintf("side-%d-%d", side, i), "v")
+		}
+	}
+
+	faults.Heal()
+	assert.True(t, converge(nodes), "the rings should merge once the partition heals")
+
+	// every key is read from the node responsible for it, however the
+	// versions of the key were spread over the nodes
+	read := func(key string) *chordpb.Value {
+		deadline := time.Now().Add(30 * time.Second)
+		for {
+			val, err := sorted[0].Get(context.Background(), key, ConsistencyOne)
+			if err == nil && (key != "shared" || len(val.Siblings) == 2) || time.Now().After(deadline) {
+				return val
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+	for side := range sides {
+		for i := 0; i < 10; i++ {
+			val := read(fmt.Sprintf("side-%d-%d", side, i))
+			assert.NotNil(t, val, "a key written on either side should be found")
+		}
+	}
+	val := read("shared")
+	if assert.NotNil(t, val, "the key written on both sides should be found") {
+		var values []string
+		for _, s := range val.Siblings {
+			values = append(values, string(s.Value))
+		}
+		sort.Strings(values)
+		assert.Equal(t, []string{"side-0", "side-1"}, values, "the concurrent versions should be kept as siblings")
+	}
+	// members declared dead during the partition refute it by gossip
+	allAlive := func() bool {
+		for _, n := range nodes {
+			for _, m := range n.Members() {
+				if m.State != chordpb.MemberState_ALIVE {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for !allAlive() && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
+	for _, n := range nodes {
+		for _, m := range n.Members() {
+			assert.Equal(t, chordpb.MemberState_ALIVE, m.State, "every node should be alive again once the rings merged")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package chord
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"sort"
+
+	"github.com/cdesiniotis/chord/chordpb"
+)
+
+// The Merkle tree of a replica group has one leaf per range of key hashes,
+// selected by the first byte of the hash. The tree is stored as an array in
+// breadth-first order: the children of node i are 2i+1 and 2i+2, and the
+// leaves are the last merkleLeaves nodes.
+const merkleLeaves = 256
+
+// return the leaf of the Merkle tree holding a key
+func merkleLeaf(key string) int {
+	return int(GetHash(key)[0])
+}
+
+/* Function: 	merkleTree
+ *
+ * Description:
+ * 		Build the Merkle tree over every key of the replica group, including deleted
+ *		ones. A leaf hashes the keys in its range along with all of their versions,
+ * 		so replicas holding the same versions of a range have the same leaf.
+ */
+func (rg *ReplicaGroup) merkleTree() [][]byte {
+	keys := make([][]string, merkleLeaves)
+	add := func(k string, _ []byte) bool {
+		leaf := merkleLeaf(k)
+		keys[leaf] = append(keys[leaf], k)
+		return true
+	}
+	rg.data.Iterate(add)
+	rg.tombstones.Iterate(func(k string, v []byte) bool {
+		if _, ok := rg.data.Get(k); ok {
+			return true
+		}
+		return add(k, v)
+	})
+
+	tree := make([][]byte, 2*merkleLeaves-1)
+	for i, leafKeys := range keys {
+		sort.Strings(leafKeys)
+		h := sha1.New()
+		for _, k := range leafKeys {
+			h.Write(GetHash(k))
+			h.Write(GetHash(string(encodeEntry(rg.entry(k)))))
+		}
+		tree[merkleLeaves-1+i] = h.Sum(nil)
+	}
+	for i := merkleLeaves - 2; i >= 0; i-- {
+		h := sha1.New()
+		h.Write(tree[2*i+1])
+		h.Write(tree[2*i+2])
+		tree[i] = h.Sum(nil)
+	}
+	return tree
+}
+
+/* Function: 	merkleDiff
+ *
+ * Description:
+ * 		Compare two Merkle trees from the root down, only descending into
+ *		subtrees whose hashes differ, and return the leaves which differ.
+ */
+func merkleDiff(a [][]byte, b [][]byte) []uint32 {
+	ranges := make([]uint32, 0)
+	if len(a) != len(b) {
+		// not built the same way, consider every range different
+		for i := 0; i < merkleLeaves; i++ {
+			ranges = append(ranges, uint32(i))
+		}
+		return ranges
+	}
+
+	var walk func(i int)
+	walk = func(i int) {
+		if bytes.Equal(a[i], b[i]) {
+			return
+		}
+		if i >= merkleLeaves-1 {
+			ranges = append(ranges, uint32(i-(merkleLeaves-1)))
+			return
+		}
+		walk(2*i + 1)
+		walk(2*i + 2)
+	}
+	walk(0)
+	return ranges
+}
+
+// return every version of the keys of the replica group in the given ranges
+func (rg *ReplicaGroup) rangeKVs(ranges []uint32) ([]*chordpb.KV, []*chordpb.KV) {
+	inRange := make(map[int]bool, len(ranges))
+	for _, r := range ranges {
+		inRange[int(r)] = true
+	}
+
+	kvs := make([]*chordpb.KV, 0)
+	tombstones := make([]*chordpb.KV, 0)
+	rg.data.Iterate(func(k string, v []byte) bool {
+		if inRange[merkleLeaf(k)] {
+			kvs = append(kvs, rg.siblingKVs(k, v)...)
+		}
+		return true
+	})
+	rg.tombstones.Iterate(func(k string, v []byte) bool {
+		if inRange[merkleLeaf(k)] {
+			tombstones = append(tombstones, rg.tombstoneKV(k, v))
+		}
+		return true
+	})
+	return kvs, tombstones
+}
+
+/* Function: 	antiEntropy
+ *
+ * Description:
+ * 		Repair divergence between our replica group and its members. We send each
+ *		member the Merkle tree of our keys, and it answers with its versions of the
+ * 		keys in the ranges that differ. We keep the newest versions of both and send
+ *		them back, so that only the differing ranges are transferred.
+ */
+func (n *Node) antiEntropy() {
+	ourId := idKey(n.Id)
+	n.rgsMtx.RLock()
+	tree := n.rgs[ourId].merkleTree()
+	n.rgsMtx.RUnlock()
+
+	for _, node := range n.replicaNodes() {
+		diff, err := n.SyncReplicasRPC(node, tree)
+		if err != nil {
+			n.logger.Errorf("error calling SyncReplicasRPC(): %v\n", err)
+			continue
+		}
+		if len(diff.Ranges) == 0 {
+			continue
+		}
+		n.logger.Infof("antiEntropy(): repairing %d ranges with %d\n", len(diff.Ranges), node.Id)
+
+		n.rgsMtx.Lock()
+		rg := n.rgs[ourId]
+		err = n.mergeKVs(rg, diff.Kvs, diff.Tombstones)
+		kvs, tombstones := rg.rangeKVs(diff.Ranges)
+		n.rgsMtx.Unlock()
+		if err != nil {
+			n.logger.Errorf("antiEntropy() error storing keys: %v\n", err)
+			continue
+		}
+
+		if len(kvs) > 0 {
+			n.SendReplicasRPC(node, &chordpb.ReplicaMsg{LeaderId: n.Id, Kv: kvs})
+		}
+		if len(tombstones) > 0 {
+			n.RemoveReplicasRPC(node, &chordpb.ReplicaMsg{LeaderId: n.Id, Kv: tombstones})
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerkleDiff(t *testing.T) {
+	a := &ReplicaGroup{data: newMemStore(8), tombstones: newMemStore(8), logger: newLogger(false)}
+	b := &ReplicaGroup{data: newMemStore(8), tombstones: newMemStore(8), logger: newLogger(false)}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%d", i)
+		e := entry{siblings: []sibling{{clock: vclock{}.increment([]byte{1}), value: []byte(key)}}}
+		a.store(key, e)
+		b.store(key, e)
+	}
+	assert.Equal(t, 0, len(merkleDiff(a.merkleTree(), b.merkleTree())), "replicas holding the same versions should not differ")
+
+	// a newer version of a key only differs in the range holding the key
+	b.merge("key3", entry{siblings: []sibling{{clock: vclock{}.increment([]byte{1}).increment([]byte{1}), value: []byte("new")}}})
+	assert.Equal(t, []uint32{uint32(merkleLeaf("key3"))}, merkleDiff(a.merkleTree(), b.merkleTree()))
+
+	kvs, tombstones := b.rangeKVs([]uint32{uint32(merkleLeaf("key3"))})
+	assert.Equal(t, 0, len(tombstones))
+	found := false
+	for _, kv := range kvs {
+		if kv.Key == "key3" {
+			found = bytes.Equal(kv.Value, []byte("new"))
+		}
+	}
+	assert.True(t, found, "rangeKVs() should return the keys in the differing range")
+
+	// deletions count as a difference as well
+	b.store("key5", entry{tombstone: vclock{}.increment([]byte{1}).increment([]byte{1})})
+	ranges := merkleDiff(a.merkleTree(), b.merkleTree())
+	assert.Contains(t, ranges, uint32(merkleLeaf("key5")))
+}
+
+func TestAntiEntropy(t *testing.T) {
+	key := "anti-entropy-key"
+	err := n1.put(context.Background(), key, []byte("val1"), ConsistencyAll)
+	assert.Nil(t, err, "put(k,v) with consistency ALL should not result in error")
+
+	// find the leader for the key and a replica
+	node, err := n1.locate(context.Background(), key)
+	assert.Nil(t, err, "locate(k) should not result in error")
+	var leader, replica *Node
+	for _, n := range []*Node{n1, n2, n3} {
+		if bytes.Equal(n.Id, node.Id) {
+			leader = n
+		} else if replica == nil {
+			replica = n
+		}
+	}
+	assert.NotNil(t, leader, "the key should be led by one of the nodes")
+
+	// the replica missed the write, and holds a key the leader lost
+	lost := "anti-entropy-lost"
+	replica.rgsMtx.Lock()
+	rg := replica.rgs[idKey(leader.Id)]
+	rg.remove(key)
+	rg.store(lost, entry{siblings: []sibling{{clock: vclock{}.increment(leader.Id), value: []byte("val2")}}})
+	replica.rgsMtx.Unlock()
+
+	leader.antiEntropy()
+
+	replica.rgsMtx.RLock()
+	e := replica.rgs[idKey(leader.Id)].entry(key)
+	replica.rgsMtx.RUnlock()
+	assert.Equal(t, 1, len(e.siblings), "anti-entropy should repair a replica which missed a write")
+
+	leader.rgsMtx.RLock()
+	e = leader.rgs[idKey(leader.Id)].entry(lost)
+	tree := leader.rgs[idKey(leader.Id)].merkleTree()
+	leader.rgsMtx.RUnlock()
+	assert.Equal(t, 1, len(e.siblings), "anti-entropy should recover versions only a replica holds")
+
+	replica.rgsMtx.RLock()
+	ranges := merkleDiff(tree, replica.rgs[idKey(leader.Id)].merkleTree())
+	replica.rgsMtx.RUnlock()
+	assert.Equal(t, 0, len(ranges), "the replica group should converge after anti-entropy")
+}
+
+func TestTombstoneExpiry(t *testing.T) {
+	ttl := time.Duration(n1.config.TombstoneTTL) * time.Millisecond
+	clock := vclock{}.increment(n1.Id)
+	expired, recent, unstamped := "expiry-old", "expiry-recent", "expiry-unstamped"
+
+	// every replica of n1 remembers the deletion of expired
+	replicas := make([]*Node, 0)
+	for _, node := range n1.replicaNodes() {
+		for _, n := range []*Node{n2, n3} {
+			if bytes.Equal(n.Id, node.Id) {
+				replicas = append(replicas, n)
+			}
+		}
+	}
+	for _, n := range replicas {
+		n.rgsMtx.Lock()
+		n.rgs[idKey(n1.Id)].store(expired, entry{tombstone: clock})
+		n.rgsMtx.Unlock()
+	}
+	n1.rgsMtx.Lock()
+	rg := n1.rgs[idKey(n1.Id)]
+	rg.tombstones.Put(expired, encodeTombstone(clock, time.Now().Add(-2*ttl)))
+	rg.store(recent, entry{tombstone: clock})
+	rg.tombstones.Put(unstamped, encodeClock(clock))
+	n1.rgsMtx.Unlock()
+
+	// storing the same deletion again keeps its time
+	n1.rgsMtx.Lock()
+	rg.store(expired, entry{tombstone: clock})
+	n1.rgsMtx.Unlock()
+
+	n1.expireTombstones()
+
+	n1.rgsMtx.RLock()
+	_, ok := rg.tombstones.Get(expired)
+	assert.False(t, ok, "the leader should forget a deletion older than TombstoneTTL")
+	_, ok = rg.tombstones.Get(recent)
+	assert.True(t, ok, "the leader should remember a recent deletion")
+	buf, ok := rg.tombstones.Get(unstamped)
+	n1.rgsMtx.RUnlock()
+	if assert.True(t, ok, "a deletion of unknown age should be remembered") {
+		_, at, err := decodeTombstone(buf)
+		assert.Nil(t, err, "decodeTombstone() should not result in error")
+		assert.False(t, at.IsZero(), "a deletion of unknown age should expire TombstoneTTL from now")
+	}
+
+	for _, n := range replicas {
+		n.rgsMtx.RLock()
+		_, ok := n.rgs[idKey(n1.Id)].tombstones.Get(expired)
+		n.rgsMtx.RUnlock()
+		assert.Falsef(t, ok, "%d should forget a deletion its leader forgot", n.Id)
+	}
+
+	n1.rgsMtx.Lock()
+	rg.remove(recent)
+	rg.remove(unstamped)
+	n1.rgsMtx.Unlock()
+}
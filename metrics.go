@@ -0,0 +1,282 @@
+package chord
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metrics of a chord process, shared by all of its virtual nodes. Samples are
+// always recorded; they are only exported if metrics are enabled in the config.
+type metrics struct {
+	registry *prometheus.Registry
+
+	rpcs                *prometheus.CounterVec
+	rpcDuration         *prometheus.HistogramVec
+	lookupHops          prometheus.Histogram
+	lookupRTTSaved      prometheus.Histogram
+	stabilizeDuration   prometheus.Histogram
+	fixFingerDuration   prometheus.Histogram
+	successorChanges    prometheus.Counter
+	predecessorFailures prometheus.Counter
+	ringMerges          prometheus.Counter
+
+	faults       *Faults // served at /faults next to the metrics, if set
+	publicFaults bool    // serve /faults beyond loopback
+
+	server *http.Server
+	stopCh chan struct{}
+	logger log.FieldLogger
+}
+
+/* Function: 	newMetrics
+ *
+ * Description:
+ * 		Create the metrics of a host. Gauges describing the state of the
+ *		virtual nodes are read from the host whenever metrics are gathered.
+ */
+func newMetrics(h *host) *metrics {
+	m := &metrics{
+		logger:       h.logger,
+		faults:       h.config.Faults,
+		publicFaults: h.config.PublicFaults,
+		registry:     prometheus.NewRegistry(),
+		rpcs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chord_rpcs_total",
+			Help: "RPCs served, by method and status code.",
+		}, []string{"method", "code"}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chord_rpc_duration_seconds",
+			Help:    "Time taken to serve RPCs, by method.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"method"}),
+		lookupHops: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "chord_lookup_hops",
+			Help:    "Nodes a key lookup was forwarded through.",
+			Buckets: prometheus.LinearBuckets(0, 1, 16),
+		}),
+		lookupRTTSaved: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "chord_lookup_rtt_saved_seconds",
+			Help:    "Round-trip time saved on each forwarded lookup, over all of its hops, by routing to the nearest candidate of a finger rather than to the first.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+		}),
+		stabilizeDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "chord_stabilize_duration_seconds",
+			Help:    "Time taken by a round of the stabilization protocol.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}),
+		fixFingerDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "chord_fix_finger_duration_seconds",
+			Help:    "Time taken to fix a finger table entry.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}),
+		successorChanges: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chord_successor_changes_total",
+			Help: "Times a virtual node changed its successor.",
+		}),
+		predecessorFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chord_predecessor_failures_total",
+			Help: "Failed predecessors detected.",
+		}),
+		ringMerges: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chord_ring_merges_total",
+			Help: "Times a virtual node relinked to a successor found in a disjoint ring.",
+		}),
+		stopCh: make(chan struct{}),
+	}
+
+	m.registry.MustRegister(m.rpcs, m.rpcDuration, m.lookupHops, m.lookupRTTSaved, m.stabilizeDuration,
+		m.fixFingerDuration, m.successorChanges, m.predecessorFailures, m.ringMerges, &hostCollector{h})
+	return m
+}
+
+/* Function: 	unaryInterceptor
+ *
+ * Description:
+ * 		Count and time every RPC served by the host.
+ */
+func (m *metrics) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	m.rpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	m.rpcs.WithLabelValues(method, status.Code(err).String()).Inc()
+	return resp, err
+}
+
+/* Function: 	start
+ *
+ * Description:
+ * 		Serve the metrics over HTTP at addr and, if dir is set, write them
+ *		to a CSV file in dir every interval. Faults are only served if addr
+ * 		is a loopback address, unless publicFaults is set.
+ */
+func (m *metrics) start(addr string, dir string, interval int) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	// anyone who can reach /faults can partition the ring
+	if m.faults != nil {
+		if tcpAddr, ok := lis.Addr().(*net.TCPAddr); m.publicFaults || ok && tcpAddr.IP.IsLoopback() {
+			mux.Handle("/faults", m.faults)
+		} else {
+			m.logger.Warnf("Not serving /faults on %s, which is not a loopback address\n", lis.Addr())
+		}
+	}
+	m.server = &http.Server{Handler: mux}
+	go func() {
+		err := m.server.Serve(lis)
+		if err != nil && err != http.ErrServerClosed {
+			m.logger.Errorf("error serving metrics: %v\n", err)
+		}
+	}()
+	m.logger.Infof("Serving metrics on http://%s/metrics\n", lis.Addr())
+
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("metrics-%s.csv", strings.ReplaceAll(lis.Addr().String(), ":", "-")))
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.writeCSV(path); err != nil {
+					m.logger.Errorf("error writing metrics to %s: %v\n", path, err)
+				}
+			case <-m.stopCh:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (m *metrics) stop() {
+	close(m.stopCh)
+	if m.server != nil {
+		m.server.Close()
+	}
+}
+
+/* Function: 	writeCSV
+ *
+ * Description:
+ * 		Append the current samples to a CSV file, one row per sample:
+ *		| timestamp | name | labels | value |. Histograms are written as
+ * 		their count and sum.
+ */
+func (m *metrics) writeCSV(path string) error {
+	families, err := m.registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			labels := make([]string, 0, len(metric.Label))
+			for _, l := range metric.Label {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			row := func(name string, value float64) {
+				w.Write([]string{now, name, strings.Join(labels, ";"), strconv.FormatFloat(value, 'g', -1, 64)})
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				row(family.GetName(), metric.Counter.GetValue())
+			case dto.MetricType_GAUGE:
+				row(family.GetName(), metric.Gauge.GetValue())
+			case dto.MetricType_HISTOGRAM:
+				row(family.GetName()+"_count", float64(metric.Histogram.GetSampleCount()))
+				row(family.GetName()+"_sum", metric.Histogram.GetSampleSum())
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// hostCollector reports the state of a host's virtual nodes when metrics are gathered
+type hostCollector struct {
+	h *host
+}
+
+var (
+	replicaGroupsDesc = prometheus.NewDesc("chord_replica_groups",
+		"Replica groups a virtual node is a member of.", []string{"vnode"}, nil)
+	storedKeysDesc = prometheus.NewDesc("chord_stored_keys",
+		"Keys stored in a replica group, by the ID of its leader.", []string{"vnode", "group"}, nil)
+	connPoolDesc = prometheus.NewDesc("chord_conn_pool_size",
+		"Open connections to other nodes.", nil, nil)
+	membersDesc = prometheus.NewDesc("chord_members",
+		"Members of the ring known through gossip, by state.", []string{"state"}, nil)
+	peerRTTDesc = prometheus.NewDesc("chord_peer_rtt_seconds",
+		"Smoothed round-trip time to other nodes, by address.", []string{"peer"}, nil)
+)
+
+func (c *hostCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- replicaGroupsDesc
+	ch <- storedKeysDesc
+	ch <- connPoolDesc
+	ch <- membersDesc
+	ch <- peerRTTDesc
+}
+
+func (c *hostCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, n := range c.h.vnodes {
+		vnode := idKey(n.Id)
+		n.rgsMtx.RLock()
+		ch <- prometheus.MustNewConstMetric(replicaGroupsDesc, prometheus.GaugeValue, float64(len(n.rgs)), vnode)
+		for group, rg := range n.rgs {
+			ch <- prometheus.MustNewConstMetric(storedKeysDesc, prometheus.GaugeValue, float64(rg.data.Len()), vnode, group)
+		}
+		n.rgsMtx.RUnlock()
+	}
+
+	ch <- prometheus.MustNewConstMetric(connPoolDesc, prometheus.GaugeValue, float64(c.h.transport.Connections()))
+
+	states := make(map[chordpb.MemberState]int)
+	for _, m := range c.h.membership.view() {
+		states[m.State]++
+	}
+	for state, name := range chordpb.MemberState_name {
+		ch <- prometheus.MustNewConstMetric(membersDesc, prometheus.GaugeValue, float64(states[chordpb.MemberState(state)]), strings.ToLower(name))
+	}
+
+	c.h.rtt.mtx.Lock()
+	for peer, s := range c.h.rtt.rtts {
+		ch <- prometheus.MustNewConstMetric(peerRTTDesc, prometheus.GaugeValue, s.srtt.Seconds(), peer)
+	}
+	c.h.rtt.mtx.Unlock()
+}
@@ -0,0 +1,119 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupHops(t *testing.T) {
+	// the successor of our own successor is forwarded through at least one node
+	n1.succMtx.RLock()
+	succ := n1.successor
+	n1.succMtx.RUnlock()
+	node, hops, _, err := n1.findSuccessorHops(context.Background(), succ.Id)
+	assert.Nil(t, err, "findSuccessorHops() should not result in error")
+	assert.Equal(t, 0, hops, "our successor should be found without forwarding")
+	assert.Equal(t, 0, bytes.Compare(node.Id, succ.Id))
+
+	succ2, err := n1.FindSuccessorRPC(succ, succ.Id)
+	assert.Nil(t, err, "FindSuccessorRPC() should not result in error")
+	id := fingerMath(succ2.Id, 0, n1.config.KeySize)
+	_, hops, _, err = n1.findSuccessorHops(context.Background(), id)
+	assert.Nil(t, err, "findSuccessorHops() should not result in error")
+	assert.True(t, hops >= 1, "a lookup past our successor should be forwarded")
+}
+
+func TestMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chord-metrics")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := DefaultConfig("0.0.0.0", 8041)
+	cfg.EnableMetrics = true
+	cfg.MetricsAddr = "127.0.0.1:9041"
+	cfg.MetricsOutputDir = dir
+	cfg.MetricsInterval = 200
+	n, err := CreateChord(cfg)
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer n.shutdown()
+
+	err = n.put(context.Background(), "metrics-key", []byte("val"), ConsistencyOne)
+	assert.Nil(t, err, "put(k,v) should not result in error")
+
+	// wait for a round of stabilization
+	time.Sleep(4 * time.Second)
+
+	resp, err := http.Get("http://127.0.0.1:9041/metrics")
+	assert.Nil(t, err, "the metrics endpoint should be served")
+	if err != nil {
+		return
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, name := range []string{
+		`chord_rpcs_total{code="OK",method="GetPredecessor"}`,
+		"chord_rpc_duration_seconds_bucket",
+		"chord_lookup_hops_count 1",
+		"chord_stabilize_duration_seconds_count",
+		"chord_fix_finger_duration_seconds_count",
+		"chord_successor_changes_total",
+		"chord_predecessor_failures_total 0",
+		"chord_replica_groups",
+		`chord_stored_keys{group="` + idKey(n.Id) + `",vnode="` + idKey(n.Id) + `"} 1`,
+		"chord_conn_pool_size",
+	} {
+		assert.Contains(t, string(body), name)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.csv"))
+	assert.Equal(t, 1, len(files), "samples should be written as CSV")
+	if len(files) == 1 {
+		buf, _ := ioutil.ReadFile(files[0])
+		assert.True(t, strings.Contains(string(buf), ",chord_lookup_hops_count,,1"), "the CSV should hold the samples")
+	}
+}
+
+// Metrics are only served at an address given explicitly, and faults only
+// on a loopback one unless they are made public
+func TestMetricsAddr(t *testing.T) {
+	cfg := DefaultConfig("0.0.0.0", 8042)
+	cfg.EnableMetrics = true
+	_, err := CreateChord(cfg)
+	assert.NotNil(t, err, "CreateChord() should fail if metrics are enabled without an address")
+
+	status := func(addr string, public bool) int {
+		cfg := DefaultConfig("0.0.0.0", 8042)
+		cfg.EnableMetrics = true
+		cfg.MetricsAddr = addr
+		cfg.Faults = NewFaults()
+		cfg.PublicFaults = public
+		n, err := CreateChord(cfg)
+		assert.Nil(t, err, "CreateChord() should not result in error")
+		if err != nil {
+			return 0
+		}
+		defer n.shutdown()
+		resp, err := http.Get("http://127.0.0.1:9042/faults")
+		assert.Nil(t, err, "the metrics endpoint should be served")
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, status("127.0.0.1:9042", false), "faults should be served on a loopback address")
+	assert.Equal(t, http.StatusNotFound, status("0.0.0.0:9042", false), "faults should not be served beyond loopback")
+	assert.Equal(t, http.StatusOK, status("0.0.0.0:9042", true), "public faults should be served on any address")
+}
@@ -2,29 +2,45 @@ package chord
 
 import (
 	"bytes"
+	"context"
 	"errors"
-	"io/ioutil"
-	"net"
-	"os"
-	"os/signal"
-	"strconv"
+	"fmt"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/cdesiniotis/chord/chordpb"
 	log "github.com/sirupsen/logrus"
-	"google.golang.org/grpc"
 )
 
-// Node implements the Chord GRPC Server interface
+var (
+	// ErrKeyNotFound is returned when reading or deleting a key which is not stored
+	ErrKeyNotFound = errors.New("key does not exist in datastore")
+	// ErrNoRoute is returned when the node responsible for a key can not be found
+	ErrNoRoute = errors.New("error finding node storing key")
+
+	errNotResponsible = errors.New("node is not responsible for key")
+)
+
+// Reasons given for the errors above in the status of the RPCs failing with
+// them, see ErrorReason. Unlike the messages, they do not change.
+const (
+	ReasonKeyNotFound    = "KEY_NOT_FOUND"
+	ReasonNoRoute        = "NO_ROUTE"
+	ReasonStaleContext   = "STALE_CONTEXT"
+	ReasonNotResponsible = "NOT_RESPONSIBLE"
+)
+
+// Node is a single (virtual) node on the Chord ring. It serves the Chord
+// gRPC methods its host dispatches to it, and the API of api.go.
 type Node struct {
 	*chordpb.Node
 
 	config *Config
+	logger log.FieldLogger
 
 	predecessor *chordpb.Node
 	predMtx     sync.RWMutex
+	handOver    bool // set when our predecessor changed, until handOverKeys() succeeded
 
 	successor *chordpb.Node
 	succMtx   sync.RWMutex
@@ -35,24 +51,28 @@ type Node struct {
 	fingerTable fingerTable
 	ftMtx       sync.RWMutex
 
-	sock       *net.TCPListener
-	grpcServer *grpc.Server
-	grpcOpts   grpcOpts
-
-	connPool    map[string]*clientConn
-	connPoolMtx sync.RWMutex
+	host     *host // the process serving this virtual node
+	grpcOpts grpcOpts
 
-	rgs    map[uint64]*ReplicaGroup
+	rgs    map[string]*ReplicaGroup
 	rgsMtx sync.RWMutex
 	rgFlag int // set to 1 initially, 0 after node sends its first Coordinator Msg
 
-	signalChannel chan os.Signal
-	shutdownCh    chan struct{}
+	wal *wal // nil if persistence is disabled
+
+	shutdownCh chan struct{}
+
+	leaving  bool // set while handing our keys over in Leave()
+	leaveMtx sync.RWMutex
+
+	detector *failureDetector // of our predecessor
 }
 
 // Node: Representa o estado local de um nó Chord.
 // Contém identificador, ponteiros predecessor/successor, tabela de dedos,
-// lista de sucessores, pool de conexões e estruturas para grupos de réplica.
+// lista de sucessores e estruturas para grupos de réplica. O socket, o
+// servidor gRPC e o pool de conexões pertencem ao host, que pode servir
+// vários nós virtuais no mesmo processo.
 
 // Some constants for readability
 var (
@@ -63,12 +83,23 @@ var (
  *
  * Description:
  * 		Create a new Chord ring and return the first node
- *		in the ring.
+ *		in the ring. Any other virtual nodes of this process
+ * 		join the ring through the first one.
  */
-func CreateChord(config *Config) *Node {
-	n := newNode(config)
+func CreateChord(config *Config) (*Node, error) {
+	h, err := newHost(config)
+	if err != nil {
+		return nil, err
+	}
+	n := h.vnodes[0]
 	n.create()
-	return n
+	for _, vn := range h.vnodes[1:] {
+		err := vn.join(n.Node)
+		if err != nil {
+			h.logger.Errorf("error joining virtual node %d to the new chord ring: %v\n", vn.Id, err)
+		}
+	}
+	return n, nil
 }
 
 // CreateChord: Cria o anel Chord inicial. Retorna o nó que será o primeiro
@@ -79,17 +110,23 @@ func CreateChord(config *Config) *Node {
  * Description:
  * 		Join an existing Chord ring. addr and port specify
  * 		an existing node in the Chord ring. Returns a newly
- * 		created node with its successor set.
+ * 		created node with its successor set. Every virtual
+ *		node of this process joins the ring.
  */
 func JoinChord(config *Config, addr string, port int) (*Node, error) {
-	n := newNode(config)
-	err := n.join(&chordpb.Node{Addr: addr, Port: uint32(port)})
+	h, err := newHost(config)
 	if err != nil {
-		log.Errorf("error joining existing chord ring: %v\n", err)
-		n.shutdown()
 		return nil, err
 	}
-	return n, err
+	for _, vn := range h.vnodes {
+		err := vn.join(&chordpb.Node{Addr: addr, Port: uint32(port)})
+		if err != nil {
+			h.logger.Errorf("error joining existing chord ring: %v\n", err)
+			h.shutdown()
+			return nil, err
+		}
+	}
+	return h.vnodes[0], nil
 }
 
 // JoinChord: Junta este nó a um anel Chord existente. Faz lookup do successor
@@ -98,92 +135,110 @@ func JoinChord(config *Config, addr string, port int) (*Node, error) {
 /* Function: 	newNode
  *
  * Description:
- * 		Create and initialize a new node based on the config.yaml.
- * 		Start all of the necessary threads required by the
- * 		Chord protocol.
+ * 		Create and initialize a new virtual node of host h based on the
+ * 		config.yaml. key is hashed into the node's peer ID. The threads
+ *		required by the Chord protocol are started by start().
  */
-func newNode(config *Config) *Node {
-	// Set timestamp format for the logger
-	log.SetFormatter(&log.TextFormatter{TimestampFormat: "2006-01-02 15:04:05", FullTimestamp: true})
-
+func newNode(h *host, config *Config, key string) (*Node, error) {
 	// Initialize some attributes
 	n := &Node{
 		Node:          &chordpb.Node{Addr: config.Addr, Port: config.Port},
 		config:        config,
+		logger:        h.logger,
 		successorList: make([]*chordpb.Node, config.SuccessorListSize),
-		connPool:      make(map[string]*clientConn),
+		host:          h,
 		grpcOpts: grpcOpts{
 			serverOpts: config.ServerOpts,
 			dialOpts:   config.DialOpts,
 			timeout:    time.Duration(config.Timeout) * time.Millisecond},
-		rgs:           make(map[uint64]*ReplicaGroup),
-		rgFlag:        1,
-		shutdownCh:    make(chan struct{}),
-		signalChannel: make(chan os.Signal, 1),
+		rgs:        make(map[string]*ReplicaGroup),
+		rgFlag:     1,
+		shutdownCh: make(chan struct{}),
+		detector: newFailureDetector(config.FailureThreshold, config.FailureWindow,
+			time.Duration(config.CheckPredecessorInterval)*time.Millisecond),
 	}
 
 	// Get PeerID
-	key := n.Addr + ":" + strconv.Itoa(int(n.Port))
 	n.Id = GetPeerID(key, config.KeySize)
 
+	// Keep the peer ID we had before a restart, and open the write-ahead
+	// log unless the storage engine is durable on its own
+	if config.DataDir != "" {
+		id, err := loadPeerID(config.DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("error reading peer id: %v", err)
+		}
+		if id != nil {
+			n.Id = id
+		} else if err = storePeerID(config.DataDir, n.Id); err != nil {
+			return nil, fmt.Errorf("error storing peer id: %v", err)
+		}
+
+		if config.StorageEngine != StorageLog {
+			w, err := openWal(config.DataDir, n.logger)
+			if err != nil {
+				return nil, fmt.Errorf("error opening data directory: %v", err)
+			}
+			n.wal = w
+		}
+	}
+
 	// Create new finger table
 	n.fingerTable = NewFingerTable(n, config.KeySize)
 
 	// Allocate a RG for us
-	id := BytesToUint64(n.Id)
-	n.rgs[id] = &ReplicaGroup{leaderId: n.Id, data: make(map[string][]byte)}
-
-	// Create a listening socket for the chord grpc server
-	lis, err := net.Listen("tcp", key)
+	id := idKey(n.Id)
+	rg, err := n.newReplicaGroup(n.Id)
 	if err != nil {
-		log.Fatalf("error creating listening socket %v\n", err)
+		n.wal.close()
+		return nil, fmt.Errorf("error creating storage for our replica group: %v", err)
 	}
-	n.sock = lis.(*net.TCPListener)
-
-	// Create and register the chord grpc Server
-	n.grpcServer = grpc.NewServer()
-	chordpb.RegisterChordServer(n.grpcServer, n)
+	n.rgs[id] = rg
 
-	// Thread 1: gRPC Server
-	go func() {
-		err := n.grpcServer.Serve(lis)
+	// Recover the data we stored before a restart
+	if config.DataDir != "" {
+		err := n.restore()
 		if err != nil {
-			log.Fatalf("error bringing up grpc server: %s\n", err)
+			// leave the data directory as we found it
+			n.wal.close()
+			for _, rg := range n.rgs {
+				rg.close()
+			}
+			return nil, fmt.Errorf("error restoring data from %s: %v", config.DataDir, err)
 		}
-	}()
-
-	log.Infof("Server is listening on %v\n", key)
+	}
 
-	// Thread 2: Catch registered signals
-	signal.Notify(n.signalChannel,
-		syscall.SIGHUP,
-		syscall.SIGINT,
-		syscall.SIGTERM,
-		syscall.SIGQUIT)
-	go func() {
-		<-n.signalChannel
-		n.shutdown()
-		os.Exit(0)
-	}()
+	return n, nil
+}
 
-	// Thread 3: Debug
-	// Check config to check if logging is disabled
-	if config.Logging == false {
-		log.SetOutput(ioutil.Discard)
-	} else {
+/* Function: 	start
+ *
+ * Description:
+ * 		Start all of the necessary threads required by the Chord protocol.
+ *		They run until stop() is called.
+ */
+func (n *Node) start() {
+	// Thread 1: Debug
+	if n.config.Logging {
 		go func() {
 			ticker := time.NewTicker(10 * time.Second)
 			for {
 				select {
 				case <-ticker.C:
-					log.Printf("------------\n")
-					PrintNode(n.Node, false, "Self")
-					PrintNode(n.predecessor, false, "Predecessor")
-					PrintNode(n.successor, false, "Successor")
+					n.logger.Printf("------------\n")
+					printNode(n.logger, n.Node, false, "Self")
+					n.predMtx.RLock()
+					pred := n.predecessor
+					n.predMtx.RUnlock()
+					n.succMtx.RLock()
+					succ := n.successor
+					n.succMtx.RUnlock()
+					printNode(n.logger, pred, false, "Predecessor")
+					printNode(n.logger, succ, false, "Successor")
 					PrintSuccessorList(n)
 					PrintReplicaGroupMembership(n)
 					n.PrintFingerTable(false)
-					log.Printf("------------\n")
+					n.logger.Printf("------------\n")
 				case <-n.shutdownCh:
 					ticker.Stop()
 					return
@@ -192,13 +247,14 @@ func newNode(config *Config) *Node {
 		}()
 	}
 
-	// Thread 4: Stabilization protocol
+	// Thread 2: Stabilization protocol
 	go func() {
+		// Must have a successor first prior to running stabilization
+		time.Sleep(3 * time.Second)
 		ticker := time.NewTicker(time.Duration(n.config.StabilizeInterval) * time.Millisecond)
 		for {
 			select {
 			case <-ticker.C:
-
 				n.stabilize()
 			case <-n.shutdownCh:
 				ticker.Stop()
@@ -207,7 +263,7 @@ func newNode(config *Config) *Node {
 		}
 	}()
 
-	// Thread 5: Fix Finger Table periodically
+	// Thread 3: Fix Finger Table periodically
 	go func() {
 		time.Sleep(3 * time.Second)
 		next := 0
@@ -224,7 +280,7 @@ func newNode(config *Config) *Node {
 		}
 	}()
 
-	// Thread 6: Check health status of predecessor
+	// Thread 4: Check health status of predecessor
 	go func() {
 		ticker := time.NewTicker(time.Duration(n.config.CheckPredecessorInterval) * time.Millisecond)
 		for {
@@ -238,39 +294,196 @@ func newNode(config *Config) *Node {
 		}
 	}()
 
-	return n
+	// Thread 5: Compact the write-ahead log periodically
+	if n.wal != nil {
+		go func() {
+			ticker := time.NewTicker(time.Duration(n.config.SnapshotInterval) * time.Millisecond)
+			for {
+				select {
+				case <-ticker.C:
+					n.snapshot()
+				case <-n.shutdownCh:
+					ticker.Stop()
+					return
+				}
+			}
+		}()
+	}
+
+	// Thread 6: Repair divergence with our replica group periodically
+	if n.config.AntiEntropyInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Duration(n.config.AntiEntropyInterval) * time.Millisecond)
+			for {
+				select {
+				case <-ticker.C:
+					n.antiEntropy()
+					if n.config.TombstoneTTL > 0 {
+						n.expireTombstones()
+					}
+				case <-n.shutdownCh:
+					ticker.Stop()
+					return
+				}
+			}
+		}()
+	}
 }
 
-// newNode: inicializa o estado interno e dispara rotinas periódicas:
-// - servidor gRPC
-// - listener de sinais (shutdown)
+// newNode: inicializa o estado interno de um nó virtual; start dispara as
+// rotinas periódicas (o servidor gRPC fica no host, e o tratamento de
+// sinais fica a cargo de quem embute a biblioteca, p.ex. server/main.go):
 // - logger/debug periódicos
 // - stabilize, fixFinger, checkPredecessor (rotinas do protocolo Chord)
+// - snapshots periódicos do write-ahead log (se DataDir estiver configurado)
+// - anti-entropia com o grupo de réplica, comparando árvores de Merkle
 // Comentários específicos nas rotinas explicam as responsabilidades.
 
+/*
+ * Function:	Done
+ *
+ * Description:
+ *		Returns a channel that is closed once the node has shut down.
+ */
+func (n *Node) Done() <-chan struct{} {
+	return n.host.doneCh
+}
+
+/*
+ * Function:	VirtualNodes
+ *
+ * Description:
+ *		Returns every virtual node served by the same process as n,
+ * 		starting with the one that handles RPCs not naming a node.
+ */
+func (n *Node) VirtualNodes() []*Node {
+	return n.host.vnodes
+}
+
+/*
+ * Function:	handOff
+ *
+ * Description:
+ *		The first half of Leave(). Hand all of our primary keys to our successor,
+ * 		which takes over as the leader of our replica group, then tell our
+ *		predecessor to relink to our successor.
+ */
+func (n *Node) handOff(ctx context.Context) error {
+	// stop stabilization so that we do not notify our successor again
+	n.leaveMtx.Lock()
+	n.leaving = true
+	n.leaveMtx.Unlock()
+
+	n.predMtx.RLock()
+	pred := n.predecessor
+	n.predMtx.RUnlock()
+
+	n.succListMtx.RLock()
+	succList := make([]*chordpb.Node, len(n.successorList))
+	copy(succList, n.successorList)
+	n.succListMtx.RUnlock()
+
+	n.succMtx.RLock()
+	succ := n.successor
+	n.succMtx.RUnlock()
+
+	// we are the only node in the ring, there is nobody to hand our keys to
+	if succ == nil || bytes.Equal(succ.Id, n.Id) {
+		n.logger.Infof("handOff(): no other node in the ring\n")
+		return nil
+	}
+
+	// collect our primary keys
+	ourId := idKey(n.Id)
+	msg := &chordpb.LeaveMsg{Node: n.Node, Predecessor: pred}
+	n.rgsMtx.RLock()
+	n.rgs[ourId].data.Iterate(func(k string, v []byte) bool {
+		msg.Kvs = append(msg.Kvs, n.rgs[ourId].siblingKVs(k, v)...)
+		return true
+	})
+	n.rgs[ourId].tombstones.Iterate(func(k string, v []byte) bool {
+		msg.Tombstones = append(msg.Tombstones, n.rgs[ourId].tombstoneKV(k, v))
+		return true
+	})
+	n.rgsMtx.RUnlock()
+
+	// hand our keys to the first successor that is alive
+	var err error
+	for _, node := range append([]*chordpb.Node{succ}, succList...) {
+		if bytes.Equal(node.Id, n.Id) {
+			continue
+		}
+		msg.Successor = node
+		n.logger.Infof("handOff(): handing %d keys to %d\n", len(msg.Kvs), node.Id)
+		err = n.NotifyLeaveRPC(ctx, node, msg)
+		if err == nil {
+			break
+		}
+		n.logger.Errorf("error calling NotifyLeaveRPC(): %v\n", err)
+	}
+	if err != nil {
+		n.leaveMtx.Lock()
+		n.leaving = false
+		n.leaveMtx.Unlock()
+		return err
+	}
+
+	// tell our predecessor to relink to our successor
+	if pred != nil && !bytes.Equal(pred.Id, n.Id) && !bytes.Equal(pred.Id, msg.Successor.Id) {
+		err = n.NotifyLeaveRPC(ctx, pred, &chordpb.LeaveMsg{Node: n.Node, Predecessor: pred, Successor: msg.Successor})
+		if err != nil {
+			// our predecessor will find our successor through its successor list
+			n.logger.Errorf("error calling NotifyLeaveRPC() on predecessor: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// handOff/Leave: sai do anel de forma graciosa. Entrega as chaves primárias ao
+// successor (que assume a liderança do grupo de réplica e reenvia as
+// réplicas), pede ao predecessor para se religar ao successor e só então
+// encerra o nó.
+
 /*
  * Function:	shutdown
  *
  * Description:
- *		Gracefully shutdown a node by performing some cleanup.
+ *		Gracefully shutdown the process serving this node, along with
+ * 		all of its virtual nodes. Safe to call more than once.
  */
 func (n *Node) shutdown() {
-	log.Infof("In shutdown()\n")
-	close(n.shutdownCh)
+	n.host.shutdown()
+}
 
-	log.Infof("Closing grpc server...\n")
-	n.grpcServer.Stop()
+/*
+ * Function:	stop
+ *
+ * Description:
+ *		Stop the periodic threads of this virtual node.
+ */
+func (n *Node) stop() {
+	close(n.shutdownCh)
+}
 
-	n.connPoolMtx.Lock()
-	for addr, cc := range n.connPool {
-		log.Infof("Closing conn %v for addr %v\n", cc, addr)
-		cc.conn.Close()
-		n.connPool[addr] = nil
+/*
+ * Function:	close
+ *
+ * Description:
+ *		Flush and close the storage of this virtual node. Called once
+ * 		the gRPC server has stopped.
+ */
+func (n *Node) close() {
+	if n.wal != nil {
+		n.logger.Infof("Closing write-ahead log\n")
+		n.snapshot()
+		n.wal.close()
 	}
-	n.connPoolMtx.Unlock()
 
-	log.Infof("Closing listening socket\n")
-	n.sock.Close()
+	n.rgsMtx.Lock()
+	for _, rg := range n.rgs {
+		rg.close()
+	}
+	n.rgsMtx.Unlock()
 }
 
 /*
@@ -307,30 +520,43 @@ func (n *Node) join(other *chordpb.Node) error {
 
 	succ, err := n.FindSuccessorRPC(other, n.Id)
 	if err != nil {
-		log.Errorf("error calling FindSuccessorRPC(): %s\n", err)
+		n.logger.Errorf("error calling FindSuccessorRPC(): %s\n", err)
 		return err
 	}
 
+	// Tell our successor which keys we still hold from before a restart,
+	// so that only the keys we missed are sent
+	ourId := idKey(n.Id)
+	n.rgsMtx.RLock()
+	have := make([]*chordpb.KeyDigest, 0, n.rgs[ourId].data.Len())
+	n.rgs[ourId].data.Iterate(func(k string, v []byte) bool {
+		have = append(have, &chordpb.KeyDigest{Key: k, Digest: GetHash(string(v))})
+		return true
+	})
+	n.rgsMtx.RUnlock()
+
 	// Get keys from successor that we are now responsible for
-	kvs, err := n.GetKeysRPC(succ, n.Id)
+	kvs, err := n.GetKeysRPC(succ, n.Id, have)
 	if err != nil {
-		log.Errorf("error callling GetKeysRPC(): %v\n", err)
+		n.logger.Errorf("error callling GetKeysRPC(): %v\n", err)
 		return err
 	}
 
 	// Add keys to our replica group
 	// On the first call to stabilize() we will initiate a leader election
 	// and notify our successor list that we are the new leader
-	ourId := BytesToUint64(n.Id)
+	// Versions we kept from before a restart may be concurrent with the ones
+	// written while we were away, in which case both are kept as siblings
 	n.rgsMtx.Lock()
-	for _, kv := range kvs.Kvs {
-		n.rgs[ourId].data[kv.Key] = kv.Value
+	if err = n.mergeKVs(n.rgs[ourId], kvs.Kvs, kvs.Tombstones); err != nil {
+		n.logger.Errorf("error storing keys: %v\n", err)
 	}
 	n.rgsMtx.Unlock()
 
 	n.succMtx.Lock()
 	n.successor = succ
 	n.succMtx.Unlock()
+	n.host.membership.discover(succ)
 
 	n.initSuccessorList()
 
@@ -354,8 +580,18 @@ func (n *Node) stabilize() {
 	successor.notify(n)
 	*/
 
-	// Must have a successor first prior to running stabilization
-	time.Sleep(3 * time.Second)
+	start := time.Now()
+	defer func() {
+		n.host.metrics.stabilizeDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	// Don't do anything while we are leaving the ring
+	n.leaveMtx.RLock()
+	defer n.leaveMtx.RUnlock()
+	if n.leaving {
+		return
+	}
+
 	n.succMtx.RLock()
 	succ := n.successor
 	n.succMtx.RUnlock()
@@ -373,7 +609,7 @@ func (n *Node) stabilize() {
 	// Get our successors predecessor
 	x, err := n.GetPredecessorRPC(succ)
 	if err != nil || x == nil {
-		log.Errorf("error invoking GetPredecessorRPC: %s\n", err)
+		n.logger.Errorf("error invoking GetPredecessorRPC: %s\n", err)
 		n.removeChordClient(succ)
 		return
 	}
@@ -381,10 +617,11 @@ func (n *Node) stabilize() {
 	// Update our successor if a new node joined between
 	// us and our current successor
 	if x.Id != nil && Between(x.Id, n.Id, succ.Id) {
-		log.Infof("stabilize(): updating our successor to - %v\n", x)
+		n.logger.Infof("stabilize(): updating our successor to - %v\n", x)
 		n.succMtx.Lock()
 		n.successor = x
 		n.succMtx.Unlock()
+		n.host.metrics.successorChanges.Inc()
 	}
 
 	// Notify our successor of our existence
@@ -409,38 +646,52 @@ func (n *Node) stabilize() {
  * 		list with its new successor.
  */
 func (n *Node) updateSuccessorList() {
-	var succ *chordpb.Node
+	n.succMtx.RLock()
+	succ := n.successor
+	n.succMtx.RUnlock()
 
-	index := 0
-	for index < n.config.SuccessorListSize {
-		n.succMtx.RLock()
-		succ = n.successor
-		n.succMtx.RUnlock()
+	// The list may still start with the successor we had before stabilize()
+	// found a closer one, so skip the entries which already failed. Should
+	// every entry fail, fall back to the members gossip knows to follow us
+	n.succListMtx.RLock()
+	candidates := append([]*chordpb.Node{succ}, n.successorList...)
+	n.succListMtx.RUnlock()
+	candidates = append(candidates, n.host.membership.successors(n.Id, n.config.SuccessorListSize)...)
 
-		succList, err := n.GetSuccessorListRPC(succ)
-		if err != nil {
-			log.Errorf("successor failed while calling GetSuccessorListRPC: %v\n", err)
+	var failed []*chordpb.Node
+	for _, node := range candidates {
+		if node == nil || Contains(failed, node) {
+			continue
+		}
+		// do not wait for a node gossip already declared failed to time out
+		if !bytes.Equal(node.Id, n.Id) && n.host.membership.failed(node.Id) {
+			failed = append(failed, node)
+			continue
+		}
+		if len(failed) > 0 {
 			// update successor the next entry in successor table
-			if index == n.config.SuccessorListSize-1 {
-				break
-			}
 			n.succMtx.Lock()
-			n.succListMtx.RLock()
-			n.successor = n.successorList[index+1]
-			n.succListMtx.RUnlock()
+			n.successor = node
 			n.succMtx.Unlock()
-			index++
-		} else {
+			n.host.metrics.successorChanges.Inc()
+		}
+
+		succList, err := n.GetSuccessorListRPC(node)
+		if err == nil {
 			n.reconcileSuccessorList(succList)
-			break
+			return
 		}
+		n.logger.Errorf("successor failed while calling GetSuccessorListRPC: %v\n", err)
+		n.host.membership.suspect(node)
+		failed = append(failed, node)
 	}
-
 }
 
 // updateSuccessorList: atualiza a lista de sucessores periodicamente.
 // Em caso de falha do successor, substitui-o pelo próximo da lista
-// e reconcilia a nova lista com o successor atual.
+// e reconcilia a nova lista com o successor atual. Nós que o gossip já
+// declarou falhos são pulados, e os membros que o gossip conhece depois
+// de nós servem de reserva se a lista inteira falhar.
 
 /*
  * Function:	reconcileSuccessorList
@@ -452,8 +703,10 @@ func (n *Node) updateSuccessorList() {
 func (n *Node) reconcileSuccessorList(succList *chordpb.SuccessorList) {
 	n.succMtx.RLock()
 	succ := n.successor
-	currList := n.successorList
 	n.succMtx.RUnlock()
+	n.succListMtx.RLock()
+	currList := n.successorList
+	n.succListMtx.RUnlock()
 
 	// Remove succList's last element
 	newList := succList.Successors
@@ -465,6 +718,9 @@ func (n *Node) reconcileSuccessorList(succList *chordpb.SuccessorList) {
 	n.succListMtx.Lock()
 	n.successorList = newList
 	n.succListMtx.Unlock()
+	for _, node := range newList {
+		n.host.membership.discover(node)
+	}
 
 	// If successor list changed, initiate leader election
 	same := CompareSuccessorLists(currList, newList)
@@ -480,7 +736,7 @@ func (n *Node) reconcileSuccessorList(succList *chordpb.SuccessorList) {
 		}
 
 		// Send coordinator messages to all successors (members of the replica group)
-		log.Infof("In reconcileSuccessorList() - sending coordinator msg: new %d\t old: %d\n", newLeaderId, oldLeaderId)
+		n.logger.Infof("In reconcileSuccessorList() - sending coordinator msg: new %d\t old: %d\n", newLeaderId, oldLeaderId)
 		for _, node := range newList {
 			n.RecvCoordinatorMsgRPC(node, newLeaderId, oldLeaderId)
 		}
@@ -505,35 +761,59 @@ func (n *Node) reconcileSuccessorList(succList *chordpb.SuccessorList) {
  */
 // TODO: come back to this after implementing replica groups
 func (n *Node) findSuccessor(id []byte) (*chordpb.Node, error) {
+	succ, hops, saved, err := n.findSuccessorHops(context.Background(), id)
+	if err == nil && hops > 0 {
+		n.host.metrics.lookupRTTSaved.Observe(saved.Seconds())
+	}
+	return succ, err
+}
+
+// findSuccessorHops also returns the number of nodes the request was forwarded
+// through, and the round-trip time proximity routing saved over all of its hops
+func (n *Node) findSuccessorHops(ctx context.Context, id []byte) (*chordpb.Node, int, time.Duration, error) {
+	if n.config.IterativeLookup {
+		succ, path, saved, err := n.lookupIterative(ctx, id)
+		return succ, answered(path), saved, err
+	}
+
 	n.succMtx.RLock()
 	succ := n.successor
 	n.succMtx.RUnlock()
 
 	if BetweenRightIncl(id, n.Id, succ.Id) {
-		return succ, nil
+		return succ, 0, 0, nil
 	} else {
 		exclude := []*chordpb.Node{}
-		n2 := n.closestPrecedingNode(id, exclude...)
-		res, err := n.FindSuccessorRPC(n2, id)
+		n2, byID := n.closestPrecedingNodes(id, exclude...)
+		res, hops, saved, err := n.findSuccessorRPC(ctx, n2, id)
+		if err == nil {
+			if s, ok := n.host.rtt.saved(byID, n2); ok {
+				saved += s
+			}
+		}
 
-		// if FindSuccessorRPC timeouts, try next best predecessor
+		// if FindSuccessorRPC timeouts, try next best predecessor. Forwarding
+		// the request to ourselves would only loop until the deadline
 		if err != nil {
 			exclude = append(exclude, n2)
 			n2 = n.closestPrecedingNode(id, exclude...)
-			res, err = n.FindSuccessorRPC(n2, id)
+			if !bytes.Equal(n2.Id, n.Id) {
+				res, hops, saved, err = n.findSuccessorRPC(ctx, n2, id)
+			}
 		}
 
 		if err != nil {
-			return nil, err
+			return nil, 0, 0, err
 		}
 
-		return res, nil
+		return res, hops + 1, saved, nil
 	}
 }
 
 // findSuccessor: procura o successor responsável por um id.
 // Usa BetweenRightIncl para checar intervalo e encaminha para
 // o closest preceding node quando necessário (com retries simples).
+// Com IterativeLookup, o próprio nó percorre o caminho (lookup.go).
 
 /*
  * Function:	closestPrecedingNode
@@ -544,18 +824,32 @@ func (n *Node) findSuccessor(id []byte) (*chordpb.Node, error) {
  * 		if it is in the list "exclude"
  */
 func (n *Node) closestPrecedingNode(id []byte, exclude ...*chordpb.Node) *chordpb.Node {
-	var ftNode *chordpb.Node
+	node, _ := n.closestPrecedingNodes(id, exclude...)
+	return node
+}
+
+/*
+ * Function:	closestPrecedingNodes
+ *
+ * Description:
+ *		Like closestPrecedingNode, but of the candidates of the finger found
+ * 		route to the nearest one, see nearestCandidate. Also returns the
+ * 		node closestPrecedingNode would choose by id alone.
+ */
+func (n *Node) closestPrecedingNodes(id []byte, exclude ...*chordpb.Node) (*chordpb.Node, *chordpb.Node) {
+	var ftNode, ftByID *chordpb.Node
 	var succListNode *chordpb.Node
 
 	// Look in finger table
 	n.ftMtx.RLock()
-	for i := len(id) - 1; i >= 0; i-- {
+	for i := len(n.fingerTable) - 1; i >= 0; i-- {
 		ftEntry := n.fingerTable[i]
 		if Contains(exclude, ftEntry.Node) {
 			continue
 		}
-		if Between(ftEntry.Id, n.Id, id) {
-			ftNode = n.fingerTable[i].Node
+		if Between(ftEntry.Node.Id, n.Id, id) {
+			ftByID = ftEntry.Node
+			ftNode = n.nearestCandidate(ftEntry, id, exclude)
 			break
 		}
 	}
@@ -575,27 +869,43 @@ func (n *Node) closestPrecedingNode(id []byte, exclude ...*chordpb.Node) *chordp
 	}
 	n.succListMtx.RUnlock()
 
+	// The successor list lags behind our successor until the next call
+	// to stabilize(), and the finger table may not know of it yet either
+	if succListNode == nil {
+		n.succMtx.RLock()
+		succ := n.successor
+		n.succMtx.RUnlock()
+		if succ != nil && !Contains(exclude, succ) && Between(succ.Id, n.Id, id) {
+			succListNode = succ
+		}
+	}
+
 	// Check if no node was found in either of the lists
 	if ftNode == nil && succListNode == nil {
-		return n.Node
+		return n.Node, n.Node
 	} else if ftNode == nil {
-		return succListNode
+		return succListNode, succListNode
 	} else if succListNode == nil {
-		return ftNode
+		return ftNode, ftByID
 	}
 
 	// See which node is closer to id
+	byID := succListNode
+	if Between(ftByID.Id, succListNode.Id, id) {
+		byID = ftByID
+	}
 	if Between(ftNode.Id, succListNode.Id, id) {
-		return ftNode
+		return ftNode, byID
 	} else {
-		return succListNode
+		return succListNode, byID
 	}
 
 }
 
 // closestPrecedingNode: procura no finger table e na successor list
 // o nó mais próximo que precede o id. Retorna o próprio nó se
-// não encontrar candidato confiável.
+// não encontrar candidato confiável. Entre os candidatos do finger
+// que precedem o id, escolhe o de menor RTT (proximity routing).
 
 /*
  * Function:	checkPredecessor
@@ -608,46 +918,96 @@ func (n *Node) checkPredecessor() {
 	pred := n.predecessor
 	n.predMtx.RUnlock()
 
-	if pred == nil {
+	// we are our own predecessor in a ring of one node. The check can then
+	// only fail because we are shutting down, and must not drop our own RG
+	if pred == nil || bytes.Equal(pred.Id, n.Id) {
 		return
 	}
 
-	_, err := n.CheckPredecessorRPC(pred)
-	if err != nil {
-		log.Infof("detected predecessor has failed - %v\n", err)
+	n.leaveMtx.RLock()
+	defer n.leaveMtx.RUnlock()
+	if n.leaving {
+		return
+	}
 
-		// transfer data to our RG before deleting it
-		n.moveReplicas(BytesToUint64(pred.Id), BytesToUint64(n.Id))
-		// remove membership to RG whose leader is the failed node
-		id := BytesToUint64(pred.Id)
-		n.removeRgMembership(id)
+	peer := idKey(pred.Id)
+	_, err := n.CheckPredecessorRPC(pred)
+	if err == nil {
+		n.detector.heartbeat(peer)
+		return
+	}
 
-		// initiate new leader election
-		n.succListMtx.RLock()
-		succList := n.successorList
-		n.succListMtx.RUnlock()
-		// send coordinator msg to all
-		log.Infof("In checkPredecessor() - sending coordinator msg: new %d\t old: %d\n", n.Id, pred.Id)
-		for _, node := range succList {
-			n.RecvCoordinatorMsgRPC(node, n.Id, pred.Id)
+	// A lost ping is no evidence of failure: wait until the predecessor
+	// has been silent for unusually long, then ask other nodes to reach it
+	// in case only our link to it is broken. Unless gossip already told
+	// us that it failed
+	if !n.host.membership.failed(pred.Id) {
+		if !n.detector.suspected(peer) {
+			n.logger.Debugf("predecessor did not answer - %v\n", err)
+			return
 		}
+		if n.probeIndirectly(pred) {
+			n.logger.Infof("predecessor only reachable through other nodes - %v\n", err)
+			n.detector.heartbeat(peer)
+			return
+		}
+	}
 
-		// TODO: only send new keys?
-		// transfer data replicas to replica group
-		n.sendAllReplicas()
+	n.logger.Infof("detected predecessor has failed - %v\n", err)
+	n.host.metrics.predecessorFailures.Inc()
+	n.detector.remove(peer)
+	n.host.membership.dead(pred)
 
-		// remove connection to failed predecessor
-		n.removeChordClient(pred)
+	// transfer data to our RG before deleting it
+	n.moveReplicas(pred.Id, n.Id)
 
-		n.predMtx.Lock()
-		n.predecessor = nil
-		n.predMtx.Unlock()
-	}
+	// become the leader of the failed node's keys
+	n.takeOverReplicaGroup(pred.Id)
+
+	// remove connection to failed predecessor
+	n.removeChordClient(pred)
+
+	n.predMtx.Lock()
+	n.predecessor = nil
+	n.predMtx.Unlock()
 }
 
-// checkPredecessor: verifica se o predecessor responde; em caso de falha
-// transfere réplicas, remove membro do grupo de réplica e notifica os
-// sucessores sobre a nova configuração (eleição de líder).
+// checkPredecessor: verifica se o predecessor responde. Uma falha isolada
+// não basta: o predecessor só é suspeito quando o seu silêncio é longo
+// demais segundo o detector phi-accrual, e só é dado como falho se outros
+// sucessores também não o alcançarem (ou de imediato, se o gossip já o
+// declarou falho). Nesse caso transfere réplicas,
+// remove membro do grupo de réplica e notifica os sucessores sobre a
+// nova configuração (eleição de líder).
+
+/*
+ * Function:	probeIndirectly
+ *
+ * Description:
+ *		Ask up to IndirectProbes nodes of our successor list to reach a node
+ * 		we can not reach. Returns true if any of them did.
+ */
+func (n *Node) probeIndirectly(node *chordpb.Node) bool {
+	var helpers []*chordpb.Node
+	for _, other := range n.replicaNodes() {
+		if len(helpers) < n.config.IndirectProbes && !bytes.Equal(other.Id, node.Id) {
+			helpers = append(helpers, other)
+		}
+	}
+
+	results := make(chan error, len(helpers))
+	for _, other := range helpers {
+		go func(other *chordpb.Node) {
+			results <- n.ProbeRPC(other, node)
+		}(other)
+	}
+	for range helpers {
+		if err := <-results; err == nil {
+			return true
+		}
+	}
+	return false
+}
 
 /*
  * Function:	initSuccessorList
@@ -657,11 +1017,14 @@ func (n *Node) checkPredecessor() {
  * 		used by creator of chord ring.
  */
 func (n *Node) initSuccessorList() {
-	n.succMtx.Lock()
+	n.succMtx.RLock()
+	succ := n.successor
+	n.succMtx.RUnlock()
+	n.succListMtx.Lock()
 	for i, _ := range n.successorList {
-		n.successorList[i] = n.successor
+		n.successorList[i] = succ
 	}
-	n.succMtx.Unlock()
+	n.succListMtx.Unlock()
 }
 
 /*
@@ -670,53 +1033,62 @@ func (n *Node) initSuccessorList() {
  * Description:
  *		Get a key's value in the datastore. First locate which
  * 		node in the ring is responsible for the key, then call
- *		GetRPC if the node is remote.
+ *		GetRPC if the node is remote. The responsible node reads
+ * 		as many replicas as the consistency level requires. Versions
+ *		written concurrently are all returned as siblings.
  */
-func (n *Node) get(key string) ([]byte, error) {
-	node, err := n.locate(key)
+func (n *Node) get(ctx context.Context, key string, consistency chordpb.Consistency) (*chordpb.Value, error) {
+	node, err := n.locate(ctx, key)
 	if err != nil {
 		return nil, err
 	}
 
 	if bytes.Compare(n.Id, node.Id) == 0 {
 		// key is stored at current node
-		myId := BytesToUint64(n.Id)
-		n.rgsMtx.RLock()
-		val, ok := n.rgs[myId].data[key]
-		n.rgsMtx.RUnlock()
-
-		if !ok {
-			return nil, errors.New("key does not exist in datastore")
-		}
-
-		return val, nil
+		return n.readReplicas(ctx, key, consistency)
 	} else {
 		// key is stored at a remote node
-		val, err := n.GetRPC(node, key)
+		val, err := n.GetRPC(ctx, node, key, consistency)
 		if err != nil {
-			log.Errorf("error getting a key from a remote node: %s", err)
+			n.logger.Errorf("error getting a key from a remote node: %s", err)
 			return nil, err
 		}
-		return val.Value, nil
+		return val, nil
 	}
 
 }
 
 // get: recupera o valor associado a uma chave.
 // - localiza o nó responsável (locate)
-// - se for o próprio nó, retorna do datastore local do ReplicaGroup
+// - se for o próprio nó, lê do ReplicaGroup local e de tantas réplicas
+//   quanto o nível de consistência exigir, retornando as versões mais novas
+//   (mais de uma se foram escritas concorrentemente)
 // - caso contrário, requisita via RPC ao nó remoto
 
 /*
  * Function:	put
  *
  * Description:
+ *		Put a key-value in the datastore, replacing every version
+ * 		of the key. See putContext.
+ */
+func (n *Node) put(ctx context.Context, key string, value []byte, consistency chordpb.Consistency) error {
+	return n.putContext(ctx, key, value, nil, consistency)
+}
+
+/*
+ * Function:	putContext
+ *
+ * Description:
  *		Put a key-value in the datastore. First locate which
  * 		node in the ring is responsible for the key, then call
- *		PutRPC if the node is remote.
+ *		PutRPC if the node is remote. The write succeeds once as
+ * 		many replicas as the consistency level requires stored it.
+ *		A non-nil context is the clock returned by a read of the key:
+ * 		the write fails if the key has versions the read did not see.
  */
-func (n *Node) put(key string, value []byte) error {
-	node, err := n.locate(key)
+func (n *Node) putContext(ctx context.Context, key string, value []byte, readContext vclock, consistency chordpb.Consistency) error {
+	node, err := n.locate(ctx, key)
 	if err != nil {
 		return err
 	}
@@ -724,42 +1096,149 @@ func (n *Node) put(key string, value []byte) error {
 	if bytes.Compare(n.Id, node.Id) == 0 {
 		// key belongs to current node
 
-		// store kv in our datastore
-		myId := BytesToUint64(n.Id)
-		n.rgsMtx.RLock()
-		n.rgs[myId].data[key] = value
-		n.rgsMtx.RUnlock()
+		// store kv in our datastore under a clock that supersedes
+		// every version we know of
+		myId := idKey(n.Id)
+		n.rgsMtx.Lock()
+		current := n.rgs[myId].entry(key)
+		if readContext != nil && !readContext.descends(current.clock()) {
+			n.rgsMtx.Unlock()
+			return errStaleContext
+		}
+		clock := current.clock().merge(readContext).increment(n.Id)
+		e := entry{siblings: []sibling{{clock: clock, value: value}}}
+		err = n.rgs[myId].store(key, e)
+		if err != nil {
+			n.rgsMtx.Unlock()
+			return err
+		}
+		n.logRecords(entryRecord(n.Id, key, e))
+		n.rgsMtx.Unlock()
 
 		// send kv to our replica group
-		n.sendReplica(key)
-		return nil
+		return n.sendReplica(ctx, &chordpb.KV{Key: key, Value: value, Clock: clock.toProto()}, consistency)
 	} else {
 		// key belongs to remote node
-		_, err := n.PutRPC(node, key, value)
+		_, err := n.PutRPC(ctx, node, key, value, readContext.toProto(), consistency)
 		return err
 	}
 }
 
 // put: armazena uma chave no DHT.
 // - localiza o nó responsável
-// - se for local, grava no ReplicaGroup local e replica para o grupo
+// - se for local, grava no ReplicaGroup local com uma nova versão e
+//   replica para o grupo, esperando as confirmações exigidas
+// - com um contexto (o clock lido por um get), a escrita falha se o líder
+//   tiver versões que o contexto não viu
 // - caso contrário, envia PutRPC ao nó responsável
 
+/*
+ * Function:	delete
+ *
+ * Description:
+ *		Delete a key from the datastore. First locate which
+ * 		node in the ring is responsible for the key, then call
+ *		DeleteRPC if the node is remote.
+ */
+func (n *Node) delete(ctx context.Context, key string) error {
+	node, err := n.locate(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Compare(n.Id, node.Id) == 0 {
+		// key belongs to current node
+
+		// remove kv from our datastore and remember the deletion
+		myId := idKey(n.Id)
+		n.rgsMtx.Lock()
+		_, ok := n.rgs[myId].data.Get(key)
+		if !ok {
+			n.rgsMtx.Unlock()
+			return ErrKeyNotFound
+		}
+		clock := n.rgs[myId].entry(key).clock().increment(n.Id)
+		e := entry{tombstone: clock}
+		err = n.rgs[myId].store(key, e)
+		if err != nil {
+			n.rgsMtx.Unlock()
+			return err
+		}
+		n.logRecords(entryRecord(n.Id, key, e))
+		n.rgsMtx.Unlock()
+
+		// remove kv from our replica group
+		n.removeReplica(key, clock)
+		return nil
+	} else {
+		// key belongs to remote node
+		_, err := n.DeleteRPC(ctx, node, key)
+		return err
+	}
+}
+
+// delete: remove uma chave do DHT.
+// - localiza o nó responsável
+// - se for local, remove do ReplicaGroup local, guarda um tombstone
+//   e propaga a remoção para o grupo de réplica
+// - caso contrário, envia DeleteRPC ao nó responsável
+
 /*
  * Function:	locate
  *
  * Description:
  *		Locate which node in the ring is responsible for a key.
  */
-func (n *Node) locate(key string) (*chordpb.Node, error) {
+func (n *Node) locate(ctx context.Context, key string) (*chordpb.Node, error) {
 	hash := GetPeerID(key, n.config.KeySize)
-	node, err := n.findSuccessor(hash)
+	if n.responsible(hash) {
+		n.host.metrics.lookupHops.Observe(0)
+		return n.Node, nil
+	}
+	node, hops, saved, err := n.findSuccessorHops(ctx, hash)
 	if err != nil || node == nil {
-		log.Errorf("error locating node storing the key %s with hash %d\n", key, hash)
-		return nil, errors.New("error finding node storing key")
+		n.logger.Errorf("error locating node storing the key %s with hash %d\n", key, hash)
+		return nil, ErrNoRoute
+	}
+	// a lookup through a node whose successor pointer is stale ends at us,
+	// while our predecessor took the key over already. Fail rather than
+	// store a key no lookup will find once the ring has stabilized
+	if bytes.Equal(node.Id, n.Id) {
+		n.predMtx.RLock()
+		pred := n.predecessor
+		n.predMtx.RUnlock()
+		if pred != nil {
+			return nil, errNotResponsible
+		}
+	}
+	n.host.metrics.lookupHops.Observe(float64(hops))
+	if hops > 0 {
+		n.host.metrics.lookupRTTSaved.Observe(saved.Seconds())
 	}
 	return node, nil
 }
 
 // locate: calcula hash da chave (GetPeerID) e usa findSuccessor
-// para identificar o nó responsável pela chave no anel.
+// para identificar o nó responsável pela chave no anel. Se a chave
+// está entre o predecessor e o próprio nó, não é preciso perguntar a ninguém.
+
+/*
+ * Function:	responsible
+ *
+ * Description:
+ *		Return true if we are responsible for an id, that is if it lies
+ * 		between our predecessor and us. Alone on the ring, we are
+ *		responsible for every id.
+ */
+func (n *Node) responsible(id []byte) bool {
+	n.predMtx.RLock()
+	pred := n.predecessor
+	n.predMtx.RUnlock()
+	if pred != nil {
+		return BetweenRightIncl(id, pred.Id, n.Id)
+	}
+
+	n.succMtx.RLock()
+	defer n.succMtx.RUnlock()
+	return n.successor == nil || bytes.Equal(n.successor.Id, n.Id)
+}
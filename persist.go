@@ -0,0 +1,524 @@
+package chord
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot"
+	peerIdFileName   = "peer_id"
+)
+
+type walOp byte
+
+const (
+	opPut       walOp = iota + 1 // store every version of a kv in a replica group
+	opDelete                     // remove a kv and remember the clock of its deletion
+	opRemove                     // remove a kv without remembering it
+	opDropGroup                  // drop a replica group and all of its data
+)
+
+type walRecord struct {
+	op       walOp
+	leaderId []byte
+	key      string
+	value    []byte
+}
+
+// wal is an append-only log of every change made to the replica groups
+// of a node. Periodically the state is compacted into a snapshot and the
+// log is truncated.
+type wal struct {
+	dir    string
+	f      *os.File
+	mtx    sync.Mutex
+	logger log.FieldLogger
+}
+
+/* Function: 	openWal
+ *
+ * Description:
+ * 		Open (or create) the write-ahead log stored in dir.
+ */
+func openWal(dir string, logger log.FieldLogger) (*wal, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wal{dir: dir, f: f, logger: logger}, nil
+}
+
+/* Function: 	append
+ *
+ * Description:
+ * 		Append records to the log and flush them to stable storage.
+ *		A nil wal means persistence is disabled.
+ */
+func (w *wal) append(recs ...walRecord) error {
+	if w == nil || len(recs) == 0 {
+		return nil
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.f == nil {
+		return errors.New("write-ahead log is closed")
+	}
+
+	bw := bufio.NewWriter(w.f)
+	for _, rec := range recs {
+		if err := writeRecord(bw, rec); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+/* Function: 	replay
+ *
+ * Description:
+ * 		Call fn for every record in the latest snapshot, followed by
+ *		every record appended to the log since then. A torn record at the
+ * 		end of the log is cut off, so that records appended after it are
+ *		not lost behind it on the next replay.
+ */
+func (w *wal) replay(fn func(walRecord)) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	snap, err := os.Open(filepath.Join(w.dir, snapshotFileName))
+	if err == nil {
+		err = readRecords(snap, w.logger, fn)
+		snap.Close()
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	_, err = w.f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	good, err := scanRecords(w.f, w.logger, func(rec walRecord, end int64) {
+		fn(rec)
+	})
+	if err != nil {
+		return err
+	}
+	return w.f.Truncate(good)
+}
+
+/* Function: 	snapshot
+ *
+ * Description:
+ * 		Atomically replace the snapshot with recs and truncate the log.
+ *		The caller must make sure no records are appended while the
+ * 		snapshot is being written.
+ */
+func (w *wal) snapshot(recs []walRecord) error {
+	if w == nil {
+		return nil
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	tmpPath := filepath.Join(w.dir, snapshotFileName+".tmp")
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(tmp)
+	for _, rec := range recs {
+		if err = writeRecord(bw, rec); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	err = os.Rename(tmpPath, filepath.Join(w.dir, snapshotFileName))
+	if err != nil {
+		return err
+	}
+
+	// everything in the log is now part of the snapshot
+	return w.f.Truncate(0)
+}
+
+/* Function: 	close
+ *
+ * Description:
+ * 		Close the log file.
+ */
+func (w *wal) close() error {
+	if w == nil {
+		return nil
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+/* Function: 	loadPeerID
+ *
+ * Description:
+ * 		Return the peer ID stored in the data directory, or nil if
+ *		this is the first time the node is started.
+ */
+func loadPeerID(dir string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, peerIdFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimSpace(string(b)))
+}
+
+/* Function: 	storePeerID
+ *
+ * Description:
+ * 		Store the peer ID in the data directory so it survives restarts.
+ */
+func storePeerID(dir string, id []byte) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, peerIdFileName), []byte(hex.EncodeToString(id)+"\n"), 0644)
+}
+
+/* Function: 	encodeRecord
+ *
+ * Description:
+ * 		Encode a single record. Each record is framed by its length and
+ *		a CRC-32 checksum of the payload:
+ *		| len (4) | crc (4) | op (1) | leaderId | key | value |
+ *		leaderId, key and value are each prefixed by their uvarint length,
+ *		so the value always ends the record.
+ */
+func encodeRecord(rec walRecord) []byte {
+	buf := make([]byte, 8, 8+1+3*binary.MaxVarintLen64+len(rec.leaderId)+len(rec.key)+len(rec.value))
+	buf = append(buf, byte(rec.op))
+	buf = binary.AppendUvarint(buf, uint64(len(rec.leaderId)))
+	buf = append(buf, rec.leaderId...)
+	buf = binary.AppendUvarint(buf, uint64(len(rec.key)))
+	buf = append(buf, rec.key...)
+	buf = binary.AppendUvarint(buf, uint64(len(rec.value)))
+	buf = append(buf, rec.value...)
+
+	payload := buf[8:]
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	return buf
+}
+
+func writeRecord(w io.Writer, rec walRecord) error {
+	_, err := w.Write(encodeRecord(rec))
+	return err
+}
+
+/* Function: 	readRecords
+ *
+ * Description:
+ * 		Decode records from r until EOF. A torn or corrupt record at the
+ *		tail (e.g. the process crashed mid-write) ends the replay.
+ */
+func readRecords(r io.Reader, logger log.FieldLogger, fn func(walRecord)) error {
+	_, err := scanRecords(r, logger, func(rec walRecord, end int64) {
+		fn(rec)
+	})
+	return err
+}
+
+/* Function: 	scanRecords
+ *
+ * Description:
+ * 		Same as readRecords, but also pass the offset at which each record
+ *		ends to fn. Returns the length of the intact prefix of r.
+ */
+func scanRecords(r io.Reader, logger log.FieldLogger, fn func(rec walRecord, end int64)) (int64, error) {
+	br := bufio.NewReader(r)
+	var header [8]byte
+	var off int64
+	for {
+		_, err := io.ReadFull(br, header[:])
+		if err == io.EOF {
+			return off, nil
+		} else if err == io.ErrUnexpectedEOF {
+			logger.Warnf("scanRecords(): ignoring torn record at end of log\n")
+			return off, nil
+		} else if err != nil {
+			return off, err
+		}
+
+		payload := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+		_, err = io.ReadFull(br, payload)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			logger.Warnf("scanRecords(): ignoring torn record at end of log\n")
+			return off, nil
+		} else if err != nil {
+			return off, err
+		}
+
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			logger.Warnf("scanRecords(): checksum mismatch, ignoring rest of log\n")
+			return off, nil
+		}
+
+		rec, err := decodeRecord(payload)
+		if err != nil {
+			logger.Warnf("scanRecords(): %v, ignoring rest of log\n", err)
+			return off, nil
+		}
+		off += int64(len(header) + len(payload))
+		fn(rec, off)
+	}
+}
+
+func decodeRecord(payload []byte) (walRecord, error) {
+	var rec walRecord
+	if len(payload) == 0 {
+		return rec, errors.New("empty record")
+	}
+	rec.op = walOp(payload[0])
+	payload = payload[1:]
+
+	fields := make([][]byte, 3)
+	for i := range fields {
+		l, n := binary.Uvarint(payload)
+		if n <= 0 || uint64(len(payload)-n) < l {
+			return rec, errors.New("malformed record")
+		}
+		fields[i] = payload[n : n+int(l)]
+		payload = payload[n+int(l):]
+	}
+
+	rec.leaderId = append([]byte{}, fields[0]...)
+	rec.key = string(fields[1])
+	if len(fields[2]) > 0 {
+		rec.value = append([]byte{}, fields[2]...)
+	}
+	return rec, nil
+}
+
+/* Function: 	logRecords
+ *
+ * Description:
+ * 		Persist changes made to our replica groups. Callers hold rgsMtx
+ *		so that records are logged in the same order they are applied.
+ */
+func (n *Node) logRecords(recs ...walRecord) {
+	err := n.wal.append(recs...)
+	if err != nil {
+		n.logger.Errorf("error appending to write-ahead log: %v\n", err)
+	}
+}
+
+/* Function: 	restore
+ *
+ * Description:
+ * 		Rebuild our replica groups from the snapshot and write-ahead log,
+ *		or reopen them from the data directory if the storage engine is
+ * 		durable on its own. Only called from newNode() before any other
+ *		thread is started.
+ */
+func (n *Node) restore() error {
+	if n.wal == nil {
+		return n.reopenReplicaGroups()
+	}
+
+	count := 0
+	err := n.wal.replay(func(rec walRecord) {
+		n.applyRecord(rec)
+		count++
+	})
+	if err != nil {
+		return err
+	}
+	n.logger.Infof("restore(): replayed %d records from %s\n", count, n.config.DataDir)
+	return nil
+}
+
+/* Function: 	applyRecord
+ *
+ * Description:
+ * 		Apply a single logged change to our replica groups.
+ */
+func (n *Node) applyRecord(rec walRecord) {
+	id := idKey(rec.leaderId)
+
+	if rec.op == opDropGroup {
+		if rg, ok := n.rgs[id]; ok {
+			rg.destroy()
+			delete(n.rgs, id)
+		}
+		return
+	}
+
+	rg, ok := n.rgs[id]
+	if !ok {
+		var err error
+		rg, err = n.newReplicaGroup(rec.leaderId)
+		if err != nil {
+			n.logger.Errorf("applyRecord(): error creating RG storage: %v\n", err)
+			return
+		}
+		n.rgs[id] = rg
+	}
+
+	var err error
+	var e entry
+	switch rec.op {
+	case opPut:
+		if e, err = decodeEntry(rec.value); err == nil {
+			err = rg.store(rec.key, e)
+		}
+	case opDelete:
+		var at time.Time
+		if e.tombstone, at, err = decodeTombstone(rec.value); err == nil {
+			err = rg.store(rec.key, e)
+		}
+		// keep the time of the deletion across restarts
+		if err == nil && !at.IsZero() {
+			err = rg.tombstones.Put(rec.key, rec.value)
+		}
+	case opRemove:
+		err = rg.remove(rec.key)
+	}
+	if err != nil {
+		n.logger.Errorf("applyRecord(): error applying record for key %s: %v\n", rec.key, err)
+	}
+}
+
+/* Function: 	entryRecord
+ *
+ * Description:
+ * 		Return the record which restores every version of a key
+ *		we hold after a change to it.
+ */
+func entryRecord(leaderId []byte, key string, e entry) walRecord {
+	switch {
+	case len(e.siblings) > 0:
+		return walRecord{op: opPut, leaderId: leaderId, key: key, value: encodeEntry(e)}
+	case e.tombstone != nil:
+		return walRecord{op: opDelete, leaderId: leaderId, key: key, value: encodeClock(e.tombstone)}
+	}
+	return walRecord{op: opRemove, leaderId: leaderId, key: key}
+}
+
+/* Function: 	reopenReplicaGroups
+ *
+ * Description:
+ * 		Reopen every replica group stored in the data directory by a
+ *		durable storage engine.
+ */
+func (n *Node) reopenReplicaGroups() error {
+	names, err := listLogStores(n.config.DataDir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".data") {
+			continue
+		}
+		leaderId, err := hex.DecodeString(strings.TrimSuffix(name, ".data"))
+		if err != nil {
+			n.logger.Warnf("reopenReplicaGroups(): ignoring unknown store %s\n", name)
+			continue
+		}
+
+		id := idKey(leaderId)
+		if _, ok := n.rgs[id]; ok {
+			continue
+		}
+		rg, err := n.newReplicaGroup(leaderId)
+		if err != nil {
+			return err
+		}
+		n.rgs[id] = rg
+	}
+	n.logger.Infof("restore(): reopened %d replica groups from %s\n", len(n.rgs), n.config.DataDir)
+	return nil
+}
+
+/* Function: 	snapshot
+ *
+ * Description:
+ * 		Compact the write-ahead log by writing out the current contents
+ *		of all our replica groups.
+ */
+func (n *Node) snapshot() {
+	if n.wal == nil {
+		return
+	}
+
+	// hold the lock until the log is truncated so no records are lost
+	n.rgsMtx.RLock()
+	defer n.rgsMtx.RUnlock()
+
+	recs := make([]walRecord, 0)
+	for _, rg := range n.rgs {
+		leaderId := rg.leaderId
+		rg.data.Iterate(func(k string, _ []byte) bool {
+			recs = append(recs, entryRecord(leaderId, k, rg.entry(k)))
+			return true
+		})
+		rg.tombstones.Iterate(func(k string, v []byte) bool {
+			if _, ok := rg.data.Get(k); !ok {
+				recs = append(recs, walRecord{op: opDelete, leaderId: leaderId, key: k, value: v})
+			}
+			return true
+		})
+	}
+
+	err := n.wal.snapshot(recs)
+	if err != nil {
+		n.logger.Errorf("error writing snapshot: %v\n", err)
+	}
+}
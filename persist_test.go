@@ -0,0 +1,153 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func replayAll(t *testing.T, w *wal) []walRecord {
+	recs := make([]walRecord, 0)
+	err := w.replay(func(rec walRecord) {
+		recs = append(recs, rec)
+	})
+	assert.Nil(t, err, "replay() should not result in error")
+	return recs
+}
+
+func TestWalReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := openWal(dir, newLogger(false))
+	assert.Nil(t, err, "openWal() should not result in error")
+	err = w.append(
+		walRecord{op: opPut, leaderId: []byte{69}, key: "key1", value: []byte("val1")},
+		walRecord{op: opDelete, leaderId: []byte{69}, key: "key2"},
+		walRecord{op: opDropGroup, leaderId: []byte{19}},
+	)
+	assert.Nil(t, err, "append() should not result in error")
+	w.close()
+
+	w, err = openWal(dir, newLogger(false))
+	assert.Nil(t, err, "openWal() should not result in error")
+	defer w.close()
+
+	recs := replayAll(t, w)
+	assert.Equal(t, 3, len(recs), "replay() should return every appended record")
+	assert.Equal(t, opPut, recs[0].op)
+	assert.Equal(t, "key1", recs[0].key)
+	assert.Equal(t, 0, bytes.Compare(recs[0].value, []byte("val1")))
+	assert.Equal(t, opDelete, recs[1].op)
+	assert.Equal(t, opDropGroup, recs[2].op)
+	assert.Equal(t, 0, bytes.Compare(recs[2].leaderId, []byte{19}))
+}
+
+func TestWalSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := openWal(dir, newLogger(false))
+	assert.Nil(t, err, "openWal() should not result in error")
+	defer w.close()
+
+	w.append(walRecord{op: opPut, leaderId: []byte{69}, key: "key1", value: []byte("old")})
+	err = w.snapshot([]walRecord{{op: opPut, leaderId: []byte{69}, key: "key1", value: []byte("val1")}})
+	assert.Nil(t, err, "snapshot() should not result in error")
+
+	info, _ := os.Stat(filepath.Join(dir, walFileName))
+	assert.Equal(t, int64(0), info.Size(), "snapshot() should truncate the log")
+
+	w.append(walRecord{op: opPut, leaderId: []byte{69}, key: "key2", value: []byte("val2")})
+
+	recs := replayAll(t, w)
+	assert.Equal(t, 2, len(recs), "replay() should return the snapshot followed by the log")
+	assert.Equal(t, 0, bytes.Compare(recs[0].value, []byte("val1")))
+	assert.Equal(t, "key2", recs[1].key)
+}
+
+func TestWalTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := openWal(dir, newLogger(false))
+	assert.Nil(t, err, "openWal() should not result in error")
+	w.append(walRecord{op: opPut, leaderId: []byte{69}, key: "key1", value: []byte("val1")})
+	w.close()
+
+	// simulate a crash in the middle of writing a record
+	f, _ := os.OpenFile(filepath.Join(dir, walFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write([]byte{0, 0, 0, 42, 1, 2})
+	f.Close()
+
+	w, err = openWal(dir, newLogger(false))
+	assert.Nil(t, err, "openWal() should not result in error")
+	defer w.close()
+
+	recs := replayAll(t, w)
+	assert.Equal(t, 1, len(recs), "replay() should ignore a torn record at the end of the log")
+
+	// records appended after the crash must not end up behind the torn one
+	err = w.append(walRecord{op: opPut, leaderId: []byte{69}, key: "key2", value: []byte("val2")})
+	assert.Nil(t, err, "append() should not result in error")
+	w.close()
+
+	w, err = openWal(dir, newLogger(false))
+	assert.Nil(t, err, "openWal() should not result in error")
+	defer w.close()
+
+	recs = replayAll(t, w)
+	if assert.Equal(t, 2, len(recs), "replay() should return the records appended after a torn one") {
+		assert.Equal(t, "key2", recs[1].key)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	testRestart(t, StorageMemory, 8011)
+}
+
+func TestRestartLogStorage(t *testing.T) {
+	testRestart(t, StorageLog, 8012)
+}
+
+func testRestart(t *testing.T, engine string, port int) {
+	dir := t.TempDir()
+
+	cfg := DefaultConfig("0.0.0.0", port)
+	cfg.DataDir = dir
+	cfg.StorageEngine = engine
+	n, err := CreateChord(cfg)
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	id := n.Id
+
+	err = n.put(context.Background(), "key1", []byte("val1"), ConsistencyOne)
+	assert.Nil(t, err, "put(k,v) should not result in error")
+	err = n.put(context.Background(), "key2", []byte("val2"), ConsistencyOne)
+	assert.Nil(t, err, "put(k,v) should not result in error")
+	err = n.delete(context.Background(), "key2")
+	assert.Nil(t, err, "delete(k) should not result in error")
+	n.shutdown()
+
+	cfg = DefaultConfig("0.0.0.0", port)
+	cfg.DataDir = dir
+	cfg.StorageEngine = engine
+	n, err = CreateChord(cfg)
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer n.shutdown()
+
+	assert.Equal(t, 0, bytes.Compare(id, n.Id), "a restarted node should keep its peer ID")
+
+	val, err := n.get(context.Background(), "key1", ConsistencyOne)
+	assert.Nil(t, err, "get(k) should not result in error after a restart")
+	assert.Equal(t, 0, bytes.Compare(val.GetValue(), []byte("val1")))
+
+	_, err = n.get(context.Background(), "key2", ConsistencyOne)
+	assert.NotNil(t, err, "a deleted key should not come back after a restart")
+}
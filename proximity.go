@@ -0,0 +1,209 @@
+package chord
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"google.golang.org/grpc"
+)
+
+/*
+ * Any node of the interval [n+2^i, n+2^(i+1)) makes a lookup through finger i
+ * progress by at least half the remaining distance, so the first node of the
+ * interval is not the only valid finger. Each finger therefore keeps a few
+ * candidates of its interval, and lookups are forwarded to the one with the
+ * lowest round-trip time among those preceding the key (proximity neighbor
+ * selection). Round-trip times are measured from the RPCs we make anyway.
+ */
+
+const (
+	rttAlpha  = 0.125            // weight of a new sample in the smoothed RTT, as in TCP
+	rttMaxAge = 30 * time.Second // candidates measured longer ago are probed again
+)
+
+// RPCs answered by the callee itself, without calling other nodes, whose
+// duration is thus the round-trip time to the callee
+var rttMethods = map[string]bool{
+	"GetPredecessor":   true,
+	"CheckPredecessor": true,
+	"GetSuccessorList": true,
+	"Gossip":           true,
+	"NextHop":          true,
+}
+
+// rttTable keeps the smoothed round-trip time to every process we called,
+// by address. It is shared by the virtual nodes of a host.
+type rttTable struct {
+	rtts map[string]*rttSample
+	now  func() time.Time // replaced by the simulator
+	mtx  sync.Mutex
+}
+
+type rttSample struct {
+	srtt time.Duration
+	at   time.Time // of the last sample
+}
+
+func newRTTTable() *rttTable {
+	return &rttTable{
+		rtts: make(map[string]*rttSample),
+		now:  time.Now,
+	}
+}
+
+/* Function: 	observe
+ *
+ * Description:
+ * 		Add a sample of the round-trip time to addr to its moving average.
+ */
+func (t *rttTable) observe(addr string, d time.Duration) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	s, ok := t.rtts[addr]
+	if !ok {
+		t.rtts[addr] = &rttSample{srtt: d, at: t.now()}
+		return
+	}
+	s.srtt += time.Duration(rttAlpha * float64(d-s.srtt))
+	s.at = t.now()
+}
+
+// get returns the smoothed round-trip time to addr, if it was measured
+func (t *rttTable) get(addr string) (time.Duration, bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	s, ok := t.rtts[addr]
+	if !ok {
+		return 0, false
+	}
+	return s.srtt, true
+}
+
+// stale returns true if addr was never measured, or not for rttMaxAge
+func (t *rttTable) stale(addr string) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	s, ok := t.rtts[addr]
+	return !ok || t.now().Sub(s.at) > rttMaxAge
+}
+
+/* Function: 	closest
+ *
+ * Description:
+ * 		Return the node with the lowest measured round-trip time. Ties, and
+ *		nodes which were not measured, go to the earliest node in the list.
+ */
+func (t *rttTable) closest(nodes []*chordpb.Node) *chordpb.Node {
+	best := nodes[0]
+	bestRTT, measured := t.get(memberAddr(best))
+	for _, node := range nodes[1:] {
+		rtt, ok := t.get(memberAddr(node))
+		if ok && (!measured || rtt < bestRTT) {
+			best, bestRTT, measured = node, rtt, true
+		}
+	}
+	return best
+}
+
+/* Function: 	saved
+ *
+ * Description:
+ * 		Return how much closer node is than byID, the node we would have
+ *		routed to by id alone. False if either of them was not measured.
+ */
+func (t *rttTable) saved(byID *chordpb.Node, node *chordpb.Node) (time.Duration, bool) {
+	if memberAddr(byID) == memberAddr(node) {
+		return 0, true
+	}
+	rttByID, ok := t.get(memberAddr(byID))
+	if !ok {
+		return 0, false
+	}
+	rtt, ok := t.get(memberAddr(node))
+	if !ok {
+		return 0, false
+	}
+	return rttByID - rtt, true
+}
+
+// rttConn measures the round-trip time of the RPCs in rttMethods
+// made over a connection to the host at addr
+type rttConn struct {
+	grpc.ClientConnInterface
+	rtt  *rttTable
+	addr string
+}
+
+func (c *rttConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	start := c.rtt.now()
+	err := c.ClientConnInterface.Invoke(ctx, method, args, reply, opts...)
+	if err == nil && rttMethods[path.Base(method)] {
+		c.rtt.observe(c.addr, c.rtt.now().Sub(start))
+	}
+	return err
+}
+
+/* Function: 	fingerCandidates
+ *
+ * Description:
+ * 		Return up to FingerCandidates nodes of the interval of finger i, succ
+ *		being the first of them: the nodes of the successor list of succ which
+ * 		precede the next finger. Candidates we have no recent round-trip time
+ *		for are probed, and left out if they do not answer. Probing succ is
+ * 		only needed if it has competitors, and it is kept either way.
+ */
+func (n *Node) fingerCandidates(i int, succ *chordpb.Node) []*chordpb.Node {
+	candidates := []*chordpb.Node{succ}
+	end := n.Id
+	if i+1 < n.config.KeySize {
+		end = fingerMath(n.Id, i+1, n.config.KeySize)
+	}
+	// the interval only holds other nodes if it holds its first one
+	if !Between(succ.Id, n.Id, end) {
+		return candidates
+	}
+
+	succList, err := n.GetSuccessorListRPC(succ)
+	if err != nil {
+		return candidates
+	}
+	if n.host.rtt.stale(memberAddr(succ)) {
+		_, _ = n.CheckPredecessorRPC(succ)
+	}
+	for _, s := range succList.Successors {
+		if len(candidates) >= n.config.FingerCandidates || !Between(s.Id, n.Id, end) {
+			break
+		}
+		if Contains(candidates, s) || n.host.membership.failed(s.Id) {
+			continue
+		}
+		if n.host.rtt.stale(memberAddr(s)) {
+			if _, err := n.CheckPredecessorRPC(s); err != nil {
+				continue
+			}
+		}
+		candidates = append(candidates, s)
+	}
+	return candidates
+}
+
+/* Function: 	nearestCandidate
+ *
+ * Description:
+ * 		Return the candidate of a finger table entry with the lowest
+ *		round-trip time among the ones which precede id and are not
+ * 		excluded. The entry's node must be one of them.
+ */
+func (n *Node) nearestCandidate(entry *fingerEntry, id []byte, exclude []*chordpb.Node) *chordpb.Node {
+	valid := []*chordpb.Node{entry.Node}
+	for _, c := range entry.Candidates {
+		if Contains(valid, c) || Contains(exclude, c) || !Between(c.Id, n.Id, id) || n.host.membership.failed(c.Id) {
+			continue
+		}
+		valid = append(valid, c)
+	}
+	return n.host.rtt.closest(valid)
+}
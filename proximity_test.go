@@ -0,0 +1,238 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTTTable(t *testing.T) {
+	now := time.Unix(0, 0)
+	node := func(port uint32) *chordpb.Node {
+		return &chordpb.Node{Id: []byte{byte(port)}, Addr: "10.0.0.1", Port: port}
+	}
+	rtt := newRTTTable()
+	rtt.now = func() time.Time { return now }
+
+	rtt.observe("10.0.0.1:1", 10*time.Millisecond)
+	d, ok := rtt.get("10.0.0.1:1")
+	assert.True(t, ok, "a measured peer should have a round-trip time")
+	assert.Equal(t, 10*time.Millisecond, d, "the first sample should be taken as is")
+	rtt.observe("10.0.0.1:1", 18*time.Millisecond)
+	d, _ = rtt.get("10.0.0.1:1")
+	assert.Equal(t, 11*time.Millisecond, d, "later samples should be averaged in")
+	rtt.observe("10.0.0.1:2", 4*time.Millisecond)
+	rtt.observe("10.0.0.1:3", 4*time.Millisecond)
+
+	assert.Equal(t, node(2), rtt.closest([]*chordpb.Node{node(1), node(2), node(4)}), "the nearest node should be chosen")
+	assert.Equal(t, node(2), rtt.closest([]*chordpb.Node{node(4), node(2)}), "a measured node should be chosen over one which was not")
+	assert.Equal(t, node(2), rtt.closest([]*chordpb.Node{node(2), node(3)}), "ties should go to the first node")
+	assert.Equal(t, node(4), rtt.closest([]*chordpb.Node{node(4), node(5)}), "without measurements the first node should be chosen")
+
+	saved, ok := rtt.saved(node(1), node(2))
+	assert.True(t, ok, "the time saved between measured nodes should be known")
+	assert.Equal(t, 7*time.Millisecond, saved)
+	_, ok = rtt.saved(node(4), node(2))
+	assert.False(t, ok, "the time saved on a node which was not measured should not be known")
+
+	assert.False(t, rtt.stale("10.0.0.1:1"), "a peer measured just now should not be stale")
+	assert.True(t, rtt.stale("10.0.0.1:4"), "a peer never measured should be stale")
+	now = now.Add(rttMaxAge + time.Second)
+	assert.True(t, rtt.stale("10.0.0.1:1"), "a peer not measured for long should be stale")
+}
+
+// Of the nodes of a finger's interval, lookups are forwarded to the one with
+// the lowest round-trip time, and still reach the node responsible for a key.
+// Half of the nodes make their lookups iteratively
+func TestProximityRouting(t *testing.T) {
+	const numNodes = 8
+	network := NewMemoryNetwork()
+	faults := NewFaults()
+	proximityConfig := func(port int) *Config {
+		cfg := memoryConfig(network, port)
+		cfg.Faults = faults
+		cfg.FixFingerInterval = 20
+		cfg.SuccessorListSize = 3
+		cfg.IterativeLookup = port%2 == 0
+		return cfg
+	}
+
+	// every other node is far away from all the others
+	far := make(map[string]bool)
+	faults.SetLatency(AllPeers, time.Millisecond)
+	for i := 3; i <= numNodes; i += 2 {
+		addr := fmt.Sprintf("10.0.0.1:%d", i)
+		far[addr] = true
+		faults.SetLatency(addr, 20*time.Millisecond)
+	}
+
+	first, err := CreateChord(proximityConfig(1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	nodes := []*Node{first}
+	defer func() {
+		for _, n := range nodes {
+			n.shutdown()
+		}
+	}()
+	for i := 2; i <= numNodes; i++ {
+		n, err := JoinChord(proximityConfig(i), "10.0.0.1", 1)
+		assert.Nil(t, err, "JoinChord() should not result in error")
+		if err != nil {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+	deadline := time.Now().Add(60 * time.Second)
+	for !ringConverged(nodes) && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
+	assert.True(t, ringConverged(nodes), "the successors of the nodes should form a ring")
+
+	// let every finger be fixed twice on the converged ring
+	time.Sleep(2 * time.Duration(first.config.KeySize*first.config.FixFingerInterval) * time.Millisecond)
+
+	// each finger routes to a near candidate of its interval if there is one
+	alternatives := 0
+	for _, n := range nodes {
+		n.ftMtx.RLock()
+		for i, entry := range n.fingerTable {
+			wantFar := far[memberAddr(entry.Node)]
+			for _, c := range entry.Candidates {
+				wantFar = wantFar && far[memberAddr(c)]
+			}
+			if far[memberAddr(entry.Node)] && !wantFar {
+				alternatives++
+			}
+			// every candidate precedes our own id, coming round the ring
+			got := n.nearestCandidate(entry, n.Id, nil)
+			assert.Equal(t, wantFar, far[memberAddr(got)], fmt.Sprintf("finger %d of node %d should route to a near candidate if it has one", i, n.Port))
+		}
+		n.ftMtx.RUnlock()
+	}
+	assert.NotZero(t, alternatives, "some finger should lead to a far node with a near one in its interval")
+
+	sorted := append([]*Node(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Id, sorted[j].Id) < 0 })
+	owner := func(id []byte) *Node {
+		for _, n := range sorted {
+			if bytes.Compare(n.Id, id) >= 0 {
+				return n
+			}
+		}
+		return sorted[0]
+	}
+	rng := rand.New(rand.NewSource(1))
+	saved := make(map[bool]time.Duration)
+	for _, n := range nodes {
+		for i := 0; i < 20; i++ {
+			id := make([]byte, n.config.KeySize/8)
+			rng.Read(id)
+			succ, _, s, err := n.findSuccessorHops(context.Background(), id)
+			assert.Nil(t, err, "findSuccessorHops() should not result in error")
+			if err != nil {
+				return
+			}
+			assert.Equal(t, owner(id).Id, succ.Id, "lookups should find the node responsible for the id")
+			saved[n.config.IterativeLookup] += s
+		}
+	}
+	assert.True(t, saved[false] > 0, "routing recursive lookups to near nodes should save time")
+	assert.True(t, saved[true] > 0, "routing iterative lookups to near nodes should save time")
+
+	// lookups made by the nodes in the background are measured as well
+	for _, iterative := range []bool{false, true} {
+		var count uint64
+		for _, n := range nodes {
+			if n.config.IterativeLookup != iterative {
+				continue
+			}
+			m := &dto.Metric{}
+			assert.Nil(t, n.host.metrics.lookupRTTSaved.Write(m), "Write() should not result in error")
+			count += m.Histogram.GetSampleCount()
+		}
+		assert.NotZerof(t, count, "forwarded lookups should be measured, iterative %v", iterative)
+	}
+}
+
+// The time proximity routing saves is recorded once per lookup, summed over
+// its hops, by the node making it rather than by the nodes forwarding it
+func TestLookupRTTSavedAtOrigin(t *testing.T) {
+	network := NewMemoryNetwork()
+	var nodes []*Node
+	defer func() {
+		for _, n := range nodes {
+			n.shutdown()
+		}
+	}()
+	for i := 1; i <= 3; i++ {
+		cfg := idleConfig(network, i)
+		cfg.GossipInterval = 0
+		n, err := CreateChord(cfg)
+		assert.Nil(t, err, "CreateChord() should not result in error")
+		if err != nil {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return bytes.Compare(nodes[i].Id, nodes[j].Id) < 0 })
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	keySize := a.config.KeySize
+
+	// a lookup from a of an id past c goes through b then c. Each of them
+	// routes to the next one rather than to a far node preceding the id
+	// more closely, which no lookup reaches
+	id := fingerMath(c.Id, 1, keySize)
+	route := func(from *Node, to *Node, saved time.Duration) {
+		far := &chordpb.Node{Id: fingerMath(to.Id, 0, keySize), Addr: "10.0.0.1", Port: uint32(10 + to.Port)}
+		from.succMtx.Lock()
+		from.successor = to.Node
+		from.succMtx.Unlock()
+		from.ftMtx.Lock()
+		from.fingerTable[0] = &fingerEntry{Id: far.Id, Node: far, Candidates: []*chordpb.Node{far, to.Node}}
+		from.ftMtx.Unlock()
+		from.host.rtt.observe(memberAddr(far), saved+time.Millisecond)
+		from.host.rtt.observe(memberAddr(to.Node), time.Millisecond)
+	}
+	route(a, b, 8*time.Millisecond)
+	route(b, c, 5*time.Millisecond)
+	c.succMtx.Lock()
+	c.successor = a.Node
+	c.succMtx.Unlock()
+
+	observed := func(n *Node) (uint64, float64) {
+		m := &dto.Metric{}
+		assert.Nil(t, n.host.metrics.lookupRTTSaved.Write(m), "Write() should not result in error")
+		return m.Histogram.GetSampleCount(), m.Histogram.GetSampleSum()
+	}
+	for _, iterative := range []bool{false, true} {
+		for _, n := range nodes {
+			n.config.IterativeLookup = iterative
+		}
+		count, sum := observed(a)
+		succ, err := a.findSuccessor(id)
+		assert.Nil(t, err, "findSuccessor() should not result in error")
+		if err != nil {
+			return
+		}
+		assert.Equal(t, a.Id, succ.Id, "the lookup should end at the node responsible for the id")
+
+		count2, sum2 := observed(a)
+		assert.Equalf(t, count+1, count2, "the origin should record a lookup once, iterative %v", iterative)
+		assert.InDeltaf(t, (13 * time.Millisecond).Seconds(), sum2-sum, 1e-9, "the time saved on every hop should be summed, iterative %v", iterative)
+		for _, n := range []*Node{b, c} {
+			count, _ := observed(n)
+			assert.Zerof(t, count, "%d only forwarded the lookup and should not record it, iterative %v", n.Port, iterative)
+		}
+	}
+}
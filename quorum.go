@@ -0,0 +1,172 @@
+package chord
+
+import (
+	"context"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/cdesiniotis/chord/chordpb"
+)
+
+// Consistency levels of reads and writes
+const (
+	ConsistencyOne    = chordpb.Consistency_ONE
+	ConsistencyQuorum = chordpb.Consistency_QUORUM
+	ConsistencyAll    = chordpb.Consistency_ALL
+)
+
+/* Function: 	ParseConsistency
+ *
+ * Description:
+ * 		Parse a consistency level (one, quorum or all).
+ */
+func ParseConsistency(s string) (chordpb.Consistency, error) {
+	c, ok := chordpb.Consistency_value[strings.ToUpper(s)]
+	if !ok {
+		return ConsistencyOne, fmt.Errorf("unknown consistency level %s", s)
+	}
+	return chordpb.Consistency(c), nil
+}
+
+/* Function: 	quorum
+ *
+ * Description:
+ * 		Return how many of the n replicas of a key must answer a request
+ *		made with the given consistency level.
+ */
+func quorum(consistency chordpb.Consistency, n int) int {
+	switch consistency {
+	case ConsistencyQuorum:
+		return n/2 + 1
+	case ConsistencyAll:
+		return n
+	}
+	return 1
+}
+
+/* Function: 	replicaNodes
+ *
+ * Description:
+ * 		Return the distinct nodes in our successor list, other than us,
+ *		which hold replicas of the keys we lead.
+ */
+func (n *Node) replicaNodes() []*chordpb.Node {
+	n.succListMtx.RLock()
+	defer n.succListMtx.RUnlock()
+
+	nodes := make([]*chordpb.Node, 0, len(n.successorList))
+	for _, node := range n.successorList {
+		if node == nil || bytes.Equal(node.Id, n.Id) || Contains(nodes, node) {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+/* Function: 	sendReplica
+ *
+ * Description:
+ * 		Send a kv we lead to our replica group. Returns once enough replicas
+ *		(counting us) have stored it to satisfy the consistency level; the
+ * 		remaining replicas are updated in the background.
+ */
+func (n *Node) sendReplica(ctx context.Context, kv *chordpb.KV, consistency chordpb.Consistency) error {
+	replicaMsg := &chordpb.ReplicaMsg{LeaderId: n.Id, Kv: []*chordpb.KV{kv}}
+	replicas := n.replicaNodes()
+	required := quorum(consistency, len(replicas)+1)
+
+	results := make(chan error, len(replicas))
+	for _, node := range replicas {
+		go func(node *chordpb.Node) {
+			results <- n.SendReplicasRPC(node, replicaMsg)
+		}(node)
+	}
+
+	// we already stored the kv ourselves
+	acks := 1
+	for i := 0; i < len(replicas) && acks < required; i++ {
+		var err error
+		select {
+		case err = <-results:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err != nil {
+			n.logger.Errorf("error calling SendReplicasRPC(): %v\n", err)
+			continue
+		}
+		acks++
+	}
+
+	if acks < required {
+		return fmt.Errorf("write acknowledged by %d replicas, %d required", acks, required)
+	}
+	return nil
+}
+
+/* Function: 	readReplicas
+ *
+ * Description:
+ * 		Read a key we lead from enough replicas (counting us) to satisfy the
+ *		consistency level, and return the newest versions among them. Versions
+ * 		written concurrently are all returned as siblings.
+ */
+func (n *Node) readReplicas(ctx context.Context, key string, consistency chordpb.Consistency) (*chordpb.Value, error) {
+	n.rgsMtx.RLock()
+	newest := n.rgs[idKey(n.Id)].entry(key)
+	n.rgsMtx.RUnlock()
+
+	replicas := n.replicaNodes()
+	required := quorum(consistency, len(replicas)+1)
+
+	answers := 1
+	if required > 1 {
+		type result struct {
+			val *chordpb.Value
+			err error
+		}
+		results := make(chan result, len(replicas))
+		for _, node := range replicas {
+			go func(node *chordpb.Node) {
+				val, err := n.GetReplicaRPC(ctx, node, n.Id, key)
+				results <- result{val, err}
+			}(node)
+		}
+
+		for i := 0; i < len(replicas) && answers < required; i++ {
+			var res result
+			select {
+			case res = <-results:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if res.err != nil {
+				n.logger.Errorf("error calling GetReplicaRPC(): %v\n", res.err)
+				continue
+			}
+			answers++
+			newest = newest.add(entryFromValue(res.val))
+		}
+
+		// keep the versions we read, so that a write resolving them is not
+		// rejected as stale
+		n.rgsMtx.Lock()
+		merged, err := n.rgs[idKey(n.Id)].merge(key, newest)
+		if err != nil {
+			n.logger.Errorf("readReplicas() error storing key %s: %v\n", key, err)
+		} else {
+			n.logRecords(entryRecord(n.Id, key, merged))
+		}
+		n.rgsMtx.Unlock()
+	}
+
+	if answers < required {
+		return nil, fmt.Errorf("read answered by %d replicas, %d required", answers, required)
+	}
+	if len(newest.siblings) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return newest.toValue(), nil
+}
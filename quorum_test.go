@@ -0,0 +1,83 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConsistency(t *testing.T) {
+	c, err := ParseConsistency("quorum")
+	assert.Nil(t, err, "ParseConsistency() should not result in error")
+	assert.Equal(t, ConsistencyQuorum, c)
+
+	c, err = ParseConsistency("ALL")
+	assert.Nil(t, err, "ParseConsistency() should not result in error")
+	assert.Equal(t, ConsistencyAll, c)
+
+	_, err = ParseConsistency("some")
+	assert.NotNil(t, err, "ParseConsistency() should result in error for an unknown level")
+}
+
+func TestQuorum(t *testing.T) {
+	assert.Equal(t, 1, quorum(ConsistencyOne, 3))
+	assert.Equal(t, 2, quorum(ConsistencyQuorum, 3))
+	assert.Equal(t, 3, quorum(ConsistencyQuorum, 5))
+	assert.Equal(t, 3, quorum(ConsistencyAll, 3))
+	assert.Equal(t, 1, quorum(ConsistencyQuorum, 1), "a node alone in the ring is a quorum")
+}
+
+func TestQuorumReadWrite(t *testing.T) {
+	key := "quorum-key"
+
+	err := n1.put(context.Background(), key, []byte("val1"), ConsistencyAll)
+	assert.Nil(t, err, "put(k,v) with consistency ALL should not result in error")
+
+	val, err := n1.get(context.Background(), key, ConsistencyQuorum)
+	assert.Nil(t, err, "get(k) with consistency QUORUM should not result in error")
+	assert.Equal(t, 0, bytes.Compare(val.GetValue(), []byte("val1")))
+
+	// find the leader for the key and a replica
+	node, err := n1.locate(context.Background(), key)
+	assert.Nil(t, err, "locate(k) should not result in error")
+	var leader, replica *Node
+	for _, n := range []*Node{n1, n2, n3} {
+		if bytes.Equal(n.Id, node.Id) {
+			leader = n
+		} else if replica == nil {
+			replica = n
+		}
+	}
+	assert.NotNil(t, leader, "the key should be led by one of the nodes")
+
+	// every replica acknowledged the write, so it holds the same version
+	leader.rgsMtx.RLock()
+	version := leader.rgs[idKey(leader.Id)].entry(key)
+	leader.rgsMtx.RUnlock()
+	replica.rgsMtx.RLock()
+	replicaVersion := replica.rgs[idKey(leader.Id)].entry(key)
+	replica.rgsMtx.RUnlock()
+	assert.Equal(t, 1, len(replicaVersion.siblings), "a write with consistency ALL should reach every replica")
+	assert.Equal(t, version.clock(), replicaVersion.clock())
+
+	// a replica holding a newer version wins a read of every replica
+	replica.rgsMtx.Lock()
+	replica.rgs[idKey(leader.Id)].merge(key, entry{siblings: []sibling{{clock: version.clock().increment(replica.Id), value: []byte("val2")}}})
+	replica.rgsMtx.Unlock()
+
+	val, err = n1.get(context.Background(), key, ConsistencyOne)
+	assert.Nil(t, err, "get(k) should not result in error")
+	assert.Equal(t, 0, bytes.Compare(val.GetValue(), []byte("val1")), "a read of one replica should only consult the leader")
+
+	val, err = n1.get(context.Background(), key, ConsistencyAll)
+	assert.Nil(t, err, "get(k) with consistency ALL should not result in error")
+	assert.Equal(t, 0, bytes.Compare(val.GetValue(), []byte("val2")), "a read of every replica should return the newest value")
+
+	// a deletion is newer than the value it removed
+	err = n1.delete(context.Background(), key)
+	assert.Nil(t, err, "delete(k) should not result in error")
+	_, err = n1.get(context.Background(), key, ConsistencyAll)
+	assert.NotNil(t, err, "get(k) should result in error for a deleted key")
+}
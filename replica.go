@@ -2,51 +2,176 @@ package chord
 
 import (
 	"bytes"
+	"encoding/hex"
 	"github.com/cdesiniotis/chord/chordpb"
 	log "github.com/sirupsen/logrus"
-	"math"
+	"math/big"
+	"time"
 )
 
 type ReplicaGroup struct {
 	leaderId []byte
-	data map[string][]byte
+	data Storage
+	// keys deleted by the leader, re-shipped along with data so that
+	// replicas which missed a removal drop their stale copy. The leader
+	// forgets them after TombstoneTTL, see expireTombstones
+	tombstones Storage
+
+	logger log.FieldLogger
+}
+
+/* Function: 	newReplicaGroup
+ *
+ * Description:
+ * 		Create a replica group for leaderId, backed by the storage engine
+ *		selected in the config. Durable engines reopen any data the group
+ *		held before a restart.
+ */
+func (n *Node) newReplicaGroup(leaderId []byte) (*ReplicaGroup, error) {
+	data, err := newStorage(n.config, storeName(leaderId, false), n.logger)
+	if err != nil {
+		return nil, err
+	}
+	tombstones, err := newStorage(n.config, storeName(leaderId, true), n.logger)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	return &ReplicaGroup{leaderId: leaderId, data: data, tombstones: tombstones, logger: n.logger}, nil
+}
+
+/* Function: 	storeName
+ *
+ * Description:
+ * 		Return the name of a replica group's store within the data directory.
+ *		Each replica group keeps its data and its tombstones in separate stores.
+ */
+func storeName(leaderId []byte, tombstones bool) string {
+	name := hex.EncodeToString(leaderId)
+	if tombstones {
+		return name + ".tombstones"
+	}
+	return name + ".data"
+}
+
+// return every version of a key
+func (rg *ReplicaGroup) entry(key string) entry {
+	var e entry
+	var err error
+	if buf, ok := rg.data.Get(key); ok {
+		if e.siblings, err = decodeSiblings(buf); err != nil {
+			rg.logger.Errorf("entry(%s): %v\n", key, err)
+		}
+	}
+	if buf, ok := rg.tombstones.Get(key); ok {
+		if e.tombstone, err = decodeClock(buf); err != nil {
+			rg.logger.Errorf("entry(%s): %v\n", key, err)
+		}
+	}
+	return e
+}
+
+// store every version of a key, replacing the stored ones
+func (rg *ReplicaGroup) store(key string, e entry) error {
+	var err error
+	if len(e.siblings) > 0 {
+		err = rg.data.Put(key, encodeSiblings(e.siblings))
+	} else {
+		err = rg.data.Delete(key)
+	}
+	if err != nil {
+		return err
+	}
+	if e.tombstone != nil {
+		return rg.tombstones.Put(key, encodeTombstone(e.tombstone, rg.deletedAt(key, e.tombstone)))
+	}
+	return rg.tombstones.Delete(key)
+}
+
+// return when we first stored a deletion of a key, now if we did not yet
+func (rg *ReplicaGroup) deletedAt(key string, clock vclock) time.Time {
+	if buf, ok := rg.tombstones.Get(key); ok {
+		c, at, err := decodeTombstone(buf)
+		if err == nil && !at.IsZero() && c.descends(clock) && clock.descends(c) {
+			return at
+		}
+	}
+	return time.Now()
+}
+
+// add versions of a key to the stored ones, dropping those superseded
+func (rg *ReplicaGroup) merge(key string, e entry) (entry, error) {
+	merged := rg.entry(key).add(e)
+	return merged, rg.store(key, merged)
+}
+
+// forget a kv entirely
+func (rg *ReplicaGroup) remove(key string) error {
+	err := rg.data.Delete(key)
+	if err != nil {
+		return err
+	}
+	return rg.tombstones.Delete(key)
+}
+
+func (rg *ReplicaGroup) close() {
+	rg.data.Close()
+	rg.tombstones.Close()
 }
 
-func (n *Node) addRgMembership(id uint64) {
-	log.Infof("addRgMembership(%d)\n", id)
+func (rg *ReplicaGroup) destroy() {
+	rg.data.Destroy()
+	rg.tombstones.Destroy()
+}
+
+func (n *Node) addRgMembership(leaderId []byte) {
+	n.logger.Infof("addRgMembership(%d)\n", leaderId)
 	n.rgsMtx.Lock()
 	defer n.rgsMtx.Unlock()
 
+	id := idKey(leaderId)
 	_, ok := n.rgs[id]
 	if ok {
-		log.Errorf("addRgMembership(id) - RG for id already exists\n")
+		n.logger.Errorf("addRgMembership(id) - RG for id already exists\n")
 		return
 	}
 
-	n.rgs[id] = &ReplicaGroup{leaderId:Uint64ToBytes(id)}
-	n.rgs[id].data = make(map[string][]byte)
+	rg, err := n.newReplicaGroup(leaderId)
+	if err != nil {
+		n.logger.Errorf("addRgMembership(id) - error creating RG storage: %v\n", err)
+		return
+	}
+	n.rgs[id] = rg
 	return
 }
 
-func (n *Node) removeRgMembership(id uint64) {
-	log.Infof("removeRgMembership(%d)\n", id)
+func (n *Node) removeRgMembership(leaderId []byte) {
+	n.logger.Infof("removeRgMembership(%d)\n", leaderId)
+	// we lead our own RG for as long as we are alive. A node which wrongly
+	// suspected us to have failed may still tell us it took over our RG
+	if bytes.Equal(leaderId, n.Id) {
+		return
+	}
 	n.rgsMtx.Lock()
-	_, ok := n.rgs[id]
+	id := idKey(leaderId)
+	rg, ok := n.rgs[id]
 	if ok {
 		delete(n.rgs, id)
+		rg.destroy()
+		n.logRecords(walRecord{op: opDropGroup, leaderId: rg.leaderId})
 	}
 	n.rgsMtx.Unlock()
 }
 
 func (n *Node) removeFarthestRgMembership() {
-	log.Infof("removeFarthestRgMembership()\n")
+	n.logger.Infof("removeFarthestRgMembership()\n")
 	n.rgsMtx.RLock()
 	numRgs := len(n.rgs)
 	n.rgsMtx.RUnlock()
 	// Do not remove membership if we are not a part of the max
 	// number of replica groups allowed -> len(successorList) + 1
 	if numRgs < (n.config.SuccessorListSize + 1) {
-		log.Infof("inside removeFarthestRgMembership - exiting since numRgs = %d\n", numRgs)
+		n.logger.Infof("inside removeFarthestRgMembership - exiting since numRgs = %d\n", numRgs)
 		return
 	}
 
@@ -55,27 +180,20 @@ func (n *Node) removeFarthestRgMembership() {
 	n.removeRgMembership(id)
 }
 
-func (n *Node) getFarthestRgMembership() uint64 {
-	log.Infof("getFarthestRgMembership()\n")
+func (n *Node) getFarthestRgMembership() []byte {
+	n.logger.Infof("getFarthestRgMembership()\n")
 	n.rgsMtx.RLock()
 	defer n.rgsMtx.RUnlock()
-	// get ids for replica groups we are apart of
-	keys := make([]uint64, len(n.rgs))
-	i := 0
-	for k := range n.rgs {
-		keys[i] = k
-		i++
-	}
 
-	var farthestId, maxDist, dist uint64
-	ourId := BytesToUint64(n.Id)
-	m := int(math.Pow(2.0, float64(n.config.KeySize)))
+	var farthestId []byte
+	maxDist := new(big.Int)
 
-	for _, id := range keys {
-		dist = Distance(ourId, id, m)
-		if dist > maxDist {
+	// check the leader ids of replica groups we are apart of
+	for _, rg := range n.rgs {
+		dist := Distance(n.Id, rg.leaderId, n.config.KeySize)
+		if dist.Cmp(maxDist) > 0 {
 			maxDist = dist
-			farthestId = id
+			farthestId = rg.leaderId
 		}
 	}
 
@@ -83,23 +201,10 @@ func (n *Node) getFarthestRgMembership() uint64 {
 }
 
 // TODO: cleanup  the below functions for sending/moving keys and replicas
-func (n *Node) sendReplica(key string) {
-	n.rgsMtx.RLock()
-	defer n.rgsMtx.RUnlock()
+// inform our replica group that a key was deleted
+func (n *Node) removeReplica(key string, clock vclock) {
+	replicaMsg := &chordpb.ReplicaMsg{LeaderId:n.Id, Kv:[]*chordpb.KV{{Key:key, Clock:clock.toProto()}}}
 
-	leaderID := BytesToUint64(n.Id)
-	// get value for key
-	val, ok := n.rgs[leaderID].data[key]
-	if !ok {
-		log.Errorf("sendReplica() exiting since key does not exist in our datastore\n")
-	}
-	// create kv
-	kv := &chordpb.KV{Key:key, Value:val}
-	// create replicaMsg
-	replicaMsg := &chordpb.ReplicaMsg{LeaderId:n.Id, Kv:[]*chordpb.KV{kv}}
-
-
-	// send kv to replica group
 	n.succListMtx.RLock()
 	succList := n.successorList
 	n.succListMtx.RUnlock()
@@ -107,32 +212,118 @@ func (n *Node) sendReplica(key string) {
 		if bytes.Equal(node.Id, n.Id) {
 			continue
 		}
-		n.SendReplicasRPC(node,replicaMsg)
+		n.RemoveReplicasRPC(node, replicaMsg)
+	}
+}
+
+/* Function: 	expireTombstones
+ *
+ * Description:
+ * 		Forget the keys of our replica group deleted more than TombstoneTTL
+ *		ago, and tell our replicas to forget them too. Keys still holding a
+ * 		version concurrent with their deletion keep their tombstone, and
+ *		tombstones stored without the time of their deletion expire TombstoneTTL
+ * 		from now. Replicas which missed a deletion for longer than that may
+ *		bring the key back.
+ */
+func (n *Node) expireTombstones() {
+	ttl := time.Duration(n.config.TombstoneTTL) * time.Millisecond
+	now := time.Now()
+
+	n.rgsMtx.Lock()
+	rg := n.rgs[idKey(n.Id)]
+	expired := make([]*chordpb.KV, 0)
+	unstamped := make(map[string]vclock)
+	rg.tombstones.Iterate(func(k string, v []byte) bool {
+		clock, at, err := decodeTombstone(v)
+		switch {
+		case err != nil:
+			rg.logger.Errorf("expireTombstones(%s): %v\n", k, err)
+		case at.IsZero():
+			unstamped[k] = clock
+		case now.Sub(at) >= ttl:
+			if _, ok := rg.data.Get(k); !ok {
+				expired = append(expired, &chordpb.KV{Key: k})
+			}
+		}
+		return true
+	})
+	for k, clock := range unstamped {
+		buf := encodeTombstone(clock, now)
+		if err := rg.tombstones.Put(k, buf); err != nil {
+			n.logger.Errorf("expireTombstones(%s): %v\n", k, err)
+			continue
+		}
+		n.logRecords(walRecord{op: opDelete, leaderId: n.Id, key: k, value: buf})
+	}
+	for _, kv := range expired {
+		if err := rg.tombstones.Delete(kv.Key); err != nil {
+			n.logger.Errorf("expireTombstones(%s): %v\n", kv.Key, err)
+			continue
+		}
+		n.logRecords(walRecord{op: opRemove, leaderId: n.Id, key: kv.Key})
+	}
+	n.rgsMtx.Unlock()
+
+	if len(expired) == 0 {
+		return
+	}
+	n.logger.Infof("expireTombstones(): forgetting %d deleted keys\n", len(expired))
+	// keys sent without a clock are removed without being remembered
+	replicaMsg := &chordpb.ReplicaMsg{LeaderId: n.Id, Kv: expired}
+	for _, node := range n.replicaNodes() {
+		n.RemoveReplicasRPC(node, replicaMsg)
+	}
+}
+
+/* Function: 	mergeKVs
+ *
+ * Description:
+ * 		Add versions of keys received from another node to a replica group. Versions
+ *		superseded by ones we hold are dropped, concurrent ones are kept as siblings.
+ * 		Callers must hold rgsMtx.
+ */
+func (n *Node) mergeKVs(rg *ReplicaGroup, kvs []*chordpb.KV, tombstones []*chordpb.KV) error {
+	for key, e := range entriesFromKVs(kvs, tombstones) {
+		merged, err := rg.merge(key, e)
+		if err != nil {
+			return err
+		}
+		n.logRecords(entryRecord(rg.leaderId, key, merged))
 	}
+	return nil
 }
 
 func (n *Node) sendAllReplicas() {
 	n.rgsMtx.RLock()
 	defer n.rgsMtx.RUnlock()
 
-	leaderID := BytesToUint64(n.Id)
+	leaderID := idKey(n.Id)
+	rg := n.rgs[leaderID]
 
-	if len(n.rgs[leaderID].data) == 0 {
+	if rg.data.Len() == 0 && rg.tombstones.Len() == 0 {
 		return
 	}
 
 	// Create kv array
-	kvs := make([]*chordpb.KV, len(n.rgs[leaderID].data))
-	index := 0
-	for k, v := range n.rgs[leaderID].data {
-		kvs[index] = &chordpb.KV{Key:k, Value:v}
-		index++
-	}
-
-	// create replicaMsg
+	kvs := make([]*chordpb.KV, 0, rg.data.Len())
+	rg.data.Iterate(func(k string, v []byte) bool {
+		kvs = append(kvs, rg.siblingKVs(k, v)...)
+		return true
+	})
+
+	// Create array of deleted keys
+	deleted := make([]*chordpb.KV, 0, rg.tombstones.Len())
+	rg.tombstones.Iterate(func(k string, v []byte) bool {
+		deleted = append(deleted, rg.tombstoneKV(k, v))
+		return true
+	})
+
+	// create replicaMsgs
 	replicaMsg := &chordpb.ReplicaMsg{LeaderId:n.Id, Kv: kvs}
+	removeMsg := &chordpb.ReplicaMsg{LeaderId:n.Id, Kv: deleted}
 
-	// send kvs to replica group
+	// send kvs to replica group, followed by the keys that must not be kept
 	n.succListMtx.RLock()
 	succList := n.successorList
 	n.succListMtx.RUnlock()
@@ -140,30 +331,77 @@ func (n *Node) sendAllReplicas() {
 		if bytes.Equal(node.Id, n.Id) {
 			continue
 		}
-		n.SendReplicasRPC(node,replicaMsg)
+		if len(kvs) > 0 {
+			n.SendReplicasRPC(node,replicaMsg)
+		}
+		if len(deleted) > 0 {
+			n.RemoveReplicasRPC(node, removeMsg)
+		}
 	}
 }
 
+/* Function: 	takeOverReplicaGroup
+ *
+ * Description:
+ * 		Our predecessor departed and its keys are now in our RG. Drop our membership
+ *		of the RG it led, initiate a new leader election by telling our successors
+ * 		we replace it, and transfer our data replicas to the replica group.
+ */
+func (n *Node) takeOverReplicaGroup(oldLeaderId []byte) {
+	// remove membership to RG whose leader is the departed node
+	n.removeRgMembership(oldLeaderId)
+
+	// initiate new leader election
+	n.succListMtx.RLock()
+	succList := n.successorList
+	n.succListMtx.RUnlock()
+	// send coordinator msg to all
+	n.logger.Infof("In takeOverReplicaGroup() - sending coordinator msg: new %d\t old: %d\n", n.Id, oldLeaderId)
+	for _, node := range succList {
+		n.RecvCoordinatorMsgRPC(node, n.Id, oldLeaderId)
+	}
+
+	// TODO: only send new keys?
+	// transfer data replicas to replica group
+	n.sendAllReplicas()
+}
+
 // strictly move new replicas to our RG
 // will take care of sending new replicas outside this function
-func (n *Node) moveReplicas(fromId uint64, toId uint64) {
+func (n *Node) moveReplicas(fromId []byte, toId []byte) {
 	n.rgsMtx.Lock()
 	defer n.rgsMtx.Unlock()
 
-	_ , ok := n.rgs[fromId]
+	from, ok := n.rgs[idKey(fromId)]
 	if !ok {
-		log.Errorf("moveReplicas(from: %d, to: %d) exiting since fromId is not a current replica group leader\n", fromId, toId)
+		n.logger.Errorf("moveReplicas(from: %d, to: %d) exiting since fromId is not a current replica group leader\n", fromId, toId)
 		return
 	}
 
-	_ , ok = n.rgs[toId]
+	to, ok := n.rgs[idKey(toId)]
 	if !ok {
-		log.Errorf("moveReplicas(from: %d, to: %d) exiting since toId is not a current replica group leader\n", fromId, toId)
+		n.logger.Errorf("moveReplicas(from: %d, to: %d) exiting since toId is not a current replica group leader\n", fromId, toId)
 		return
 	}
 
-	for k, v := range n.rgs[fromId].data {
-		n.rgs[toId].data[k] = v
+	keys := make([]string, 0, from.data.Len()+from.tombstones.Len())
+	from.data.Iterate(func(k string, _ []byte) bool {
+		keys = append(keys, k)
+		return true
+	})
+	from.tombstones.Iterate(func(k string, _ []byte) bool {
+		keys = append(keys, k)
+		return true
+	})
+
+	// versions we already hold in our RG may be concurrent with the moved ones
+	for _, k := range keys {
+		merged, err := to.merge(k, from.entry(k))
+		if err != nil {
+			n.logger.Errorf("moveReplicas(from: %d, to: %d) error storing key %s: %v\n", fromId, toId, k, err)
+			continue
+		}
+		n.logRecords(entryRecord(to.leaderId, k, merged))
 	}
 	return
 }
@@ -173,44 +411,130 @@ func (n *Node) moveReplicas(fromId uint64, toId uint64) {
 // return a list of kvs to be passed to sendKeys() so that
 // the newly joined node receives its existing keys
 func (n *Node) moveKeys(fromId []byte, toId []byte) []*chordpb.KV {
-	fromId_uint := BytesToUint64(fromId)
-	//toId_uint := BytesToUint64(toId)
+	fromKey := idKey(fromId)
 	kvs := make([]*chordpb.KV, 0)
 
 	n.rgsMtx.Lock()
 	defer n.rgsMtx.Unlock()
 
-	var hash []byte
-	for k, v := range n.rgs[fromId_uint].data {
-		hash = GetPeerID(k, n.config.KeySize)
-		if !BetweenRightIncl(hash, toId, fromId) {
-			kvs = append(kvs, &chordpb.KV{Key:k, Value:v})
-			// remove kv from our data store
-			//delete(n.rgs[fromId_uint].data, k)
-			// SEND REMOVE TO OUR RG
-		}
-	}
+	// keys hashing outside of (toId, fromId] now belong to toId
+	n.rgs[fromKey].data.RangeByHash(fromId, toId, func(k string, v []byte) bool {
+		kvs = append(kvs, n.rgs[fromKey].siblingKVs(k, v)...)
+		// remove kv from our data store
+		//delete(n.rgs[fromKey].data, k)
+		// SEND REMOVE TO OUR RG
+		return true
+	})
 	return kvs
 
 }
 
+/* Function: 	handOnKeys
+ *
+ * Description:
+ * 		Called with keys our successor handed over to us. Its view of our
+ *		predecessor may be older than ours, e.g. while two rings merge after
+ * 		a partition, in which case some of them belong to a node before us:
+ *		hand them on with the next notification of our predecessor. Each node
+ * 		in turn keeps the keys it is responsible for, until they reach theirs.
+ */
+func (n *Node) handOnKeys(kvs []*chordpb.KV) {
+	n.predMtx.Lock()
+	defer n.predMtx.Unlock()
+	if n.predecessor == nil {
+		return
+	}
+	for _, kv := range kvs {
+		if !BetweenRightIncl(GetPeerID(kv.Key, n.config.KeySize), n.predecessor.Id, n.Id) {
+			n.handOver = true
+			return
+		}
+	}
+}
+
 // Remove keys from fromId's replica group, if toId is responsible for them
 func (n *Node) removeKeys(fromId []byte, toId []byte) []*chordpb.KV {
-	fromId_uint := BytesToUint64(fromId)
-
 	n.rgsMtx.Lock()
 	defer n.rgsMtx.Unlock()
 
-	var hash []byte
+	rg := n.rgs[idKey(fromId)]
 	kvs := make([]*chordpb.KV, 0)
-	for k, v := range n.rgs[fromId_uint].data {
-		hash = GetPeerID(k, n.config.KeySize)
-		if !BetweenRightIncl(hash, toId, fromId) {
-			// remove kv from our data store
-			delete(n.rgs[fromId_uint].data, k)
-			// append to list tracking which keys we have removed
-			kvs = append(kvs, &chordpb.KV{Key:k, Value:v})
+	// keys hashing outside of (toId, fromId] now belong to toId
+	rg.data.RangeByHash(fromId, toId, func(k string, v []byte) bool {
+		// append to list tracking which keys we have removed
+		kvs = append(kvs, &chordpb.KV{Key:k})
+		return true
+	})
+	// tombstones for keys toId is now responsible for were handed over by GetKeys
+	deleted := make([]string, 0)
+	rg.tombstones.RangeByHash(fromId, toId, func(k string, _ []byte) bool {
+		deleted = append(deleted, k)
+		return true
+	})
+
+	// remove kvs from our data store
+	for _, kv := range kvs {
+		deleted = append(deleted, kv.Key)
+	}
+	for _, k := range deleted {
+		if err := rg.remove(k); err != nil {
+			n.logger.Errorf("removeKeys() error removing key %s: %v\n", k, err)
 		}
+		n.logRecords(walRecord{op: opRemove, leaderId: fromId, key: k})
 	}
 	return kvs
 }
+
+/* Function: 	handOverKeys
+ *
+ * Description:
+ * 		Our predecessor changed to pred, so the keys we lead hashing outside of
+ *		(pred, n] belong to pred now. Send them to pred, then remove them from our
+ * 		replica group. pred fetched most of them with GetKeys when it joined, but
+ *		keys written to us since, while we still believed we were responsible
+ * 		for them, would otherwise be lost. Returns false if pred did not take them.
+ */
+func (n *Node) handOverKeys(pred *chordpb.Node) bool {
+	if bytes.Equal(pred.Id, n.Id) {
+		return true
+	}
+
+	kvs := n.moveKeys(n.Id, pred.Id)
+	deleted := make([]*chordpb.KV, 0)
+	n.rgsMtx.RLock()
+	rg := n.rgs[idKey(n.Id)]
+	rg.tombstones.RangeByHash(n.Id, pred.Id, func(k string, v []byte) bool {
+		deleted = append(deleted, rg.tombstoneKV(k, v))
+		return true
+	})
+	n.rgsMtx.RUnlock()
+	if len(kvs) == 0 && len(deleted) == 0 {
+		return true
+	}
+
+	if len(kvs) > 0 {
+		if err := n.SendReplicasRPC(pred, &chordpb.ReplicaMsg{LeaderId: pred.Id, Kv: kvs}); err != nil {
+			n.logger.Errorf("handOverKeys() error calling SendReplicasRPC(): %v\n", err)
+			return false
+		}
+	}
+	if len(deleted) > 0 {
+		if err := n.RemoveReplicasRPC(pred, &chordpb.ReplicaMsg{LeaderId: pred.Id, Kv: deleted}); err != nil {
+			n.logger.Errorf("handOverKeys() error calling RemoveReplicasRPC(): %v\n", err)
+			return false
+		}
+	}
+
+	// remove the keys from our replica group
+	removed := n.removeKeys(n.Id, pred.Id)
+	n.succListMtx.RLock()
+	succList := n.successorList
+	n.succListMtx.RUnlock()
+	for _, node := range succList {
+		if node == nil || bytes.Equal(node.Id, n.Id) {
+			continue
+		}
+		n.RemoveReplicasRPC(node, &chordpb.ReplicaMsg{LeaderId: n.Id, Kv: removed})
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package chord
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+)
+
+// A node which wrongly suspected us to have failed, and tells us it took
+// over our replica group, does not make us drop our own keys
+func TestOwnReplicaGroupKept(t *testing.T) {
+	network := NewMemoryNetwork()
+	n, err := CreateChord(idleConfig(network, 1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer n.shutdown()
+	ctx := context.Background()
+	err = n.Put(ctx, "key", []byte("val"), ConsistencyOne)
+	assert.Nil(t, err, "Put() should not result in error")
+	if err != nil {
+		return
+	}
+
+	other := fingerMath(n.Id, 0, n.config.KeySize)
+	_, err = n.RecvCoordinatorMsg(ctx, &chordpb.CoordinatorMsg{NewLeaderId: other, OldLeaderId: n.Id})
+	assert.Nil(t, err, "RecvCoordinatorMsg() should not result in error")
+
+	n.rgsMtx.RLock()
+	_, ok := n.rgs[idKey(n.Id)]
+	n.rgsMtx.RUnlock()
+	if !assert.True(t, ok, "we should still lead our own replica group") {
+		return
+	}
+	val, err := n.Get(ctx, "key", ConsistencyOne)
+	assert.Nil(t, err, "Get() should not result in error")
+	assert.Equal(t, "val", string(val.GetValue()))
+}
+
+// Keys written to us before a new predecessor notified us, which it could
+// not fetch when it joined, are handed over to it rather than dropped
+func TestKeysHandedOverOnNotify(t *testing.T) {
+	network := NewMemoryNetwork()
+	n, err := CreateChord(idleConfig(network, 1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer n.shutdown()
+	pred, err := CreateChord(idleConfig(network, 2))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer pred.shutdown()
+
+	// alone on the ring, we are responsible for every key
+	ctx := context.Background()
+	keys := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		err := n.Put(ctx, key, []byte("val"), ConsistencyOne)
+		assert.Nil(t, err, "Put() should not result in error")
+		if err != nil {
+			return
+		}
+		keys = append(keys, key)
+	}
+
+	_, err = n.Notify(ctx, pred.Node)
+	assert.Nil(t, err, "Notify() should not result in error")
+	stored := func(node *Node, key string) bool {
+		node.rgsMtx.RLock()
+		defer node.rgsMtx.RUnlock()
+		_, ok := node.rgs[idKey(node.Id)].data.Get(key)
+		return ok
+	}
+	handedOver := 0
+	for _, key := range keys {
+		if BetweenRightIncl(GetPeerID(key, n.config.KeySize), pred.Id, n.Id) {
+			assert.True(t, stored(n, key), fmt.Sprintf("%s should stay with us", key))
+			assert.False(t, stored(pred, key), fmt.Sprintf("%s should not be handed over", key))
+			continue
+		}
+		handedOver++
+		assert.True(t, stored(pred, key), fmt.Sprintf("%s should be handed over to our predecessor", key))
+		assert.False(t, stored(n, key), fmt.Sprintf("%s should be removed from our replica group", key))
+	}
+	assert.NotZero(t, handedOver, "some key should belong to our predecessor")
+}
+
+// A member which missed the coordinator message making it join a replica
+// group joins it when the leader syncs the group with it
+func TestReplicaGroupJoinedOnSync(t *testing.T) {
+	network := NewMemoryNetwork()
+	n, err := CreateChord(idleConfig(network, 1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer n.shutdown()
+	leader, err := CreateChord(idleConfig(network, 2))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer leader.shutdown()
+	ctx := context.Background()
+	err = leader.Put(ctx, "key", []byte("val"), ConsistencyOne)
+	assert.Nil(t, err, "Put() should not result in error")
+	if err != nil {
+		return
+	}
+
+	leader.rgsMtx.RLock()
+	tree := leader.rgs[idKey(leader.Id)].merkleTree()
+	leader.rgsMtx.RUnlock()
+	diff, err := n.SyncReplicas(ctx, &chordpb.MerkleTree{LeaderId: leader.Id, Hashes: tree})
+	assert.Nil(t, err, "SyncReplicas() should not result in error")
+	if err != nil {
+		return
+	}
+	assert.NotEmpty(t, diff.Ranges, "the key we miss should be in a range which differs")
+	n.rgsMtx.RLock()
+	_, ok := n.rgs[idKey(leader.Id)]
+	n.rgsMtx.RUnlock()
+	assert.True(t, ok, "we should join the replica group of the leader")
+}
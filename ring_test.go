@@ -0,0 +1,117 @@
+package chord
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+)
+
+// idleConfig returns the config of a quiet node on network whose periodic
+// threads do not run during a test, so that its pointers can be set by hand
+func idleConfig(network *MemoryNetwork, port int) *Config {
+	cfg := memoryConfig(network, port)
+	cfg.StabilizeInterval = 3600000
+	cfg.FixFingerInterval = 3600000
+	cfg.CheckPredecessorInterval = 3600000
+	cfg.AntiEntropyInterval = 3600000
+	return cfg
+}
+
+// A lookup which no live node can take fails at once, rather than being
+// forwarded to the node making it until the deadline
+func TestLookupNotForwardedToSelf(t *testing.T) {
+	network := NewMemoryNetwork()
+	n, err := CreateChord(idleConfig(network, 1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer n.shutdown()
+
+	// a successor which crashed before we noticed, and which neither the
+	// finger table nor the successor list know of yet
+	dead := &chordpb.Node{Id: fingerMath(n.Id, 0, n.config.KeySize), Addr: "10.0.0.1", Port: 2}
+	n.succMtx.Lock()
+	n.successor = dead
+	n.succMtx.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, _, _, err = n.findSuccessorHops(ctx, fingerMath(n.Id, 1, n.config.KeySize))
+	assert.NotNil(t, err, "a lookup through a failed successor should fail")
+	assert.True(t, time.Since(start) < time.Second, "a lookup should not be forwarded to ourselves until the deadline")
+}
+
+// A failed successor is replaced by the first node of the successor list
+// which answers, even if the list still holds the failed successor
+func TestSuccessorReplacedByLiveNode(t *testing.T) {
+	network := NewMemoryNetwork()
+	n, err := CreateChord(idleConfig(network, 1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer n.shutdown()
+	live, err := CreateChord(idleConfig(network, 2))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer live.shutdown()
+
+	// a successor which crashed, and a list which stabilize() did not
+	// reorder yet since it found that successor
+	dead := &chordpb.Node{Id: fingerMath(n.Id, 0, n.config.KeySize), Addr: "10.0.0.1", Port: 3}
+	n.succMtx.Lock()
+	n.successor = dead
+	n.succMtx.Unlock()
+	n.succListMtx.Lock()
+	n.successorList = []*chordpb.Node{live.Node, dead}
+	n.succListMtx.Unlock()
+
+	n.updateSuccessorList()
+	n.succMtx.RLock()
+	succ := n.successor
+	n.succMtx.RUnlock()
+	assert.Equal(t, live.Id, succ.Id, "the failed successor should be replaced by a node which answers")
+}
+
+// A lookup ending at a node whose predecessor took the key over, as one
+// through a stale successor pointer does, fails rather than storing the
+// key where no lookup will find it
+func TestKeyNotStoredAfterHandOver(t *testing.T) {
+	network := NewMemoryNetwork()
+	n, err := CreateChord(idleConfig(network, 1))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer n.shutdown()
+
+	// our successor pointer still leads to ourselves
+	pred := &chordpb.Node{Id: fingerMath(n.Id, n.config.KeySize-1, n.config.KeySize), Addr: "10.0.0.1", Port: 2}
+	n.predMtx.Lock()
+	n.predecessor = pred
+	n.predMtx.Unlock()
+	var key string
+	for i := 0; key == ""; i++ {
+		if k := fmt.Sprintf("key-%d", i); !n.responsible(GetPeerID(k, n.config.KeySize)) {
+			key = k
+		}
+	}
+
+	ctx := context.Background()
+	_, err = n.Locate(ctx, key)
+	assert.NotNil(t, err, "Locate() of a key our predecessor took over should fail")
+	err = n.Put(ctx, key, []byte("val"), ConsistencyOne)
+	assert.NotNil(t, err, "Put() of a key our predecessor took over should fail")
+	n.rgsMtx.RLock()
+	_, ok := n.rgs[idKey(n.Id)].data.Get(key)
+	n.rgsMtx.RUnlock()
+	assert.False(t, ok, "a key our predecessor took over should not be stored")
+}
@@ -5,8 +5,11 @@ import (
 	"context"
 	"errors"
 	"github.com/cdesiniotis/chord/chordpb"
-	log "github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"strconv"
 	"time"
 )
@@ -17,47 +20,42 @@ type grpcOpts struct {
 	timeout    time.Duration
 }
 
-type clientConn struct {
-	client chordpb.ChordClient
-	conn   *grpc.ClientConn
-}
-
 /* Function: 	getChordClient
  *
  * Description:
- *		Returns a client necessary to make a chord grpc call.
- * 		Adds the client to the node's connection pool.
+ *		Returns a client necessary to make a chord grpc call
+ * 		through the transport of our host, subject to the
+ *		injected faults if any. The round-trip times of the
+ * 		calls are recorded, see rttConn.
  */
 func (n *Node) getChordClient(other *chordpb.Node) (chordpb.ChordClient, error) {
-
 	target := other.Addr + ":" + strconv.Itoa(int(other.Port))
-
-	n.connPoolMtx.RLock()
-	cc, ok := n.connPool[target]
-	n.connPoolMtx.RUnlock()
-	if ok {
-		return cc.client, nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
-	defer cancel()
-
-	conn, err := grpc.DialContext(ctx, target, n.grpcOpts.dialOpts...)
-	//conn, err := grpc.Dial(target, n.grpcOpts.dialOpts...)
+	conn, err := n.host.transport.Client(target)
 	if err != nil {
 		return nil, err
 	}
-
-	client := chordpb.NewChordClient(conn)
-	cc = &clientConn{client, conn}
-	n.connPoolMtx.Lock()
-	defer n.connPoolMtx.Unlock()
-	if n.connPool == nil {
-		return nil, errors.New("must instantiate node before using")
+	if n.config.Faults != nil {
+		from := n.Addr + ":" + strconv.Itoa(int(n.Port))
+		conn = &faultConn{ClientConnInterface: conn, faults: n.config.Faults, from: from, to: target}
 	}
-	n.connPool[target] = cc
+	conn = &rttConn{ClientConnInterface: conn, rtt: n.host.rtt, addr: target}
+	return chordpb.NewChordClient(conn), nil
+}
 
-	return client, nil
+/* Function: 	rpcContext
+ *
+ * Description:
+ *		Returns a context for an RPC on node "other," derived from ctx. The context
+ * 		carries the id of the virtual node being called, so that its host can
+ *		dispatch the RPC.
+ */
+func (n *Node) rpcContext(ctx context.Context, other *chordpb.Node) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, n.grpcOpts.timeout)
+	ctx = WithRingSecret(ctx, n.config.RingSecret)
+	if len(other.Id) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, vnodeMetadataKey, idKey(other.Id))
+	}
+	return ctx, cancel
 }
 
 /* Function: 	removeChordClient
@@ -67,13 +65,7 @@ func (n *Node) getChordClient(other *chordpb.Node) (chordpb.ChordClient, error)
  */
 func (n *Node) removeChordClient(other *chordpb.Node) {
 	target := other.Addr + ":" + strconv.Itoa(int(other.Port))
-	n.connPoolMtx.RLock()
-	defer n.connPoolMtx.RUnlock()
-	_, ok := n.connPool[target]
-	if ok {
-		delete(n.connPool, target)
-	}
-	return
+	n.host.transport.Forget(target)
 }
 
 /* Function: 	FindSuccessorRPC
@@ -82,16 +74,41 @@ func (n *Node) removeChordClient(other *chordpb.Node) {
  *		Invoke a FindSuccessor RPC on node "other," asking for the successor of a given id.
  */
 func (n *Node) FindSuccessorRPC(other *chordpb.Node, id []byte) (*chordpb.Node, error) {
+	resp, _, _, err := n.findSuccessorRPC(context.Background(), other, id)
+	return resp, err
+}
+
+// findSuccessorRPC also returns the number of nodes "other" forwarded the request
+// through, and the round-trip time proximity routing saved on the way
+func (n *Node) findSuccessorRPC(ctx context.Context, other *chordpb.Node, id []byte) (*chordpb.Node, int, time.Duration, error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
-		return nil, err
+		n.logger.Errorf("error getting Chord Client: %v", err)
+		return nil, 0, 0, err
 	}
 	req := &chordpb.PeerID{Id: id}
 
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
-	resp, err := client.FindSuccessor(ctx, req)
-	return resp, err
+	ctx, cancel := n.rpcContext(ctx, other)
+	defer cancel()
+	var header metadata.MD
+	resp, err := client.FindSuccessor(ctx, req, grpc.Header(&header))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	hops := 0
+	if v := header.Get(hopsMetadataKey); len(v) > 0 {
+		hops, _ = strconv.Atoi(v[0])
+	}
+	return resp, hops, rttSavedHeader(header), nil
+}
+
+// rttSavedHeader returns the round-trip time saved a node reported in header
+func rttSavedHeader(header metadata.MD) time.Duration {
+	if v := header.Get(rttSavedMetadataKey); len(v) > 0 {
+		ns, _ := strconv.ParseInt(v[0], 10, 64)
+		return time.Duration(ns)
+	}
+	return 0
 }
 
 /* Function: 	GetPredecessorRPC
@@ -102,12 +119,13 @@ func (n *Node) FindSuccessorRPC(other *chordpb.Node, id []byte) (*chordpb.Node,
 func (n *Node) GetPredecessorRPC(other *chordpb.Node) (*chordpb.Node, error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return nil, err
 	}
 	req := &chordpb.Empty{}
 
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
 	resp, err := client.GetPredecessor(ctx, req)
 	return resp, err
 }
@@ -121,12 +139,13 @@ func (n *Node) NotifyRPC(other *chordpb.Node) error {
 
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return err
 	}
 	req := n.Node
 
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
 	_, err = client.Notify(ctx, req)
 	return err
 }
@@ -139,16 +158,76 @@ func (n *Node) NotifyRPC(other *chordpb.Node) error {
 func (n *Node) CheckPredecessorRPC(other *chordpb.Node) (*chordpb.Empty, error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return nil, err
 	}
 	req := &chordpb.Empty{}
 
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
 	resp, err := client.CheckPredecessor(ctx, req)
 	return resp, err
 }
 
+/* Function: 	ProbeRPC
+ *
+ * Description:
+ *		Ask node "other" to check whether target is still alive, when we
+ * 		can not reach target ourselves
+ */
+func (n *Node) ProbeRPC(other *chordpb.Node, target *chordpb.Node) error {
+	client, err := n.getChordClient(other)
+	if err != nil {
+		n.logger.Errorf("error getting Chord Client: %v", err)
+		return err
+	}
+
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
+	_, err = client.Probe(ctx, target)
+	return err
+}
+
+/* Function: 	GossipRPC
+ *
+ * Description:
+ *		Exchange membership updates with the process serving node "other"
+ */
+func (n *Node) GossipRPC(other *chordpb.Node, msg *chordpb.GossipMsg) (*chordpb.GossipMsg, error) {
+	client, err := n.getChordClient(other)
+	if err != nil {
+		n.logger.Errorf("error getting Chord Client: %v", err)
+		return nil, err
+	}
+
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
+	return client.Gossip(ctx, msg)
+}
+
+/* Function: 	NextHopRPC
+ *
+ * Description:
+ *		Ask node "other" for the next node to ask for the successor of id,
+ * 		see lookupIterative.
+ */
+func (n *Node) NextHopRPC(ctx context.Context, other *chordpb.Node, id []byte) (*chordpb.Hop, time.Duration, error) {
+	client, err := n.getChordClient(other)
+	if err != nil {
+		n.logger.Errorf("error getting Chord Client: %v", err)
+		return nil, 0, err
+	}
+
+	ctx, cancel := n.rpcContext(ctx, other)
+	defer cancel()
+	var header metadata.MD
+	hop, err := client.NextHop(ctx, &chordpb.PeerID{Id: id}, grpc.Header(&header))
+	if err != nil {
+		return nil, 0, err
+	}
+	return hop, rttSavedHeader(header), nil
+}
+
 /* Function: 	GetSuccessorListRPC
  *
  * Description:
@@ -157,12 +236,13 @@ func (n *Node) CheckPredecessorRPC(other *chordpb.Node) (*chordpb.Empty, error)
 func (n *Node) GetSuccessorListRPC(other *chordpb.Node) (*chordpb.SuccessorList, error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return nil, err
 	}
 	req := &chordpb.Empty{}
 
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
 	resp, err := client.GetSuccessorList(ctx, req)
 	return resp, err
 }
@@ -170,26 +250,28 @@ func (n *Node) GetSuccessorListRPC(other *chordpb.Node) (*chordpb.SuccessorList,
 func (n *Node) RecvCoordinatorMsgRPC(other *chordpb.Node, newLeaderId []byte, oldLeaderId []byte) (error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return err
 	}
 	req := &chordpb.CoordinatorMsg{NewLeaderId:newLeaderId, OldLeaderId:oldLeaderId}
 
 	// TODO: consider not sending with timeout here
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
 	_, err = client.RecvCoordinatorMsg(ctx, req)
 	return err
 }
 
-func (n *Node) GetKeysRPC(other *chordpb.Node, id []byte) (*chordpb.KVs, error) {
+func (n *Node) GetKeysRPC(other *chordpb.Node, id []byte, have []*chordpb.KeyDigest) (*chordpb.KVs, error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return nil, err
 	}
-	req := &chordpb.PeerID{Id:id}
+	req := &chordpb.KeysRequest{Id:id, Have:have}
 
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
 	resp , err := client.GetKeys(ctx, req)
 	return resp, err
 }
@@ -197,12 +279,13 @@ func (n *Node) GetKeysRPC(other *chordpb.Node, id []byte) (*chordpb.KVs, error)
 func (n *Node) SendReplicasRPC(other *chordpb.Node, req *chordpb.ReplicaMsg) (error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return err
 	}
 
 	// TODO: consider not sending with timeout here
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
 	_, err = client.SendReplicas(ctx, req)
 	return err
 }
@@ -210,55 +293,124 @@ func (n *Node) SendReplicasRPC(other *chordpb.Node, req *chordpb.ReplicaMsg) (er
 func (n *Node) RemoveReplicasRPC(other *chordpb.Node, req *chordpb.ReplicaMsg) (error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return err
 	}
 
 	// TODO: consider not sending with timeout here
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
 	_, err = client.RemoveReplicas(ctx, req)
 	return err
 }
 
-func (n *Node) GetRPC(other *chordpb.Node, key string) (*chordpb.Value, error) {
+func (n *Node) GetRPC(ctx context.Context, other *chordpb.Node, key string, consistency chordpb.Consistency) (*chordpb.Value, error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return nil, err
 	}
-	req := &chordpb.Key{Key: key}
+	req := &chordpb.Key{Key: key, Consistency: consistency}
 
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(ctx, other)
+	defer cancel()
 	resp, err := client.Get(ctx, req)
 	return resp, err
 }
 
-func (n *Node) PutRPC(other *chordpb.Node, key string, value []byte) (*chordpb.Empty, error) {
+func (n *Node) PutRPC(ctx context.Context, other *chordpb.Node, key string, value []byte, clock []*chordpb.ClockEntry, consistency chordpb.Consistency) (*chordpb.Empty, error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return nil, err
 	}
-	req := &chordpb.KV{Key: key, Value: value}
+	req := &chordpb.KV{Key: key, Value: value, Clock: clock, Consistency: consistency}
 
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(ctx, other)
+	defer cancel()
 	resp, err := client.Put(ctx, req)
 	return resp, err
 }
 
+/* Function: 	GetReplicaRPC
+ *
+ * Description:
+ *		Invoke a GetReplica RPC on node "other," asking for its replica of a key led by leaderId.
+ */
+func (n *Node) GetReplicaRPC(ctx context.Context, other *chordpb.Node, leaderId []byte, key string) (*chordpb.Value, error) {
+	client, err := n.getChordClient(other)
+	if err != nil {
+		n.logger.Errorf("error getting Chord Client: %v", err)
+		return nil, err
+	}
+	req := &chordpb.ReplicaKey{LeaderId: leaderId, Key: key}
+
+	ctx, cancel := n.rpcContext(ctx, other)
+	defer cancel()
+	resp, err := client.GetReplica(ctx, req)
+	return resp, err
+}
+
+/* Function: 	SyncReplicasRPC
+ *
+ * Description:
+ *		Invoke a SyncReplicas RPC on node "other," sending the Merkle tree of the keys we lead.
+ */
+func (n *Node) SyncReplicasRPC(other *chordpb.Node, tree [][]byte) (*chordpb.MerkleDiff, error) {
+	client, err := n.getChordClient(other)
+	if err != nil {
+		n.logger.Errorf("error getting Chord Client: %v", err)
+		return nil, err
+	}
+	req := &chordpb.MerkleTree{LeaderId: n.Id, Hashes: tree}
+
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
+	resp, err := client.SyncReplicas(ctx, req)
+	return resp, err
+}
+
+func (n *Node) DeleteRPC(ctx context.Context, other *chordpb.Node, key string) (*chordpb.Empty, error) {
+	client, err := n.getChordClient(other)
+	if err != nil {
+		n.logger.Errorf("error getting Chord Client: %v", err)
+		return nil, err
+	}
+	req := &chordpb.Key{Key: key}
+
+	ctx, cancel := n.rpcContext(ctx, other)
+	defer cancel()
+	resp, err := client.Delete(ctx, req)
+	return resp, err
+}
+
 func (n *Node) LocateRPC(other *chordpb.Node, key string) (*chordpb.Node, error) {
 	client, err := n.getChordClient(other)
 	if err != nil {
-		log.Errorf("error getting Chord Client: %v", err)
+		n.logger.Errorf("error getting Chord Client: %v", err)
 		return nil, err
 	}
 	req := &chordpb.Key{Key: key}
 
-	ctx, _ := context.WithTimeout(context.Background(), n.grpcOpts.timeout)
+	ctx, cancel := n.rpcContext(context.Background(), other)
+	defer cancel()
 	resp, err := client.Locate(ctx, req)
 	return resp, err
 }
 
+func (n *Node) NotifyLeaveRPC(ctx context.Context, other *chordpb.Node, msg *chordpb.LeaveMsg) error {
+	client, err := n.getChordClient(other)
+	if err != nil {
+		n.logger.Errorf("error getting Chord Client: %v", err)
+		return err
+	}
+
+	ctx, cancel := n.rpcContext(ctx, other)
+	defer cancel()
+	_, err = client.NotifyLeave(ctx, msg)
+	return err
+}
+
 /* Function: 	FindSuccessor
  *
  * Description:
@@ -267,7 +419,13 @@ func (n *Node) LocateRPC(other *chordpb.Node, key string) (*chordpb.Node, error)
  * 		Otherwise, check our finger table and forward the request to the closest preceding node.
  */
 func (n *Node) FindSuccessor(context context.Context, peerID *chordpb.PeerID) (*chordpb.Node, error) {
-	return n.findSuccessor(peerID.Id)
+	succ, hops, saved, err := n.findSuccessorHops(context, peerID.Id)
+	if err != nil {
+		return nil, err
+	}
+	grpc.SetHeader(context, metadata.Pairs(hopsMetadataKey, strconv.Itoa(hops),
+		rttSavedMetadataKey, strconv.FormatInt(int64(saved), 10)))
+	return succ, nil
 }
 
 /* Function: 	GetPredecessor
@@ -289,15 +447,28 @@ func (n *Node) GetPredecessor(context context.Context, empty *chordpb.Empty) (*c
  *
  * Description:
  * 		Implementation of Notify RPC. A Node is notifying us that it believes it is our predecessor.
- * 		Check if this is true based on our predecessor/successor knowledge and update.
+ * 		Check if this is true based on our predecessor/successor knowledge and update. Keys
+ *		our new predecessor is responsible for are handed over to it.
  */
 func (n *Node) Notify(context context.Context, node *chordpb.Node) (*chordpb.Empty, error) {
 	n.predMtx.Lock()
-	defer n.predMtx.Unlock()
-
 	if n.predecessor == nil || Between(node.Id, n.predecessor.Id, n.Id) {
-		log.Infof("Notify(): Updating predecessor to: %v\n", node)
+		n.logger.Infof("Notify(): Updating predecessor to: %v\n", node)
 		n.predecessor = node
+		n.handOver = true
+	}
+	handOver := n.handOver && bytes.Equal(n.predecessor.Id, node.Id)
+	n.predMtx.Unlock()
+	n.host.membership.discover(node)
+
+	// hand the keys of our new predecessor over, retrying on its next
+	// notification if it did not take them
+	if handOver && n.handOverKeys(node) {
+		n.predMtx.Lock()
+		if n.predecessor != nil && bytes.Equal(n.predecessor.Id, node.Id) {
+			n.handOver = false
+		}
+		n.predMtx.Unlock()
 	}
 	return &chordpb.Empty{}, nil
 }
@@ -312,6 +483,20 @@ func (n *Node) CheckPredecessor(context context.Context, empty *chordpb.Empty) (
 	return &chordpb.Empty{}, nil
 }
 
+/* Function: 	Probe
+ *
+ * Description:
+ *		Check whether a node is alive for another node which could not
+ * 		reach it. Fails if we can not reach it either.
+ */
+func (n *Node) Probe(context context.Context, target *chordpb.Node) (*chordpb.Empty, error) {
+	_, err := n.CheckPredecessorRPC(target)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "%s:%d is not reachable: %v", target.Addr, target.Port, err)
+	}
+	return &chordpb.Empty{}, nil
+}
+
 /* Function: 	GetSuccessorList
  *
  * Description:
@@ -323,6 +508,34 @@ func (n *Node) GetSuccessorList(context context.Context, empty *chordpb.Empty) (
 	return &chordpb.SuccessorList{Successors: n.successorList}, nil
 }
 
+/* Function: 	GetRoutingTable
+ *
+ * Description:
+ * 		Implementation of GetRoutingTable RPC. Return our predecessor, successor list
+ *		and the distinct nodes of our finger table, so that clients can learn the ring.
+ */
+func (n *Node) GetRoutingTable(context context.Context, empty *chordpb.Empty) (*chordpb.RoutingTable, error) {
+	table := &chordpb.RoutingTable{Node: n.Node, KeySize: uint32(n.config.KeySize)}
+
+	n.predMtx.RLock()
+	table.Predecessor = n.predecessor
+	n.predMtx.RUnlock()
+
+	n.succListMtx.RLock()
+	table.Successors = append(table.Successors, n.successorList...)
+	n.succListMtx.RUnlock()
+
+	n.ftMtx.RLock()
+	for _, finger := range n.fingerTable {
+		if finger.Node != nil && !Contains(table.Fingers, finger.Node) {
+			table.Fingers = append(table.Fingers, finger.Node)
+		}
+	}
+	n.ftMtx.RUnlock()
+
+	return table, nil
+}
+
 /* Function: 	ReceiveCoordinatorMsg
  *
  * Description:
@@ -339,14 +552,14 @@ func (n *Node) RecvCoordinatorMsg(context context.Context, msg *chordpb.Coordina
 		return &chordpb.Empty{}, nil
 	}
 
-	log.Infof("ReceivedCoordinatorMsg(): newLeaderID: %d\t oldLeaderID: %d\n", msg.NewLeaderId, msg.OldLeaderId)
+	n.logger.Infof("ReceivedCoordinatorMsg(): newLeaderID: %d\t oldLeaderID: %d\n", msg.NewLeaderId, msg.OldLeaderId)
 
 	if len(msg.OldLeaderId) == 0 {
 		// New node has joined chord ring
 
 		// Check if this is a duplicate message
 		n.rgsMtx.RLock()
-		_, ok := n.rgs[BytesToUint64(msg.NewLeaderId)]
+		_, ok := n.rgs[idKey(msg.NewLeaderId)]
 		if ok {
 			n.rgsMtx.RUnlock()
 			return &chordpb.Empty{}, errors.New("received duplicate coordinator message")
@@ -357,42 +570,27 @@ func (n *Node) RecvCoordinatorMsg(context context.Context, msg *chordpb.Coordina
 		n.removeFarthestRgMembership()
 
 		// Add new RG
-		newLeaderId := BytesToUint64(msg.NewLeaderId)
-		n.addRgMembership(newLeaderId)
-
-		// If newleader should be our predecessor, or is already our predecessor,
-		// remove keys we are not responsible for anymore.
-		// This new node already requested these keys from us when it joined the chord ring.
-		n.predMtx.RLock()
-		if n.predecessor == nil || Between(msg.NewLeaderId, n.predecessor.Id, n.Id) || bytes.Equal(msg.NewLeaderId, n.predecessor.Id) {
-			// remove keys we aren't responsible for anymore
-			kvs := n.removeKeys(n.Id, msg.NewLeaderId)
-			// remove these keys from our replica group
-			n.succListMtx.RLock()
-			succList := n.successorList
-			n.succListMtx.RUnlock()
-			for _, node := range succList {
-				n.RemoveReplicasRPC(node, &chordpb.ReplicaMsg{LeaderId:n.Id, Kv:kvs})
-			}
-		}
-		n.predMtx.RUnlock()
+		n.addRgMembership(msg.NewLeaderId)
 
+		// The keys the new leader is now responsible for are handed over, then
+		// removed from our replica group, once it notifies us that it is our
+		// predecessor. See handOverKeys()
 
 	} else {
 
-		newLeaderId := BytesToUint64(msg.NewLeaderId)
-		oldLeaderId := BytesToUint64(msg.OldLeaderId)
+		newLeaderId := msg.NewLeaderId
+		oldLeaderId := msg.OldLeaderId
 
 		// Check if new leader or old leader is currently the leader
 		// for a replica group we are a part of
 		n.rgsMtx.RLock()
-		_, newLeaderExists := n.rgs[newLeaderId]
-		_, oldLeaderExists := n.rgs[oldLeaderId]
+		_, newLeaderExists := n.rgs[idKey(newLeaderId)]
+		_, oldLeaderExists := n.rgs[idKey(oldLeaderId)]
 		n.rgsMtx.RUnlock()
 
 		// Two cases where our replica group membership changes
 		if newLeaderExists && oldLeaderExists {
-			if (newLeaderId == oldLeaderId) {
+			if bytes.Equal(newLeaderId, oldLeaderId) {
 				// RG membership has not changed - we are already in this RG
 				return &chordpb.Empty{}, nil
 			}
@@ -417,112 +615,346 @@ func (n *Node) RecvCoordinatorMsg(context context.Context, msg *chordpb.Coordina
  * Description:
  * 		Implementation of GetKeys RPC. The caller of this RPC is requesting keys for which it
  * 		it responsible for along the chord ring. We simply check our own datastore for keys
- * 		that the other node is responsible for and send it.
+ * 		that the other node is responsible for and send it. Keys the caller already holds
+ * 		with the same value (e.g. after a restart) are skipped.
  */
-func (n *Node) GetKeys(context context.Context, id *chordpb.PeerID) (*chordpb.KVs, error) {
+func (n *Node) GetKeys(context context.Context, id *chordpb.KeysRequest) (*chordpb.KVs, error) {
 	n.rgsMtx.RLock()
 	defer n.rgsMtx.RUnlock()
 
-	ourId := BytesToUint64(n.Id)
-	if len(n.rgs[ourId].data) == 0 {
+	ourId := idKey(n.Id)
+	rg := n.rgs[ourId]
+	if rg.data.Len() == 0 && rg.tombstones.Len() == 0 {
 		return &chordpb.KVs{}, nil
 	}
 	kvs := make([]*chordpb.KV, 0)
+	tombstones := make([]*chordpb.KV, 0)
 
-	var hash []byte
-	for k, v := range n.rgs[ourId].data {
-		hash = GetPeerID(k, n.config.KeySize)
-		// TODO: ensure this only sends the necessary keys at all times
-		if !BetweenRightIncl(hash, id.Id, n.Id){
-			kvs = append(kvs, &chordpb.KV{Key:k, Value:v})
-		}
+	have := make(map[string][]byte, len(id.Have))
+	for _, d := range id.Have {
+		have[d.Key] = d.Digest
 	}
-	return &chordpb.KVs{Kvs:kvs}, nil
+
+	// keys hashing outside of (id, n.Id] belong to the caller
+	// TODO: ensure this only sends the necessary keys at all times
+	rg.data.RangeByHash(n.Id, id.Id, func(k string, v []byte) bool {
+		if digest, ok := have[k]; ok && bytes.Equal(digest, GetHash(string(v))) {
+			return true
+		}
+		kvs = append(kvs, rg.siblingKVs(k, v)...)
+		return true
+	})
+	// hand over deletions as well so the new leader does not resurrect them
+	rg.tombstones.RangeByHash(n.Id, id.Id, func(k string, v []byte) bool {
+		tombstones = append(tombstones, rg.tombstoneKV(k, v))
+		return true
+	})
+	return &chordpb.KVs{Kvs:kvs, Tombstones:tombstones}, nil
 }
 
 /* Function: 	SendReplicas
  *
  * Description:
  * 		Implementation of SendReplicas RPC. A leader is sending us kv replicas. Add them to the leaders
- * 		replica group internally.
+ * 		replica group internally, unless we already hold a newer version. Versions concurrent with
+ *		the ones we hold are kept as siblings. Keys handed over to us which we are not responsible
+ * 		for are handed on to our predecessor, see handOnKeys.
  */
 func (n *Node) SendReplicas(context context.Context, replicaMsg *chordpb.ReplicaMsg) (*chordpb.Empty, error) {
-	leaderId := BytesToUint64(replicaMsg.LeaderId)
-
-	n.rgsMtx.RLock()
-	_ , ok := n.rgs[leaderId]
-	n.rgsMtx.RUnlock()
+	leaderId := idKey(replicaMsg.LeaderId)
 
+	// the group may be dropped concurrently, so look it up under the lock we merge under
+	n.rgsMtx.Lock()
+	rg, ok := n.rgs[leaderId]
 	if !ok {
-		log.Errorf("SendReplicas() for leaderId %d, but not currently apart of this replica group\n", leaderId)
+		n.rgsMtx.Unlock()
+		n.logger.Errorf("SendReplicas() for leaderId %s, but not currently apart of this replica group\n", leaderId)
 		return &chordpb.Empty{}, errors.New("node is not in replica group")
 	}
+	err := n.mergeKVs(rg, replicaMsg.Kv, nil)
+	n.rgsMtx.Unlock()
+	if err == nil && bytes.Equal(replicaMsg.LeaderId, n.Id) {
+		n.handOnKeys(replicaMsg.Kv)
+	}
+	return &chordpb.Empty{}, err
+}
+
+/* Function: 	RemoveReplicas
+ *
+ * Description:
+ * 		Implementation of RemoveReplicas RPC. A leader is informing us that certain keys do not belong
+ * 		in this replica group anymore. Remove the specified keys from the leaders replica group internally.
+ *		Keys sent with the clock of their deletion are remembered as deleted, so that an older
+ * 		replica arriving late does not bring them back.
+ */
+func (n *Node) RemoveReplicas(context context.Context, replicaMsg *chordpb.ReplicaMsg) (*chordpb.Empty, error) {
+	leaderId := idKey(replicaMsg.LeaderId)
 
 	n.rgsMtx.Lock()
 	defer n.rgsMtx.Unlock()
+	rg, ok := n.rgs[leaderId]
+	if !ok {
+		n.logger.Errorf("RemoveReplicas() for leaderId %s, but not currently apart of this replica group\n", leaderId)
+		return &chordpb.Empty{}, errors.New("node is not in replica group")
+	}
+	deleted := make([]*chordpb.KV, 0, len(replicaMsg.Kv))
 	for _ ,kv := range replicaMsg.Kv {
-		n.rgs[leaderId].data[kv.Key] = kv.Value
+		if len(kv.Clock) == 0 {
+			if err := rg.remove(kv.Key); err != nil {
+				return &chordpb.Empty{}, err
+			}
+			n.logRecords(walRecord{op: opRemove, leaderId: replicaMsg.LeaderId, key: kv.Key})
+			continue
+		}
+		deleted = append(deleted, kv)
 	}
 
-	return &chordpb.Empty{}, nil
+	err := n.mergeKVs(rg, nil, deleted)
+	return &chordpb.Empty{}, err
 }
 
-/* Function: 	RemoveReplicas
+/* Function: 	GetReplica
  *
  * Description:
- * 		Implementation of RemoveReplicas RPC. A leader is informing us that certain keys do not belong
- * 		in this replica group anymore. Remove the specified keys from the leaders replica group internally
+ * 		Implementation of GetReplica RPC. The leader of a replica group is reading
+ *		our replica of a key for a quorum read.
  */
-func (n *Node) RemoveReplicas(context context.Context, replicaMsg *chordpb.ReplicaMsg) (*chordpb.Empty, error) {
-	leaderId := BytesToUint64(replicaMsg.LeaderId)
+func (n *Node) GetReplica(context context.Context, req *chordpb.ReplicaKey) (*chordpb.Value, error) {
+	n.rgsMtx.RLock()
+	defer n.rgsMtx.RUnlock()
 
+	rg, ok := n.rgs[idKey(req.LeaderId)]
+	if !ok {
+		return nil, errors.New("node is not in replica group")
+	}
+	return rg.entry(req.Key).toValue(), nil
+}
+
+/* Function: 	SyncReplicas
+ *
+ * Description:
+ * 		Implementation of SyncReplicas RPC. The leader of a replica group sent us the Merkle
+ *		tree of its keys. Compare it with the tree of our replica and return our versions of the
+ * 		keys in the ranges that differ. A leader counting us in its replica group while
+ *		we missed the coordinator message telling us so makes us join the group.
+ */
+func (n *Node) SyncReplicas(context context.Context, tree *chordpb.MerkleTree) (*chordpb.MerkleDiff, error) {
 	n.rgsMtx.RLock()
-	_ , ok := n.rgs[leaderId]
+	_, ok := n.rgs[idKey(tree.LeaderId)]
 	n.rgsMtx.RUnlock()
+	if !ok {
+		n.addRgMembership(tree.LeaderId)
+	}
+
+	n.rgsMtx.RLock()
+	defer n.rgsMtx.RUnlock()
 
+	rg, ok := n.rgs[idKey(tree.LeaderId)]
 	if !ok {
-		log.Errorf("RemoveReplicas() for leaderId %d, but not currently apart of this replica group\n", leaderId)
-		return &chordpb.Empty{}, errors.New("node is not in replica group")
+		return nil, errors.New("node is not in replica group")
 	}
+	ranges := merkleDiff(tree.Hashes, rg.merkleTree())
+	kvs, tombstones := rg.rangeKVs(ranges)
+	return &chordpb.MerkleDiff{Ranges: ranges, Kvs: kvs, Tombstones: tombstones}, nil
+}
 
-	n.rgsMtx.Lock()
-	defer n.rgsMtx.Unlock()
-	for _ ,kv := range replicaMsg.Kv {
-		delete(n.rgs[leaderId].data, kv.Key)
+/* Function: 	serveGet
+ *
+ * Description:
+ * 		Implementation of Get RPC. See Get for the API of embedding applications.
+ */
+func (n *Node) serveGet(context context.Context, key *chordpb.Key) (*chordpb.Value, error) {
+	if err := n.checkDirect(context, key.Key); err != nil {
+		return nil, err
 	}
+	val, err := n.get(context, key.Key, key.Consistency)
+	return val, statusError(err)
+}
 
-	return &chordpb.Empty{}, nil
+/* Function: 	servePut
+ *
+ * Description:
+ * 		Implementation of Put RPC. See Put for the API of embedding applications.
+ */
+func (n *Node) servePut(context context.Context, kv *chordpb.KV) (*chordpb.Empty, error) {
+	if err := n.checkDirect(context, kv.Key); err != nil {
+		return nil, err
+	}
+	err := n.putContext(context, kv.Key, kv.Value, clockFromProto(kv.Clock), kv.Consistency)
+	return &chordpb.Empty{}, statusError(err)
 }
 
-/* Function: 	Get
+/* Function: 	serveDelete
  *
  * Description:
- * 		Implementation of Get RPC.
+ * 		Implementation of Delete RPC. See Delete for the API of embedding applications.
  */
-func (n *Node) Get(context context.Context, key *chordpb.Key) (*chordpb.Value, error) {
-	val, err := n.get(key.Key)
-	if err != nil {
+func (n *Node) serveDelete(context context.Context, key *chordpb.Key) (*chordpb.Empty, error) {
+	if err := n.checkDirect(context, key.Key); err != nil {
 		return nil, err
 	}
+	err := n.delete(context, key.Key)
+	return &chordpb.Empty{}, statusError(err)
+}
+
+/* Function: 	serveLocate
+ *
+ * Description:
+ * 		Implementation of Locate RPC. See Locate for the API of embedding applications.
+ */
+func (n *Node) serveLocate(context context.Context, key *chordpb.Key) (*chordpb.Node, error) {
+	node, err := n.locate(context, key.Key)
+	return node, statusError(err)
+}
 
-	return &chordpb.Value{Value: val}, nil
+/* Function: 	NextHop
+ *
+ * Description:
+ * 		Implementation of NextHop RPC. See nextHop.
+ */
+func (n *Node) NextHop(context context.Context, peerID *chordpb.PeerID) (*chordpb.Hop, error) {
+	hop, saved := n.nextHop(peerID.Id)
+	grpc.SetHeader(context, metadata.Pairs(rttSavedMetadataKey, strconv.FormatInt(int64(saved), 10)))
+	return hop, nil
 }
 
-/* Function: 	Put
+/* Function: 	serveTrace
  *
  * Description:
- * 		Implementation of Put RPC.
+ * 		Implementation of Trace RPC. See Trace for the API of embedding applications.
  */
-func (n *Node) Put(context context.Context, kv *chordpb.KV) (*chordpb.Empty, error) {
-	err := n.put(kv.Key, kv.Value)
-	return &chordpb.Empty{}, err
+func (n *Node) serveTrace(context context.Context, key *chordpb.Key) (*chordpb.Path, error) {
+	path, err := n.trace(context, key.Key)
+	return path, statusError(err)
 }
 
-/* Function: 	Locate
+/* Function: 	checkDirect
  *
  * Description:
- * 		Implementation of Locate RPC.
+ * 		Clients routing requests themselves expect the node they call to be responsible
+ *		for the key. If it is not, their view of the ring is stale: fail instead of
+ * 		forwarding the request, so that they refresh it.
  */
-func (n *Node) Locate(context context.Context, key *chordpb.Key) (*chordpb.Node, error) {
-	return n.locate(key.Key)
+func (n *Node) checkDirect(ctx context.Context, key string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md.Get(directMetadataKey)) == 0 || n.responsible(GetPeerID(key, n.config.KeySize)) {
+		return nil
+	}
+	return statusError(errNotResponsible)
+}
+
+/* Function: 	statusError
+ *
+ * Description:
+ * 		Give the errors returned to clients a gRPC code they can act on, and
+ *		a reason they can tell them apart by. Errors forwarded from the node
+ * 		responsible for a key already carry both.
+ */
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch err {
+	case ErrKeyNotFound:
+		return reasonError(codes.NotFound, ReasonKeyNotFound, err)
+	case errStaleContext:
+		return reasonError(codes.FailedPrecondition, ReasonStaleContext, err)
+	case ErrNoRoute:
+		return reasonError(codes.Unavailable, ReasonNoRoute, err)
+	case errNotResponsible:
+		return reasonError(codes.Aborted, ReasonNotResponsible, err)
+	}
+	return err
+}
+
+// domain of the reasons we give for our errors
+const errorDomain = "chord"
+
+// reasonError returns the status of err with the given code and reason
+func reasonError(code codes.Code, reason string, err error) error {
+	s := status.New(code, err.Error())
+	if detailed, e := s.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain}); e == nil {
+		s = detailed
+	}
+	return s.Err()
+}
+
+/* Function: 	ErrorReason
+ *
+ * Description:
+ * 		Return the reason a node gave for an error returned by one of its RPCs,
+ *		one of the Reason constants, or "" if it gave none. The error may
+ * 		be wrapped.
+ */
+func ErrorReason(err error) string {
+	s, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == errorDomain {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+/* Function: 	NotifyLeave
+ *
+ * Description:
+ * 		Implementation of NotifyLeave RPC. A node is gracefully leaving the ring. If we are its
+ *		successor we store its keys and take over as the leader of its replica group. If we are
+ * 		its predecessor we relink to its successor. In a ring of two nodes we are both.
+ */
+func (n *Node) NotifyLeave(context context.Context, msg *chordpb.LeaveMsg) (*chordpb.Empty, error) {
+	leaver := msg.Node
+	if leaver == nil || msg.Successor == nil {
+		return &chordpb.Empty{}, errors.New("malformed leave message")
+	}
+	n.logger.Infof("NotifyLeave(): %d is leaving the ring\n", leaver.Id)
+
+	// stop routing lookups through the departing node
+	n.replaceFingers(leaver, msg.Successor)
+	n.host.membership.left(leaver)
+
+	if bytes.Equal(msg.Successor.Id, n.Id) {
+		// store the departing node's keys in our RG
+		ourId := idKey(n.Id)
+		n.rgsMtx.Lock()
+		err := n.mergeKVs(n.rgs[ourId], msg.Kvs, msg.Tombstones)
+		n.rgsMtx.Unlock()
+		if err != nil {
+			return &chordpb.Empty{}, err
+		}
+
+		// the departing node's predecessor is now our predecessor
+		n.predMtx.Lock()
+		pred := msg.Predecessor
+		if pred == nil || len(pred.Id) == 0 || bytes.Equal(pred.Id, leaver.Id) || bytes.Equal(pred.Id, n.Id) {
+			pred = nil
+		}
+		n.predecessor = pred
+		n.predMtx.Unlock()
+
+		// rebuild the replica groups before the departing node exits
+		n.takeOverReplicaGroup(leaver.Id)
+	}
+
+	if msg.Predecessor != nil && bytes.Equal(msg.Predecessor.Id, n.Id) {
+		// the departing node's successor is now our successor
+		n.succMtx.Lock()
+		n.successor = msg.Successor
+		n.succMtx.Unlock()
+		n.host.metrics.successorChanges.Inc()
+
+		if bytes.Equal(msg.Successor.Id, n.Id) {
+			// we are the only node left in the ring
+			n.initSuccessorList()
+		} else {
+			n.updateSuccessorList()
+		}
+	}
+
+	n.removeChordClient(leaver)
+	return &chordpb.Empty{}, nil
 }
@@ -1,56 +1,77 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/cdesiniotis/chord"
+	"github.com/cdesiniotis/chord/chordpb"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 )
 
-func Create(cfg *chord.Config) error {
-	chord.CreateChord(cfg)
-	return nil
+func Create(cfg *chord.Config) (*chord.Node, error) {
+	return chord.CreateChord(cfg)
 }
 
-func Join(cfg *chord.Config, ip string, port int) error {
-	_, err := chord.JoinChord(cfg, ip, port)
-	return err
+func Join(cfg *chord.Config, ip string, port int) (*chord.Node, error) {
+	return chord.JoinChord(cfg, ip, port)
 }
 
-// a implementacao cria numNodes nós lógicos, iniciando no porto cfg.Port e incrementando
-// para cada novo nó. Todos os nós se juntam ao nó bootstrap no cfg.Addr:cfg.Port que fica no
-// config.yaml
-func JoinNNodes(cfg *chord.Config, numNodes int) ([]*chord.Node, error) {
-	nodes := make([]*chord.Node, numNodes)
+// Leave asks the node at ip:port to hand its keys over and leave the ring. Leave
+// is internal to the ring, so we dial with the credentials of the local config
+func Leave(cfg *chord.Config, ip string, port int, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	log.Infof("Starting bootstrap node on %s:%d", cfg.Addr, cfg.Port)
-	bootstrap := chord.CreateChord(cfg)
-	nodes[0] = bootstrap
+	target := ip + ":" + strconv.Itoa(port)
+	conn, err := grpc.DialContext(ctx, target, cfg.DialOpts...)
+	if err != nil {
+		return fmt.Errorf("error dialing %s - %s", target, err)
+	}
+	defer conn.Close()
 
-	baseIP := cfg.Addr
-	basePort := int(cfg.Port)
+	ctx = chord.WithRingSecret(ctx, cfg.RingSecret)
+	_, err = chordpb.NewChordClient(conn).Leave(ctx, &chordpb.Empty{})
+	return err
+}
 
-	for i := 1; i < numNodes; i++ {
-		port := basePort + i
-		newCfg := *cfg
-		newCfg.Port = uint32(port)
+// a implementacao cria numNodes nós virtuais num único processo, todos servidos
+// pelo mesmo listener em cfg.Addr:cfg.Port. O primeiro cria o anel e os demais
+// entram nele através do primeiro
+func JoinNNodes(cfg *chord.Config, numNodes int) ([]*chord.Node, error) {
+	newCfg := *cfg
+	newCfg.VirtualNodes = numNodes
 
-		log.Infof("Starting node on port %d joining bootstrap %s:%d", port, baseIP, basePort)
+	log.Infof("Starting %d virtual nodes on %s:%d", numNodes, cfg.Addr, cfg.Port)
+	node, err := chord.CreateChord(&newCfg)
+	if err != nil {
+		return nil, err
+	}
+	return node.VirtualNodes(), nil
+}
 
-		node, err := chord.JoinChord(&newCfg, baseIP, basePort)
+// leaveOnSignal makes node leave the ring gracefully when the process is
+// asked to stop. If its keys can not be handed over, it is shut down anyway
+// and the ring recovers them from replicas
+func leaveOnSignal(node *chord.Node) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		<-signals
+		err := node.Leave(context.Background())
 		if err != nil {
-			log.Errorf("error joining node on port %d: %v", port, err)
-			return nil, err
+			log.Errorf("error leaving the chord ring: %v\n", err)
+			node.Close()
 		}
-		nodes[i] = node
-
-		time.Sleep(200 * time.Millisecond)
-	}
-
-	return nodes, nil
+	}()
 }
 
 func readConfig(filename string, defaults map[string]interface{}) (*viper.Viper, error) {
@@ -68,7 +89,7 @@ func readConfig(filename string, defaults map[string]interface{}) (*viper.Viper,
 
 func defaults() map[string]interface{} {
 	return map[string]interface{}{
-		"keysize":                  8,
+		"keysize":                  160,
 		"addr":                     "0.0.0.0",
 		"port":                     8000,
 		"timeout":                  2000,
@@ -76,9 +97,35 @@ func defaults() map[string]interface{} {
 		"fixfingerinterval":        50,
 		"checkpredecessorinterval": 150,
 		"successorlistsize":        2,
+		"fingercandidates":         4,
+		"iterativelookup":          false,
+		"hoptimeout":               1000,
+		"failurethreshold":         8,
+		"failurewindow":            100,
+		"indirectprobes":           2,
+		"gossipinterval":           1000,
+		"gossipfanout":             3,
+		"suspiciontimeout":         5000,
+		"mergeinterval":            5000,
 		"logging":                  true,
 		"enablemetrics":            false,
+		"metricsaddr":              "",
 		"metricsoutputdir":         "metrics",
+		"metricsinterval":          10000,
+		"datadir":                  "",
+		"snapshotinterval":         60000,
+		"storageengine":            "memory",
+		"antientropyinterval":      30000,
+		"tombstonettl":             86400000,
+		"virtualnodes":             1,
+		"tlscafile":                "",
+		"tlscertfile":              "",
+		"tlskeyfile":               "",
+		"ringsecret":               "",
+		"peernames":                []string{},
+		"apitokens":                []chord.APIToken{},
+		"enablefaults":             false,
+		"publicfaults":             false,
 	}
 }
 
@@ -100,7 +147,19 @@ func main() {
 	if err != nil {
 		log.Fatalf("error unmarshalling config: %v\n", err)
 	}
+	log.SetFormatter(&log.TextFormatter{TimestampFormat: "2006-01-02 15:04:05", FullTimestamp: true})
+	if cfg.Logging {
+		cfg.Logger = log.StandardLogger()
+	}
 	cfg = chord.SetDefaultGrpcOpts(cfg)
+	// fault injection is configured at runtime through the /faults endpoint
+	// of the metrics server
+	if v.GetBool("enablefaults") {
+		cfg.Faults = chord.NewFaults()
+	}
+	if err = chord.ConfigureTLS(cfg); err != nil {
+		log.Fatalf("error configuring TLS: %v\n", err)
+	}
 
 	var cmdCreate = &cobra.Command{
 		Use:   "create",
@@ -108,13 +167,12 @@ func main() {
 		Long:  `create is for creating a new chord distributed hash table`,
 		Args:  cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			err := Create(cfg)
+			node, err := Create(cfg)
 			if err != nil {
 				log.Fatalf("error calling Create(cfg): %v\n", err)
 			}
-			for {
-				time.Sleep(5 * time.Second)
-			}
+			leaveOnSignal(node)
+			<-node.Done()
 		},
 	}
 
@@ -141,13 +199,12 @@ func main() {
 				localCfg.Port = uint32(nodePort)
 			}
 
-			err = Join(&localCfg, args[0], port)
+			node, err := Join(&localCfg, args[0], port)
 			if err != nil {
 				log.Fatalf("error calling Join(cfg, ip, port): %v\n", err)
 			}
-			for {
-				time.Sleep(5 * time.Second)
-			}
+			leaveOnSignal(node)
+			<-node.Done()
 		},
 	}
 	// flags local al comando join para especificar addr/port del nodo que se crea
@@ -157,7 +214,7 @@ func main() {
 	var cmdJoinNNodes = &cobra.Command{
 		Use:   "join-n-nodes [num-nodes]",
 		Short: "Join multiple nodes to an existing chord dht ring",
-		Long:  `join-n-nodes is for create n logical nodes using ports starting from config`,
+		Long:  `join-n-nodes is for creating n virtual nodes served by a single listener on the configured port`,
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			numNodes, err := strconv.Atoi(args[0])
@@ -177,17 +234,36 @@ func main() {
 				localCfg.Port = uint32(nodePort)
 			}
 
-			_, err = JoinNNodes(&localCfg, numNodes)
+			nodes, err := JoinNNodes(&localCfg, numNodes)
 			if err != nil {
 				log.Fatalf("error calling JoinNNodes(cfg, numNodes): %v\n", err)
 			}
-			for {
-				time.Sleep(5 * time.Second)
+			// all virtual nodes share one process
+			leaveOnSignal(nodes[0])
+			<-nodes[0].Done()
+		},
+	}
+	cmdJoinNNodes.Flags().String("addr", "", "Address to bind the nodes to (overrides config)")
+	cmdJoinNNodes.Flags().Int("port", 0, "Port to bind the nodes to (overrides config)")
+
+	var cmdLeave = &cobra.Command{
+		Use:   "leave [ip] [port]",
+		Short: "Gracefully remove a node from the chord dht ring",
+		Long:  `leave is for asking the node at ip:port to hand its keys to its successor and shut down`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			port, err := strconv.Atoi(args[1])
+			if err != nil {
+				log.Fatalf("port argument is not valid\n")
+			}
+			// handing keys over involves several RPCs between the remaining nodes
+			err = Leave(cfg, args[0], port, 10*time.Duration(cfg.Timeout)*time.Millisecond)
+			if err != nil {
+				log.Fatalf("error calling Leave(ip, port): %v\n", err)
 			}
+			log.Infof("node %s:%d left the ring\n", args[0], port)
 		},
 	}
-	cmdJoinNNodes.Flags().String("addr", "", "Base address to bind nodes to (overrides config)")
-	cmdJoinNNodes.Flags().Int("port", 0, "Base port to start creating nodes from (overrides config)")
 
 	var rootCmd = &cobra.Command{Use: "chord"}
 
@@ -195,6 +271,6 @@ func main() {
 	rootCmd.PersistentFlags().String("addr", "0.0.0.0", "Address to bind to")
 	rootCmd.PersistentFlags().Int("port", 8000, "Port to bind to")
 
-	rootCmd.AddCommand(cmdCreate, cmdJoin, cmdJoinNNodes)
+	rootCmd.AddCommand(cmdCreate, cmdJoin, cmdJoinNNodes, cmdLeave)
 	rootCmd.Execute()
 }
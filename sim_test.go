@@ -0,0 +1,524 @@
+package chord
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	simSeed  = flag.Int64("sim.seed", 0, "replay the simulation of this seed instead of -sim.seeds random ones")
+	simSeeds = flag.Int("sim.seeds", defaultSimSeeds(), "number of seeds TestSimulation runs")
+)
+
+// the race detector records the stack of every goroutine and lock of a run,
+// which slows the simulation down by well over an order of magnitude
+func defaultSimSeeds() int {
+	if raceEnabled {
+		return 1
+	}
+	return 5
+}
+
+const (
+	simMinNodes = 3
+	simMaxNodes = 16
+	// how long the ring is left alone, without churn nor message loss,
+	// before checking its invariants
+	simSettle = 3 * time.Minute
+)
+
+// simEvent runs fn at virtual time at. Events at the same time run in the
+// order they were scheduled.
+type simEvent struct {
+	at  time.Duration
+	seq int
+	fn  func()
+}
+
+type simQueue []*simEvent
+
+func (q simQueue) Len() int { return len(q) }
+func (q simQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q simQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *simQueue) Push(x interface{}) { *q = append(*q, x.(*simEvent)) }
+func (q *simQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// simulation drives the nodes of a ring on a MemoryNetwork with a virtual
+// clock. The nodes are opened without their periodic threads: the
+// simulation runs stabilize, fixFinger, checkPredecessor, antiEntropy, and
+// the gossip and partition checks of their hosts one at a time as events,
+// so that a run only depends on its seed.
+type simulation struct {
+	seed int64
+	rng  *rand.Rand
+
+	now    time.Duration // written under nowMtx: RPCs left running after their caller gave up still read the clock
+	nowMtx sync.Mutex
+	seq    int
+	queue  simQueue
+
+	network  *MemoryNetwork
+	nodes    []*Node // live nodes
+	departed []*Node // nodes which crashed or left
+	nextPort int
+	keys     []string // keys whose put was acknowledged
+	trace    []string
+
+	// message loss, decided from the seed and the number of RPCs made
+	// on each path so that it does not depend on goroutine scheduling
+	dropRate float64
+	sent     map[string]uint64
+	sides    map[string]bool // side of each address while the network is partitioned
+	dropMtx  sync.Mutex
+}
+
+func newSimulation(seed int64) *simulation {
+	s := &simulation{
+		seed:     seed,
+		rng:      rand.New(rand.NewSource(seed)),
+		network:  NewMemoryNetwork(),
+		nextPort: 1,
+		sent:     make(map[string]uint64),
+	}
+	s.network.setDrop(s.drop)
+	return s
+}
+
+func (s *simulation) logf(format string, args ...interface{}) {
+	s.trace = append(s.trace, fmt.Sprintf("%9.3fs ", s.now.Seconds())+fmt.Sprintf(format, args...))
+}
+
+func (s *simulation) after(d time.Duration, fn func()) {
+	s.seq++
+	heap.Push(&s.queue, &simEvent{at: s.now + d, seq: s.seq, fn: fn})
+}
+
+// run the events of the next d of virtual time
+func (s *simulation) run(d time.Duration) {
+	end := s.now + d
+	for len(s.queue) > 0 && s.queue[0].at <= end {
+		e := heap.Pop(&s.queue).(*simEvent)
+		s.setNow(e.at)
+		e.fn()
+	}
+	s.setNow(end)
+}
+
+func (s *simulation) setNow(now time.Duration) {
+	s.nowMtx.Lock()
+	s.now = now
+	s.nowMtx.Unlock()
+}
+
+func (s *simulation) drop(from, to, method string) bool {
+	s.dropMtx.Lock()
+	defer s.dropMtx.Unlock()
+	if s.sides != nil && s.sides[from] != s.sides[to] {
+		return true
+	}
+	if s.dropRate == 0 {
+		return false
+	}
+	path := from + ">" + to
+	s.sent[path]++
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s/%d", s.seed, path, s.sent[path])
+	return float64(h.Sum64()%10000) < s.dropRate*10000
+}
+
+func (s *simulation) setDropRate(rate float64) {
+	s.dropMtx.Lock()
+	s.dropRate = rate
+	s.dropMtx.Unlock()
+	s.logf("drop %.0f%% of messages", rate*100)
+}
+
+// partition the network into two random sides of the live nodes for d,
+// then heal it. Nodes joining meanwhile are on the first side
+func (s *simulation) partition(d time.Duration) {
+	sides := make(map[string]bool)
+	var names []string
+	for i, p := range s.rng.Perm(len(s.nodes)) {
+		n := s.nodes[p]
+		addr := fmt.Sprintf("%s:%d", n.Addr, n.Port)
+		sides[addr] = i%2 == 1
+		if sides[addr] {
+			names = append(names, simName(n.Node))
+		}
+	}
+	s.dropMtx.Lock()
+	s.sides = sides
+	s.dropMtx.Unlock()
+	s.logf("partition %s from the other nodes for %v", strings.Join(names, " "), d)
+
+	s.run(d)
+	s.dropMtx.Lock()
+	s.sides = nil
+	s.dropMtx.Unlock()
+	s.logf("heal the partition")
+}
+
+func (s *simulation) alive(n *Node) bool {
+	for _, live := range s.nodes {
+		if live == n {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *simulation) remove(n *Node) {
+	for i, live := range s.nodes {
+		if live == n {
+			s.nodes = append(s.nodes[:i], s.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *simulation) randomNode() *Node {
+	return s.nodes[s.rng.Intn(len(s.nodes))]
+}
+
+// every runs fn on n each interval, from a random phase, until n is gone
+func (s *simulation) every(n *Node, delay, interval time.Duration, fn func()) {
+	var tick func()
+	tick = func() {
+		if !s.alive(n) {
+			return
+		}
+		fn()
+		s.after(interval, tick)
+	}
+	s.after(delay+time.Duration(s.rng.Int63n(int64(interval))), tick)
+}
+
+// schedule the periodic threads started by n.start(), on the same cadence
+func (s *simulation) start(n *Node) {
+	ms := func(i int) time.Duration { return time.Duration(i) * time.Millisecond }
+	s.every(n, 3*time.Second, ms(n.config.StabilizeInterval), n.stabilize)
+	next := 0
+	s.every(n, 3*time.Second, ms(n.config.FixFingerInterval), func() {
+		n.fixFinger(next)
+		next = (next + 1) % n.config.KeySize
+	})
+	s.every(n, 0, ms(n.config.CheckPredecessorInterval), n.checkPredecessor)
+	s.every(n, 0, ms(n.config.AntiEntropyInterval), n.antiEntropy)
+	if n.config.GossipInterval > 0 {
+		s.every(n, 0, ms(n.config.GossipInterval), n.host.gossip)
+	}
+	if n.config.MergeInterval > 0 {
+		s.every(n, 0, ms(n.config.MergeInterval), n.host.checkPartition)
+	}
+}
+
+func (s *simulation) open() (*Node, error) {
+	cfg := memoryConfig(s.network, s.nextPort)
+	cfg.AntiEntropyInterval = 10000
+	s.nextPort++
+	h, err := openHost(cfg)
+	if err != nil {
+		return nil, err
+	}
+	h.vnodes[0].detector.now = s.clock
+	h.membership.now = s.clock
+	h.membership.rng = rand.New(rand.NewSource(s.rng.Int63()))
+	h.rtt.now = s.clock
+	return h.vnodes[0], nil
+}
+
+// clock returns the virtual time, for the failure detectors, the membership
+// and the round-trip times. RPCs take no virtual time, so every peer measures
+// as close as any other and lookups route by id alone
+func (s *simulation) clock() time.Time {
+	s.nowMtx.Lock()
+	defer s.nowMtx.Unlock()
+	return time.Unix(0, 0).Add(s.now)
+}
+
+func (s *simulation) create() error {
+	n, err := s.open()
+	if err != nil {
+		return err
+	}
+	n.create()
+	s.nodes = append(s.nodes, n)
+	s.start(n)
+	s.logf("create %s", simName(n.Node))
+	return nil
+}
+
+func (s *simulation) join() {
+	n, err := s.open()
+	if err != nil {
+		s.logf("join: %v", err)
+		return
+	}
+	other := s.randomNode()
+	if err := n.join(other.Node); err != nil {
+		n.shutdown()
+		s.logf("join %s via %s failed: %v", simName(n.Node), simName(other.Node), err)
+		return
+	}
+	s.nodes = append(s.nodes, n)
+	s.start(n)
+	s.logf("join %s via %s", simName(n.Node), simName(other.Node))
+}
+
+// stop a node without handing its keys over
+func (s *simulation) crash(n *Node) {
+	s.remove(n)
+	s.departed = append(s.departed, n)
+	n.shutdown()
+	s.logf("crash %s", simName(n.Node))
+}
+
+func (s *simulation) leave(n *Node) {
+	if err := n.host.leave(context.Background()); err != nil {
+		s.logf("leave %s failed: %v", simName(n.Node), err)
+		return
+	}
+	s.remove(n)
+	s.departed = append(s.departed, n)
+	s.logf("leave %s", simName(n.Node))
+}
+
+func (s *simulation) put() {
+	key := fmt.Sprintf("sim-%d", len(s.trace))
+	n := s.randomNode()
+	if err := n.put(context.Background(), key, []byte(key), ConsistencyAll); err != nil {
+		s.logf("put %s at %s failed: %v", key, simName(n.Node), err)
+		return
+	}
+	s.keys = append(s.keys, key)
+	s.logf("put %s at %s", key, simName(n.Node))
+}
+
+// script runs steps random actions: joins, crashes, leaves, puts, bursts of
+// message loss and partitions. A crash, leave or partition is only scripted
+// once the ring had the time to settle since the previous change of its
+// members: SuccessorListSize+1 copies of a key can not survive as many
+// failures at once, and a node which just joined only knows of its successor.
+func (s *simulation) script(steps int) {
+	var lastChange time.Duration
+	for i := 0; i < steps; i++ {
+		churn := len(s.nodes) > simMinNodes && s.now-lastChange > time.Minute
+		switch r := s.rng.Intn(10); {
+		case r < 3 && len(s.nodes) < simMaxNodes:
+			s.join()
+			lastChange = s.now
+		case r == 3 && churn:
+			s.crash(s.randomNode())
+			lastChange = s.now
+		case r == 4 && churn:
+			s.leave(s.randomNode())
+			lastChange = s.now
+		case r == 5:
+			s.setDropRate([]float64{0, 0.05, 0.2}[s.rng.Intn(3)])
+		case r == 6 && churn:
+			s.partition(time.Duration(10+s.rng.Intn(50)) * time.Second)
+			lastChange = s.now
+		default:
+			s.put()
+		}
+		s.run(time.Duration(s.rng.Intn(10000)) * time.Millisecond)
+	}
+	s.setDropRate(0)
+	s.run(simSettle)
+}
+
+func (s *simulation) shutdown() {
+	for _, n := range s.nodes {
+		n.shutdown()
+	}
+}
+
+func simName(n *chordpb.Node) string {
+	if n == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%d(%x)", n.Port, n.Id)
+}
+
+// the live nodes sorted by id
+func (s *simulation) ring() []*Node {
+	ring := append([]*Node(nil), s.nodes...)
+	sort.Slice(ring, func(i, j int) bool { return bytes.Compare(ring[i].Id, ring[j].Id) < 0 })
+	return ring
+}
+
+// index in ring of the live node responsible for id
+func simSuccessor(ring []*Node, id []byte) int {
+	i := sort.Search(len(ring), func(i int) bool { return bytes.Compare(ring[i].Id, id) >= 0 })
+	return i % len(ring)
+}
+
+/* Function: 	check
+ *
+ * Description:
+ * 		Return the violations of the invariants of a settled ring: the
+ *		successor, predecessor and successor list of every node point to the
+ *		next live nodes, every finger to the node responsible for its id,
+ *		every acknowledged key is stored by the node responsible for it and
+ *		replicated to the successor list of that node, and gossip told every
+ * 		node which nodes are still members.
+ */
+func (s *simulation) check() []string {
+	var errs []string
+	ring := s.ring()
+	for i, n := range ring {
+		next, prev := ring[(i+1)%len(ring)], ring[(i+len(ring)-1)%len(ring)]
+		n.succMtx.RLock()
+		succ := n.successor
+		n.succMtx.RUnlock()
+		if succ == nil || !bytes.Equal(succ.Id, next.Id) {
+			errs = append(errs, fmt.Sprintf("successor of %s is %s, not %s", simName(n.Node), simName(succ), simName(next.Node)))
+		}
+		n.predMtx.RLock()
+		pred := n.predecessor
+		n.predMtx.RUnlock()
+		if pred == nil || !bytes.Equal(pred.Id, prev.Id) {
+			errs = append(errs, fmt.Sprintf("predecessor of %s is %s, not %s", simName(n.Node), simName(pred), simName(prev.Node)))
+		}
+
+		if len(ring) > n.config.SuccessorListSize {
+			n.succListMtx.RLock()
+			for j, entry := range n.successorList {
+				want := ring[(i+j+1)%len(ring)]
+				if entry == nil || !bytes.Equal(entry.Id, want.Id) {
+					errs = append(errs, fmt.Sprintf("successor list entry %d of %s is %s, not %s", j, simName(n.Node), simName(entry), simName(want.Node)))
+				}
+			}
+			n.succListMtx.RUnlock()
+		}
+
+		n.ftMtx.RLock()
+		for j, entry := range n.fingerTable {
+			want := ring[simSuccessor(ring, fingerMath(n.Id, j, n.config.KeySize))]
+			if !bytes.Equal(entry.Node.Id, want.Id) {
+				errs = append(errs, fmt.Sprintf("finger %d of %s is %s, not %s", j, simName(n.Node), simName(entry.Node), simName(want.Node)))
+			}
+		}
+		n.ftMtx.RUnlock()
+
+		if n.config.GossipInterval > 0 {
+			for _, other := range ring {
+				if n.host.membership.failed(other.Id) {
+					errs = append(errs, fmt.Sprintf("%s believes %s is gone", simName(n.Node), simName(other.Node)))
+				}
+			}
+			for _, other := range s.departed {
+				if !n.host.membership.failed(other.Id) {
+					errs = append(errs, fmt.Sprintf("%s believes %s is still a member", simName(n.Node), simName(other.Node)))
+				}
+			}
+		}
+	}
+
+	for _, key := range s.keys {
+		i := simSuccessor(ring, GetPeerID(key, ring[0].config.KeySize))
+		owner := ring[i]
+		copies := ring[0].config.SuccessorListSize + 1
+		if copies > len(ring) {
+			copies = len(ring)
+		}
+		for j := 0; j < copies; j++ {
+			n := ring[(i+j)%len(ring)]
+			n.rgsMtx.RLock()
+			rg, ok := n.rgs[idKey(owner.Id)]
+			stored := ok && len(rg.entry(key).siblings) > 0
+			n.rgsMtx.RUnlock()
+			if !stored {
+				errs = append(errs, fmt.Sprintf("key %s led by %s is not stored at %s", key, simName(owner.Node), simName(n.Node)))
+			}
+		}
+	}
+	return errs
+}
+
+// a summary of the state of the ring, equal for two runs of the same seed
+func (s *simulation) state() string {
+	var b strings.Builder
+	for _, n := range s.ring() {
+		fmt.Fprintf(&b, "%s succ %s pred %s fingers", simName(n.Node), simName(n.successor), simName(n.predecessor))
+		for _, entry := range n.fingerTable {
+			fmt.Fprintf(&b, " %d", entry.Node.Port)
+		}
+		n.rgsMtx.RLock()
+		fmt.Fprintf(&b, " groups %d\n", len(n.rgs))
+		n.rgsMtx.RUnlock()
+	}
+	return strings.Join(s.trace, "\n") + "\n" + b.String()
+}
+
+func simulate(t *testing.T, seed int64) *simulation {
+	s := newSimulation(seed)
+	defer func() {
+		if r := recover(); r != nil {
+			panic(fmt.Sprintf("seed %d: %v, replay with go test -run TestSimulation -sim.seed=%d", seed, r, seed))
+		}
+	}()
+	err := s.create()
+	assert.Nil(t, err, "create() should not result in error")
+	if err != nil {
+		return s
+	}
+	s.script(60)
+
+	errs := s.check()
+	if len(errs) > 0 {
+		if len(errs) > 10 {
+			errs = append(errs[:10], fmt.Sprintf("... and %d more", len(errs)-10))
+		}
+		t.Errorf("seed %d broke the invariants of the ring, replay with go test -run TestSimulation -sim.seed=%d\n%s\n\ntrace:\n%s",
+			seed, seed, strings.Join(errs, "\n"), strings.Join(s.trace, "\n"))
+	}
+	return s
+}
+
+func TestSimulation(t *testing.T) {
+	seeds := []int64{*simSeed}
+	if *simSeed == 0 {
+		seeds = nil
+		for i := 0; i < *simSeeds; i++ {
+			seeds = append(seeds, time.Now().UnixNano()+int64(i))
+		}
+	}
+	for _, seed := range seeds {
+		s := simulate(t, seed)
+		s.shutdown()
+	}
+}
+
+func TestSimulationReplay(t *testing.T) {
+	// two runs of a seed go through the same states
+	const seed = 42
+	first := simulate(t, seed)
+	first.shutdown()
+	second := simulate(t, seed)
+	second.shutdown()
+	assert.Equal(t, first.state(), second.state(), "a seed should replay the same run")
+}
@@ -0,0 +1,112 @@
+package chord
+
+import (
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Storage engines that can back a replica group
+const (
+	StorageMemory = "memory" // keys are kept in a map, optionally made durable by the write-ahead log
+	StorageLog    = "log"    // keys are kept in an append-only log per replica group under DataDir
+)
+
+// Storage is implemented by the storage engines backing a ReplicaGroup.
+// Engines are not safe for concurrent use, callers synchronize through rgsMtx.
+type Storage interface {
+	// Get returns the value for key and whether key exists
+	Get(key string) ([]byte, bool)
+	// Put stores the value for key, overwriting any previous value
+	Put(key string, value []byte) error
+	// Delete removes key, it is not an error if key does not exist
+	Delete(key string) error
+	// Iterate calls fn for every kv until fn returns false.
+	// fn must not modify the store.
+	Iterate(fn func(key string, value []byte) bool)
+	// RangeByHash calls fn for every kv whose key hashes into (from, to]
+	// until fn returns false. fn must not modify the store.
+	RangeByHash(from, to []byte, fn func(key string, value []byte) bool)
+	// Len returns the number of keys stored
+	Len() int
+	// Close releases any resources held by the engine
+	Close() error
+	// Destroy closes the engine and removes all of its data
+	Destroy() error
+}
+
+/* Function: 	newStorage
+ *
+ * Description:
+ * 		Create the storage engine selected in the config. name identifies
+ *		the store within the data directory.
+ */
+func newStorage(config *Config, name string, logger log.FieldLogger) (Storage, error) {
+	switch config.StorageEngine {
+	case "", StorageMemory:
+		return newMemStore(config.KeySize), nil
+	case StorageLog:
+		if config.DataDir == "" {
+			return nil, errors.New("the log storage engine requires a data directory")
+		}
+		return openLogStore(logStorePath(config.DataDir, name), config.KeySize, logger)
+	}
+	return nil, errors.New("unknown storage engine " + config.StorageEngine)
+}
+
+// memStore is the default in-memory storage engine
+type memStore struct {
+	keySize int
+	data    map[string][]byte
+}
+
+func newMemStore(keySize int) *memStore {
+	return &memStore{keySize: keySize, data: make(map[string][]byte)}
+}
+
+func (s *memStore) Get(key string) ([]byte, bool) {
+	v, ok := s.data[key]
+	return v, ok
+}
+
+func (s *memStore) Put(key string, value []byte) error {
+	s.data[key] = value
+	return nil
+}
+
+func (s *memStore) Delete(key string) error {
+	delete(s.data, key)
+	return nil
+}
+
+func (s *memStore) Iterate(fn func(key string, value []byte) bool) {
+	for k, v := range s.data {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+func (s *memStore) RangeByHash(from, to []byte, fn func(key string, value []byte) bool) {
+	for k, v := range s.data {
+		if !BetweenRightIncl(GetPeerID(k, s.keySize), from, to) {
+			continue
+		}
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+func (s *memStore) Len() int {
+	return len(s.data)
+}
+
+func (s *memStore) Close() error {
+	return nil
+}
+
+func (s *memStore) Destroy() error {
+	s.data = make(map[string][]byte)
+	return nil
+}
@@ -0,0 +1,121 @@
+package chord
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exercise the Storage interface, the same way for every engine
+func testStorage(t *testing.T, s Storage) {
+	assert.Equal(t, 0, s.Len(), "a new store should be empty")
+
+	assert.Nil(t, s.Put("key1", []byte("val1")))
+	assert.Nil(t, s.Put("key2", []byte("val2")))
+	assert.Nil(t, s.Put("key3", []byte("val3")))
+	assert.Nil(t, s.Put("key1", []byte("new1")))
+	assert.Equal(t, 3, s.Len(), "overwriting a key should not change Len()")
+
+	val, ok := s.Get("key1")
+	assert.True(t, ok, "Get() should find a stored key")
+	assert.Equal(t, 0, bytes.Compare(val, []byte("new1")), "Get() should return the latest value")
+
+	assert.Nil(t, s.Delete("key2"))
+	assert.Nil(t, s.Delete("key4"), "deleting a missing key should not result in error")
+	_, ok = s.Get("key2")
+	assert.False(t, ok, "Get() should not find a deleted key")
+	assert.Equal(t, 2, s.Len())
+
+	seen := make(map[string]string)
+	s.Iterate(func(k string, v []byte) bool {
+		seen[k] = string(v)
+		return true
+	})
+	assert.Equal(t, map[string]string{"key1": "new1", "key3": "val3"}, seen)
+
+	/*
+	 * key - key1	 hash - [16]
+	 * key - key3	 hash - [59]
+	 */
+	inRange := make([]string, 0)
+	s.RangeByHash([]byte{16}, []byte{59}, func(k string, v []byte) bool {
+		inRange = append(inRange, k)
+		return true
+	})
+	assert.Equal(t, []string{"key3"}, inRange, "RangeByHash() should only return keys hashing into (from, to]")
+
+	inRange = inRange[:0]
+	s.RangeByHash([]byte{59}, []byte{16}, func(k string, v []byte) bool {
+		inRange = append(inRange, k)
+		return true
+	})
+	assert.Equal(t, []string{"key1"}, inRange, "RangeByHash() should wrap around the ring")
+}
+
+func TestMemStore(t *testing.T) {
+	testStorage(t, newMemStore(8))
+}
+
+func TestLogStore(t *testing.T) {
+	path := logStorePath(t.TempDir(), "test")
+	s, err := openLogStore(path, 8, newLogger(false))
+	assert.Nil(t, err, "openLogStore() should not result in error")
+	testStorage(t, s)
+	s.Close()
+
+	// reopening the log should rebuild the same contents
+	s, err = openLogStore(path, 8, newLogger(false))
+	assert.Nil(t, err, "openLogStore() should not result in error")
+	defer s.Close()
+	assert.Equal(t, 2, s.Len(), "a reopened store should keep its keys")
+	val, ok := s.Get("key1")
+	assert.True(t, ok)
+	assert.Equal(t, 0, bytes.Compare(val, []byte("new1")))
+	_, ok = s.Get("key2")
+	assert.False(t, ok, "a deleted key should stay deleted after reopening")
+}
+
+func TestLogStoreTornRecord(t *testing.T) {
+	path := logStorePath(t.TempDir(), "test")
+	s, _ := openLogStore(path, 8, newLogger(false))
+	s.Put("key1", []byte("val1"))
+	s.Close()
+
+	// simulate a crash in the middle of writing a record
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write([]byte{0, 0, 0, 42, 1, 2})
+	f.Close()
+
+	s, err := openLogStore(path, 8, newLogger(false))
+	assert.Nil(t, err, "openLogStore() should not result in error")
+	assert.Equal(t, 1, s.Len())
+	s.Put("key2", []byte("val2"))
+	s.Close()
+
+	// the record written after the torn one must be readable
+	s, _ = openLogStore(path, 8, newLogger(false))
+	defer s.Close()
+	val, ok := s.Get("key2")
+	assert.True(t, ok, "records appended after a torn record should survive")
+	assert.Equal(t, 0, bytes.Compare(val, []byte("val2")))
+}
+
+func TestLogStoreCompact(t *testing.T) {
+	path := logStorePath(t.TempDir(), "test")
+	s, _ := openLogStore(path, 8, newLogger(false))
+	defer s.Close()
+
+	val := make([]byte, 4096)
+	for i := 0; i < 1024; i++ {
+		s.Put(fmt.Sprintf("key%d", i%8), val)
+	}
+
+	info, _ := os.Stat(path)
+	assert.Less(t, info.Size(), int64(logStoreCompactMinSize), "the log should be compacted once it is mostly garbage")
+	assert.Equal(t, 8, s.Len())
+	_, ok := s.Get("key7")
+	assert.True(t, ok, "compaction should keep live keys")
+}
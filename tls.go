@@ -0,0 +1,71 @@
+package chord
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+/* Function: 	NewTLSCredentials
+ *
+ * Description:
+ * 		Load the credentials for mutual TLS with the other members of a ring. We present
+ *		our certificate both when serving and when dialing, and only accept peers whose
+ * 		certificate chains to the ring CA.
+ */
+func NewTLSCredentials(caFile string, certFile string, keyFile string) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate: %v", err)
+	}
+
+	ca, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates found in " + caFile)
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		// verify the servers we dial
+		RootCAs: pool,
+		// verify the clients dialing us
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+	}), nil
+}
+
+/* Function: 	ConfigureTLS
+ *
+ * Description:
+ * 		Serve and dial with mutual TLS if the config holds a CA, certificate and
+ *		key. Otherwise the config is left untouched.
+ */
+func ConfigureTLS(cfg *Config) error {
+	if cfg.TLSCAFile == "" && cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		return nil
+	}
+	if cfg.TLSCAFile == "" || cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return errors.New("TLS requires a CA, a certificate and a key")
+	}
+
+	creds, err := NewTLSCredentials(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return err
+	}
+
+	dialOpts := make([]grpc.DialOption, 0, 5)
+	dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds), grpc.WithBlock(), grpc.FailOnNonTempDialError(true))
+	cfg.DialOpts = dialOpts
+	cfg.ServerOpts = append(cfg.ServerOpts, grpc.Creds(creds))
+	return nil
+}
@@ -0,0 +1,120 @@
+package chord
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// write a PEM encoded certificate and key to dir, signed by ca (self-signed if ca is nil)
+func writeTestCert(t *testing.T, dir string, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if ca == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		ca, caKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	ioutil.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return cert, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chord-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ca, caKey := writeTestCert(t, dir, "ca", nil, nil)
+	writeTestCert(t, dir, "node", ca, caKey)
+	// a certificate which does not chain to the ring CA
+	otherCa, otherCaKey := writeTestCert(t, dir, "other-ca", nil, nil)
+	writeTestCert(t, dir, "intruder", otherCa, otherCaKey)
+
+	tlsConfig := func(port int) *Config {
+		cfg := DefaultConfig("127.0.0.1", port)
+		cfg.TLSCAFile = filepath.Join(dir, "ca.pem")
+		cfg.TLSCertFile = filepath.Join(dir, "node.pem")
+		cfg.TLSKeyFile = filepath.Join(dir, "node-key.pem")
+		assert.Nil(t, ConfigureTLS(cfg), "ConfigureTLS() should not result in error")
+		return cfg
+	}
+
+	a, err := CreateChord(tlsConfig(8051))
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer a.shutdown()
+	b, err := JoinChord(tlsConfig(8052), "127.0.0.1", 8051)
+	assert.Nil(t, err, "JoinChord() over TLS should not result in error")
+	if err != nil {
+		return
+	}
+	defer b.shutdown()
+
+	err = b.put(context.Background(), "tls-key", []byte("val"), ConsistencyOne)
+	assert.Nil(t, err, "put(k,v) over TLS should not result in error")
+	val, err := a.get(context.Background(), "tls-key", ConsistencyOne)
+	assert.Nil(t, err, "get(k) over TLS should not result in error")
+	assert.Equal(t, "val", string(val.GetValue()))
+
+	locate := func(creds grpc.DialOption) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		conn, err := grpc.DialContext(ctx, "127.0.0.1:8051", creds, grpc.WithBlock(), grpc.FailOnNonTempDialError(true))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = chordpb.NewChordClient(conn).Locate(ctx, &chordpb.Key{Key: "tls-key"})
+		return err
+	}
+
+	creds, err := NewTLSCredentials(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "node.pem"), filepath.Join(dir, "node-key.pem"))
+	assert.Nil(t, err, "NewTLSCredentials() should not result in error")
+	assert.Nil(t, locate(grpc.WithTransportCredentials(creds)), "a client with a certificate from the ring CA should be accepted")
+
+	assert.NotNil(t, locate(grpc.WithInsecure()), "a client without TLS should be rejected")
+
+	creds, err = NewTLSCredentials(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "intruder.pem"), filepath.Join(dir, "intruder-key.pem"))
+	assert.Nil(t, err, "NewTLSCredentials() should not result in error")
+	assert.NotNil(t, locate(grpc.WithTransportCredentials(creds)), "a client whose certificate does not chain to the ring CA should be rejected")
+
+	cfg := DefaultConfig("127.0.0.1", 8053)
+	cfg.TLSCAFile = filepath.Join(dir, "ca.pem")
+	assert.NotNil(t, ConfigureTLS(cfg), "ConfigureTLS() should require a certificate and key along with the CA")
+}
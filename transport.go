@@ -0,0 +1,157 @@
+package chord
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/cdesiniotis/chord/chordpb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// Transport carries the RPCs between the nodes of a ring. Each host has a
+// transport of its own: it serves the RPCs of the host at one address and
+// makes the RPCs of its virtual nodes to other hosts. The default transport
+// is gRPC over TCP; a MemoryNetwork connects hosts within one process.
+type Transport interface {
+	// Listen serves the RPCs of srv at addr (ip:port). interceptor is run
+	// on every incoming RPC.
+	Listen(addr string, srv chordpb.ChordServer, interceptor grpc.UnaryServerInterceptor) error
+
+	// Client returns a connection for the RPCs of the host at addr, see
+	// chordpb.NewChordClient.
+	Client(addr string) (grpc.ClientConnInterface, error)
+
+	// Forget drops the connection to addr, if any, e.g. once the host at
+	// addr is suspected to have failed.
+	Forget(addr string)
+
+	// Connections returns the number of open connections to other hosts.
+	Connections() int
+
+	// GracefulStop stops accepting RPCs and waits for the pending ones to
+	// complete.
+	GracefulStop()
+
+	// Stop stops serving RPCs and closes every connection. Clients can not
+	// be created afterwards.
+	Stop()
+}
+
+var errTransportStopped = errors.New("transport is stopped")
+
+// grpcTransport is the default transport: gRPC over TCP, with a pool of
+// connections to the hosts we made RPCs to.
+type grpcTransport struct {
+	opts   grpcOpts
+	logger log.FieldLogger
+
+	sock       net.Listener
+	grpcServer *grpc.Server
+
+	connPool    map[string]*grpc.ClientConn
+	connPoolMtx sync.RWMutex
+}
+
+func newGRPCTransport(opts grpcOpts, logger log.FieldLogger) *grpcTransport {
+	return &grpcTransport{opts: opts, logger: logger, connPool: make(map[string]*grpc.ClientConn)}
+}
+
+func (t *grpcTransport) Listen(addr string, srv chordpb.ChordServer, interceptor grpc.UnaryServerInterceptor) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	t.sock = lis
+
+	serverOpts := append([]grpc.ServerOption{grpc.ChainUnaryInterceptor(interceptor)}, t.opts.serverOpts...)
+	t.grpcServer = grpc.NewServer(serverOpts...)
+	chordpb.RegisterChordServer(t.grpcServer, srv)
+
+	go func() {
+		err := t.grpcServer.Serve(lis)
+		// Stop() may stop the server before it started serving
+		if err != nil && err != grpc.ErrServerStopped {
+			t.logger.Errorf("error bringing up grpc server: %s\n", err)
+		}
+	}()
+	return nil
+}
+
+/* Function: 	Client
+ *
+ * Description:
+ *		Returns the connection necessary to make a chord grpc call.
+ * 		Adds the connection to the connection pool.
+ */
+func (t *grpcTransport) Client(addr string) (grpc.ClientConnInterface, error) {
+	t.connPoolMtx.RLock()
+	cc, ok := t.connPool[addr]
+	t.connPoolMtx.RUnlock()
+	if ok {
+		return cc, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.opts.timeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr, t.opts.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.connPoolMtx.Lock()
+	defer t.connPoolMtx.Unlock()
+	if t.connPool == nil {
+		conn.Close()
+		return nil, errTransportStopped
+	}
+	t.connPool[addr] = conn
+
+	return conn, nil
+}
+
+/* Function: 	Forget
+ *
+ * Description:
+ *		Removes a stale chord client from connection pool
+ */
+func (t *grpcTransport) Forget(addr string) {
+	t.connPoolMtx.Lock()
+	defer t.connPoolMtx.Unlock()
+	delete(t.connPool, addr)
+}
+
+func (t *grpcTransport) Connections() int {
+	t.connPoolMtx.RLock()
+	defer t.connPoolMtx.RUnlock()
+	return len(t.connPool)
+}
+
+func (t *grpcTransport) GracefulStop() {
+	if t.grpcServer != nil {
+		t.grpcServer.GracefulStop()
+	}
+}
+
+func (t *grpcTransport) Stop() {
+	if t.grpcServer != nil {
+		t.grpcServer.Stop()
+	}
+
+	t.connPoolMtx.Lock()
+	for addr, cc := range t.connPool {
+		t.logger.Infof("Closing conn %v for addr %v\n", cc, addr)
+		cc.Close()
+	}
+	// threads still in the middle of an RPC must not reuse closed conns
+	t.connPool = nil
+	t.connPoolMtx.Unlock()
+
+	if t.sock != nil {
+		t.logger.Infof("Closing listening socket\n")
+		t.sock.Close()
+	}
+}
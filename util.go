@@ -7,9 +7,7 @@ import (
 	"fmt"
 	"github.com/cdesiniotis/chord/chordpb"
 	log "github.com/sirupsen/logrus"
-	"math"
 	"math/big"
-	"strconv"
 )
 
 /* Function:	GetHash
@@ -33,26 +31,47 @@ func GetHash(key string) []byte {
  *		Given an input string (usually ip:port), return
  * 		the peer ID. The peer ID is a SHA-1 hash truncated
  * 		to m bits. There are 2^m -1 possible peer IDs.
- * 		m must be a multiple of 8.
+ * 		m must be a valid key size, see CheckKeySize, as
+ * 		CreateChord and JoinChord check. Returns nil for
+ * 		other sizes, see PeerID to learn why.
  */
 func GetPeerID(key string, m int) []byte {
+	id, _ := PeerID(key, m)
+	return id
+}
 
-	if m%8 != 0 {
-		log.Fatalf("GetPeerID(): m is not a multiple of 8\n")
+/* Function:	PeerID
+ *
+ * Description:
+ *		Same as GetPeerID, but returns an error if m is
+ * 		not a valid key size rather than a nil peer ID.
+ */
+func PeerID(key string, m int) ([]byte, error) {
+	if err := CheckKeySize(m); err != nil {
+		return nil, err
 	}
 
 	hash := GetHash(key)
 	str := hex.EncodeToString(hash)
 
 	numHexChars := m / 4
-	var id []byte
-	err := error(nil)
-	id, err = hex.DecodeString(str[:numHexChars])
-	if err != nil {
-		return nil
-	}
+	return hex.DecodeString(str[:numHexChars])
+}
 
-	return id
+/* Function:	CheckKeySize
+ *
+ * Description:
+ *		Returns an error unless m is a valid size for peer IDs:
+ * 		a multiple of 8 between 8 and 160, the size of a SHA-1 hash.
+ */
+func CheckKeySize(m int) error {
+	if m <= 0 || m%8 != 0 {
+		return fmt.Errorf("key size %d is not a positive multiple of 8", m)
+	}
+	if m > sha1.Size*8 {
+		return fmt.Errorf("key size %d is larger than %d bits", m, sha1.Size*8)
+	}
+	return nil
 }
 
 /* Function:	GetLocationOnRing
@@ -63,12 +82,12 @@ func GetPeerID(key string, m int) []byte {
  * 		testing purposes. Assumes id is a multiple of 8 bits.
  */
 func GetLocationOnRing(id []byte) float64 {
-	str := hex.EncodeToString(id)
-	m := len(str)
-	max := math.Pow(2.0, float64(m*4)) - 1
+	max := new(big.Int).Lsh(big.NewInt(1), uint(len(id)*8))
+	max.Sub(max, big.NewInt(1))
 
-	idInt, _ := strconv.ParseUint(str, 16, 64)
-	return (float64(idInt) / max) * 100.0
+	idInt := new(big.Int).SetBytes(id)
+	loc, _ := new(big.Float).Quo(new(big.Float).SetInt(idInt), new(big.Float).SetInt(max)).Float64()
+	return loc * 100.0
 }
 
 /* Function:	BetweenRightIncl
@@ -118,16 +137,20 @@ func Contains(list []*chordpb.Node, node *chordpb.Node) bool {
  * 		the node's id in hex or decimal.
  */
 func PrintNode(n *chordpb.Node, hex bool, label string) {
+	printNode(log.StandardLogger(), n, hex, label)
+}
+
+func printNode(logger log.FieldLogger, n *chordpb.Node, hex bool, label string) {
 	if n == nil {
 		//fmt.Printf("%s - nil\n", label)
-		log.Infof("%s - nil", label)
+		logger.Infof("%s - nil", label)
 		return
 	}
 
 	if hex {
-		log.Infof("%s - {id: %x\t addr: %s\t port: %d}\n", label, n.Id, n.Addr, n.Port)
+		logger.Infof("%s - {id: %x\t addr: %s\t port: %d}\n", label, n.Id, n.Addr, n.Port)
 	} else {
-		log.Infof("%s - {id: %d\t addr: %s\t port: %d}\n", label, n.Id, n.Addr, n.Port)
+		logger.Infof("%s - {id: %d\t addr: %s\t port: %d}\n", label, n.Id, n.Addr, n.Port)
 	}
 
 }
@@ -143,7 +166,7 @@ func PrintSuccessorList(n *Node) {
 	defer n.succListMtx.RUnlock()
 
 	for i, node := range n.successorList {
-		PrintNode(node, false, fmt.Sprintf("Successor %d", i))
+		printNode(n.logger, node, false, fmt.Sprintf("Successor %d", i))
 	}
 }
 
@@ -151,9 +174,16 @@ func PrintReplicaGroupMembership(n *Node) {
 	n.rgsMtx.RLock()
 	defer n.rgsMtx.RUnlock()
 
-	log.Infof("------Replica Group Membership------\n")
+	n.logger.Infof("------Replica Group Membership------\n")
 	for id, _ := range n.rgs {
-		log.Infof("RG Leader ID: %d\t RG data: %v\n", id, n.rgs[id].data)
+		data := make(map[string][][]byte, n.rgs[id].data.Len())
+		n.rgs[id].data.Iterate(func(k string, v []byte) bool {
+			for _, kv := range n.rgs[id].siblingKVs(k, v) {
+				data[k] = append(data[k], kv.Value)
+			}
+			return true
+		})
+		n.logger.Infof("RG Leader ID: %s\t RG data: %v\n", id, data)
 	}
 }
 
@@ -167,10 +197,33 @@ func Uint64ToBytes(i uint64) []byte {
 	return temp.SetUint64(i).Bytes()
 }
 
-func Distance(a, b uint64, n int) uint64 {
-	sub := float64(a-b)
-	_n := float64(n)
-	return uint64(math.Min(math.Abs(sub), float64(_n-math.Abs(sub))))
+/* Function:	idKey
+ *
+ * Description:
+ *		Return the key used to index a peer ID in maps, such as
+ * 		the replica groups a node is a member of.
+ */
+func idKey(id []byte) string {
+	return hex.EncodeToString(id)
+}
+
+/* Function:	Distance
+ *
+ * Description:
+ *		Return the distance between ids a and b on a ring
+ * 		of 2^m ids, going whichever way round is shorter.
+ */
+func Distance(a, b []byte, m int) *big.Int {
+	n := new(big.Int).Lsh(big.NewInt(1), uint(m))
+
+	sub := new(big.Int).SetBytes(a)
+	sub.Sub(sub, new(big.Int).SetBytes(b)).Abs(sub)
+
+	other := new(big.Int).Sub(n, sub)
+	if other.Cmp(sub) < 0 {
+		return other
+	}
+	return sub
 }
 
 // returns true if same, false if different
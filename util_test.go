@@ -1,9 +1,10 @@
 package chord
 
 import (
+	"bytes"
 	"github.com/cdesiniotis/chord/chordpb"
 	"github.com/stretchr/testify/assert"
-	"math"
+	"math/big"
 	"testing"
 )
 
@@ -94,20 +95,55 @@ func TestBetweenRightIncl(t *testing.T) {
 }
 
 func TestDistance(t *testing.T) {
-	var a, b, res uint64
-	n := 8
+	var res *big.Int
+	m := 8
+
+	a := []byte{255}
+	b := []byte{30}
+
+	res = Distance(a, b, m)
+	assert.Equal(t, 31, int(res.Int64()), "Distance(255,30,256) should be 31")
+
+	a = []byte{255}
+	b = []byte{0}
+	res = Distance(a, b, m)
+	assert.Equal(t, 1, int(res.Int64()), "Distance(255,0,256) should be 1")
+
+	// ids wider than 64 bits
+	m = 160
+	a = make([]byte, 20)
+	b = make([]byte, 20)
+	a[0] = 0xff
+	b[19] = 1
+	res = Distance(a, b, m)
+	ans := new(big.Int).Lsh(big.NewInt(1), 152)
+	ans.Add(ans, big.NewInt(1))
+	assert.Equal(t, 0, res.Cmp(ans), "Distance() should wrap around a 160-bit ring")
+}
+
+func TestGetPeerID(t *testing.T) {
+	id := GetPeerID("0.0.0.0:8001", 8)
+	assert.Equal(t, 0, bytes.Compare(id, []byte{118}), "GetPeerID(0.0.0.0:8001, 8) should be 118")
 
-	a = 255
-	b = 30
+	id = GetPeerID("0.0.0.0:8001", 160)
+	assert.Equal(t, 20, len(id), "a 160-bit peer ID should be 20 bytes long")
+	assert.Equal(t, 0, bytes.Compare(id, GetHash("0.0.0.0:8001")), "a 160-bit peer ID should be the whole SHA-1 hash")
 
-	res = Distance(a, b, int(math.Pow(2.0, float64(n))))
-	assert.Equal(t, 31, int(res), "Distance(255,30,256) should be 31")
+	// invalid sizes are reported rather than bringing the process down
+	for _, m := range []int{0, 12, 256} {
+		_, err := PeerID("0.0.0.0:8001", m)
+		assert.NotNilf(t, err, "PeerID() should fail for a key size of %d", m)
+		assert.Nilf(t, GetPeerID("0.0.0.0:8001", m), "GetPeerID() should not return a peer ID of %d bits", m)
+	}
+}
 
-	a = 255
-	b = 0
-	res = Distance(a, b, int(math.Pow(2.0, float64(n))))
-	assert.Equal(t, 1, int(res), "Distance(255,0,256) should be 1")
+func TestGetLocationOnRing(t *testing.T) {
+	assert.Equal(t, 0.0, GetLocationOnRing([]byte{0}))
+	assert.Equal(t, 100.0, GetLocationOnRing([]byte{255}))
 
+	id := make([]byte, 20)
+	id[0] = 0x80
+	assert.InDelta(t, 50.0, GetLocationOnRing(id), 0.0001, "GetLocationOnRing() should handle 160-bit ids")
 }
 
 func TestCompareSuccessorLists(t *testing.T) {
@@ -0,0 +1,459 @@
+package chord
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/cdesiniotis/chord/chordpb"
+)
+
+// vclock is a vector clock, counting the writes of a key coordinated by each
+// node. Keys are hex encoded node IDs.
+type vclock map[string]uint64
+
+// sibling is one version of a key's value
+type sibling struct {
+	clock vclock
+	value []byte
+}
+
+// entry holds every version of a key a replica group knows of: the concurrent
+// values not superseded by a later write, and the clock of the key's deletion
+type entry struct {
+	siblings  []sibling
+	tombstone vclock // nil if the key was not deleted
+}
+
+var errStaleContext = errors.New("key has versions newer than the given context, read it again")
+
+/* Function: 	descends
+ *
+ * Description:
+ * 		Returns true if c has seen every write o has seen.
+ */
+func (c vclock) descends(o vclock) bool {
+	for node, counter := range o {
+		if c[node] < counter {
+			return false
+		}
+	}
+	return true
+}
+
+/* Function: 	merge
+ *
+ * Description:
+ * 		Returns a new clock which has seen every write c or o has seen.
+ */
+func (c vclock) merge(o vclock) vclock {
+	res := make(vclock, len(c)+len(o))
+	for node, counter := range c {
+		res[node] = counter
+	}
+	for node, counter := range o {
+		if counter > res[node] {
+			res[node] = counter
+		}
+	}
+	return res
+}
+
+/* Function: 	increment
+ *
+ * Description:
+ * 		Returns a new clock recording one more write coordinated by node id.
+ */
+func (c vclock) increment(id []byte) vclock {
+	res := c.merge(nil)
+	res[idKey(id)]++
+	return res
+}
+
+func (c vclock) toProto() []*chordpb.ClockEntry {
+	nodes := make([]string, 0, len(c))
+	for node := range c {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	entries := make([]*chordpb.ClockEntry, 0, len(c))
+	for _, node := range nodes {
+		id, _ := hex.DecodeString(node)
+		entries = append(entries, &chordpb.ClockEntry{Node: id, Counter: c[node]})
+	}
+	return entries
+}
+
+func clockFromProto(entries []*chordpb.ClockEntry) vclock {
+	if len(entries) == 0 {
+		return nil
+	}
+	c := make(vclock, len(entries))
+	for _, e := range entries {
+		c[idKey(e.Node)] = e.Counter
+	}
+	return c
+}
+
+/* Function: 	clock
+ *
+ * Description:
+ * 		Returns a clock which has seen every version in the entry. A write
+ * 		made with this clock supersedes all of them.
+ */
+func (e entry) clock() vclock {
+	c := e.tombstone.merge(nil)
+	for _, s := range e.siblings {
+		c = c.merge(s.clock)
+	}
+	return c
+}
+
+/* Function: 	reconcile
+ *
+ * Description:
+ * 		Drop every version which is superseded by another version of the key.
+ *		Values written concurrently are all kept as siblings. A deletion only
+ * 		removes the values it has seen.
+ */
+func (e entry) reconcile() entry {
+	siblings := make([]sibling, 0, len(e.siblings))
+	for i, s := range e.siblings {
+		if e.tombstone != nil && e.tombstone.descends(s.clock) {
+			continue
+		}
+		superseded := false
+		for j, o := range e.siblings {
+			if i == j || !o.clock.descends(s.clock) {
+				continue
+			}
+			// keep the first of two copies of the same version
+			if !s.clock.descends(o.clock) || j < i {
+				superseded = true
+				break
+			}
+		}
+		if !superseded {
+			siblings = append(siblings, s)
+		}
+	}
+	sort.Slice(siblings, func(i, j int) bool {
+		return bytes.Compare(encodeClock(siblings[i].clock), encodeClock(siblings[j].clock)) < 0
+	})
+
+	tombstone := e.tombstone
+	for _, s := range siblings {
+		if s.clock.descends(tombstone) {
+			tombstone = nil
+			break
+		}
+	}
+	return entry{siblings: siblings, tombstone: tombstone}
+}
+
+/* Function: 	add
+ *
+ * Description:
+ * 		Returns the entry holding the versions of both e and o.
+ */
+func (e entry) add(o entry) entry {
+	res := entry{siblings: make([]sibling, 0, len(e.siblings)+len(o.siblings))}
+	res.siblings = append(res.siblings, e.siblings...)
+	res.siblings = append(res.siblings, o.siblings...)
+	if e.tombstone != nil || o.tombstone != nil {
+		res.tombstone = e.tombstone.merge(o.tombstone)
+	}
+	return res.reconcile()
+}
+
+// return the versions of a key stored by the replica group as KVs, one per sibling
+func (rg *ReplicaGroup) siblingKVs(key string, buf []byte) []*chordpb.KV {
+	siblings, err := decodeSiblings(buf)
+	if err != nil {
+		rg.logger.Errorf("siblingKVs(%s): %v\n", key, err)
+	}
+	kvs := make([]*chordpb.KV, 0, len(siblings))
+	for _, s := range siblings {
+		kvs = append(kvs, &chordpb.KV{Key: key, Value: s.value, Clock: s.clock.toProto()})
+	}
+	return kvs
+}
+
+// return a deletion stored by the replica group as a KV
+func (rg *ReplicaGroup) tombstoneKV(key string, buf []byte) *chordpb.KV {
+	clock, err := decodeClock(buf)
+	if err != nil {
+		rg.logger.Errorf("tombstoneKV(%s): %v\n", key, err)
+	}
+	return &chordpb.KV{Key: key, Clock: clock.toProto()}
+}
+
+// group versions shipped as KVs by key
+func entriesFromKVs(kvs []*chordpb.KV, tombstones []*chordpb.KV) map[string]entry {
+	entries := make(map[string]entry)
+	for _, kv := range kvs {
+		e := entries[kv.Key]
+		e.siblings = append(e.siblings, sibling{clock: clockFromProto(kv.Clock), value: kv.Value})
+		entries[kv.Key] = e
+	}
+	for _, kv := range tombstones {
+		e := entries[kv.Key]
+		e.tombstone = e.tombstone.merge(clockFromProto(kv.Clock))
+		entries[kv.Key] = e
+	}
+	return entries
+}
+
+// return the entry as the result of a read
+func (e entry) toValue() *chordpb.Value {
+	val := &chordpb.Value{Tombstone: e.tombstone.toProto(), Context: e.clock().toProto()}
+	for _, s := range e.siblings {
+		val.Siblings = append(val.Siblings, &chordpb.Sibling{Value: s.value, Clock: s.clock.toProto()})
+	}
+	if len(e.siblings) == 1 {
+		val.Value = e.siblings[0].value
+	}
+	return val
+}
+
+func entryFromValue(val *chordpb.Value) entry {
+	e := entry{tombstone: clockFromProto(val.Tombstone)}
+	for _, s := range val.Siblings {
+		e.siblings = append(e.siblings, sibling{clock: clockFromProto(s.Clock), value: s.Value})
+	}
+	return e
+}
+
+/*
+ * Clocks, siblings and entries are stored as:
+ *		clock   - | n | n * (| node id | counter |) |
+ *		sibling - | clock | value |
+ *		entry   - | n | n * sibling | tombstone clock |
+ *		tombstone - | clock | deletion time |
+ * Node ids and values are prefixed by their uvarint length, all
+ * numbers are uvarints. A stored clock, list of siblings or entry
+ * starts with the version of its format, storedFormat. Values stored
+ * before the format was versioned are read by the legacy functions.
+ */
+
+// version of the format of stored values. 0xff neither starts a list of
+// fewer than 128 siblings nor a small version number, as the unversioned
+// formats did, nor UTF-8 text.
+const storedFormat byte = 0xff
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func readBytes(buf []byte) ([]byte, []byte, error) {
+	l, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < l {
+		return nil, nil, errCorruptValue
+	}
+	return buf[n : n+int(l)], buf[n+int(l):], nil
+}
+
+func readUvarint(buf []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return 0, nil, errCorruptValue
+	}
+	return v, buf[n:], nil
+}
+
+var errCorruptValue = errors.New("corrupt stored value")
+
+func appendClock(buf []byte, c vclock) []byte {
+	entries := c.toProto()
+	buf = binary.AppendUvarint(buf, uint64(len(entries)))
+	for _, e := range entries {
+		buf = appendBytes(buf, e.Node)
+		buf = binary.AppendUvarint(buf, e.Counter)
+	}
+	return buf
+}
+
+func readClock(buf []byte) (vclock, []byte, error) {
+	n, buf, err := readUvarint(buf)
+	if err != nil || n == 0 {
+		return nil, buf, err
+	}
+	// every entry takes at least 2 bytes
+	if n > uint64(len(buf)/2) {
+		return nil, nil, errCorruptValue
+	}
+	c := make(vclock, n)
+	for i := uint64(0); i < n; i++ {
+		var id []byte
+		id, buf, err = readBytes(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		c[idKey(id)], buf, err = readUvarint(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return c, buf, nil
+}
+
+func encodeClock(c vclock) []byte {
+	return appendClock([]byte{storedFormat}, c)
+}
+
+func decodeClock(buf []byte) (vclock, error) {
+	if len(buf) == 0 || buf[0] != storedFormat {
+		return legacyClock(buf), nil
+	}
+	c, _, err := readClock(buf[1:])
+	return c, err
+}
+
+// a tombstone is followed by the unix milliseconds at which we first stored
+// it. Tombstones stored without it read as deleted at the zero time.
+func encodeTombstone(c vclock, at time.Time) []byte {
+	return binary.AppendUvarint(encodeClock(c), uint64(at.UnixMilli()))
+}
+
+func decodeTombstone(buf []byte) (vclock, time.Time, error) {
+	if len(buf) == 0 || buf[0] != storedFormat {
+		return legacyClock(buf), time.Time{}, nil
+	}
+	c, buf, err := readClock(buf[1:])
+	if err != nil || len(buf) == 0 {
+		return c, time.Time{}, err
+	}
+	ms, _, err := readUvarint(buf)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return c, time.UnixMilli(int64(ms)), nil
+}
+
+func appendSiblings(buf []byte, siblings []sibling) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(siblings)))
+	for _, s := range siblings {
+		buf = appendClock(buf, s.clock)
+		buf = appendBytes(buf, s.value)
+	}
+	return buf
+}
+
+func encodeSiblings(siblings []sibling) []byte {
+	return appendSiblings([]byte{storedFormat}, siblings)
+}
+
+func readSiblings(buf []byte) ([]sibling, []byte, error) {
+	n, buf, err := readUvarint(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	// every sibling takes at least 2 bytes
+	if n > uint64(len(buf)/2) {
+		return nil, nil, errCorruptValue
+	}
+	siblings := make([]sibling, 0, n)
+	for i := uint64(0); i < n; i++ {
+		var s sibling
+		s.clock, buf, err = readClock(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.value, buf, err = readBytes(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		siblings = append(siblings, s)
+	}
+	return siblings, buf, nil
+}
+
+func decodeSiblings(buf []byte) ([]sibling, error) {
+	if len(buf) == 0 || buf[0] != storedFormat {
+		return legacySiblings(buf), nil
+	}
+	siblings, _, err := readSiblings(buf[1:])
+	return siblings, err
+}
+
+func encodeEntry(e entry) []byte {
+	return appendClock(encodeSiblings(e.siblings), e.tombstone)
+}
+
+func decodeEntry(buf []byte) (entry, error) {
+	if len(buf) == 0 || buf[0] != storedFormat {
+		return legacyEntry(buf), nil
+	}
+	return readEntry(buf[1:])
+}
+
+func readEntry(buf []byte) (entry, error) {
+	siblings, buf, err := readSiblings(buf)
+	if err != nil {
+		return entry{}, err
+	}
+	tombstone, _, err := readClock(buf)
+	if err != nil {
+		return entry{}, err
+	}
+	return entry{siblings: siblings, tombstone: tombstone}, nil
+}
+
+/*
+ * Values stored before the format was versioned are, from the newest:
+ *		| clock |, | siblings | or | entry | as above, without storedFormat
+ *		| version (8) | value |, a big-endian version counted by the leader
+ *		| value |, the value alone
+ * A version counted by the leader is read as the clock of a write by an
+ * unknown node, which every later write of the key supersedes.
+ */
+
+// return the clock of a write counted as version by the leader
+func legacyVersionClock(version uint64) vclock {
+	return vclock{"": version}
+}
+
+// versions of the leader were small, so their first byte is 0
+func isLegacyVersion(buf []byte) bool {
+	return len(buf) >= 8 && buf[0] == 0
+}
+
+func legacyClock(buf []byte) vclock {
+	if c, rest, err := readClock(buf); err == nil && len(c) > 0 && len(rest) == 0 {
+		return c
+	}
+	if len(buf) == 8 && isLegacyVersion(buf) {
+		return legacyVersionClock(binary.BigEndian.Uint64(buf))
+	}
+	// a deletion of unknown version
+	return vclock{}
+}
+
+// return the value stored without a clock as a single sibling
+func legacyValue(buf []byte) []sibling {
+	if isLegacyVersion(buf) {
+		return []sibling{{clock: legacyVersionClock(binary.BigEndian.Uint64(buf)), value: buf[8:]}}
+	}
+	return []sibling{{clock: vclock{}, value: buf}}
+}
+
+func legacySiblings(buf []byte) []sibling {
+	if siblings, rest, err := readSiblings(buf); err == nil && len(siblings) > 0 && len(rest) == 0 {
+		return siblings
+	}
+	return legacyValue(buf)
+}
+
+func legacyEntry(buf []byte) entry {
+	if siblings, rest, err := readSiblings(buf); err == nil && len(siblings) > 0 {
+		if tombstone, rest, err := readClock(rest); err == nil && len(rest) == 0 {
+			return entry{siblings: siblings, tombstone: tombstone}
+		}
+	}
+	return entry{siblings: legacyValue(buf)}
+}
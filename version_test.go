@@ -0,0 +1,179 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/status"
+)
+
+func TestVClock(t *testing.T) {
+	a := vclock{}.increment([]byte{1})
+	b := a.increment([]byte{2})
+	c := a.increment([]byte{3})
+
+	assert.True(t, b.descends(a), "a later write should descend an earlier one")
+	assert.False(t, a.descends(b), "an earlier write should not descend a later one")
+	assert.False(t, b.descends(c), "concurrent writes should not descend each other")
+	assert.False(t, c.descends(b), "concurrent writes should not descend each other")
+
+	m := b.merge(c)
+	assert.True(t, m.descends(b) && m.descends(c), "a merged clock should descend both clocks")
+	assert.Equal(t, uint64(1), a[idKey([]byte{1})], "incrementing should not modify the original clock")
+}
+
+func TestReconcile(t *testing.T) {
+	a := vclock{}.increment([]byte{1})
+	b := a.increment([]byte{2})
+	c := a.increment([]byte{3})
+
+	e := entry{siblings: []sibling{{clock: a, value: []byte("a")}}}
+	e = e.add(entry{siblings: []sibling{{clock: b, value: []byte("b")}}})
+	assert.Equal(t, 1, len(e.siblings), "a newer version should replace an older one")
+	assert.Equal(t, 0, bytes.Compare(e.siblings[0].value, []byte("b")))
+
+	e = e.add(entry{siblings: []sibling{{clock: a, value: []byte("a")}}})
+	assert.Equal(t, 1, len(e.siblings), "an older version arriving late should be dropped")
+
+	e = e.add(entry{siblings: []sibling{{clock: c, value: []byte("c")}}})
+	assert.Equal(t, 2, len(e.siblings), "concurrent versions should be kept as siblings")
+
+	e = e.add(entry{siblings: []sibling{{clock: c, value: []byte("c")}}})
+	assert.Equal(t, 2, len(e.siblings), "receiving a version twice should not add a sibling")
+
+	// a deletion only removes the versions it has seen
+	e = e.add(entry{tombstone: b})
+	assert.Equal(t, 1, len(e.siblings), "a deletion should remove the versions it has seen")
+	assert.Equal(t, 0, bytes.Compare(e.siblings[0].value, []byte("c")))
+	assert.NotNil(t, e.tombstone, "a deletion concurrent with a value should be kept")
+
+	e = e.add(entry{tombstone: e.clock().increment([]byte{1})})
+	assert.Equal(t, 0, len(e.siblings), "a deletion which saw every version should remove them all")
+
+	e = e.add(entry{siblings: []sibling{{clock: e.clock().increment([]byte{2}), value: []byte("d")}}})
+	assert.Equal(t, 1, len(e.siblings), "a write after a deletion should bring the key back")
+	assert.Nil(t, e.tombstone, "a write after a deletion should supersede it")
+}
+
+func TestEncodeEntry(t *testing.T) {
+	a := vclock{}.increment([]byte{1, 2})
+	b := a.increment([]byte{3, 4})
+	c := a.increment([]byte{5, 6})
+	e := entry{siblings: []sibling{{clock: b, value: []byte("b")}, {clock: c, value: nil}}, tombstone: a}
+
+	res, err := decodeEntry(encodeEntry(e))
+	assert.Nil(t, err, "decodeEntry() should not result in error")
+	assert.Equal(t, 2, len(res.siblings))
+	assert.Equal(t, b, res.siblings[0].clock)
+	assert.Equal(t, 0, bytes.Compare(res.siblings[0].value, []byte("b")))
+	assert.Equal(t, c, res.siblings[1].clock)
+	assert.Equal(t, 0, len(res.siblings[1].value))
+	assert.Equal(t, a, res.tombstone)
+
+	res, err = decodeEntry(encodeEntry(entry{}))
+	assert.Nil(t, err, "decodeEntry() should not result in error")
+	assert.Equal(t, 0, len(res.siblings))
+	assert.Nil(t, res.tombstone)
+
+	_, err = decodeEntry(encodeEntry(e)[:5])
+	assert.NotNil(t, err, "decodeEntry() should result in error for a truncated entry")
+
+	// counts larger than the value can hold are refused before allocating
+	huge := binary.AppendUvarint([]byte{storedFormat}, 1<<40)
+	_, err = decodeClock(huge)
+	assert.Equal(t, errCorruptValue, err, "decodeClock() should refuse a clock longer than the value")
+	_, err = decodeSiblings(huge)
+	assert.Equal(t, errCorruptValue, err, "decodeSiblings() should refuse more siblings than the value holds")
+	_, err = decodeEntry(huge)
+	assert.Equal(t, errCorruptValue, err, "decodeEntry() should refuse more siblings than the value holds")
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	a := vclock{}.increment([]byte{1, 2})
+	e := entry{siblings: []sibling{{clock: a, value: []byte("a")}}, tombstone: vclock{}.increment([]byte{3})}
+
+	// values stored as they were before the format was versioned
+	res, err := decodeEntry(encodeEntry(e)[1:])
+	assert.Nil(t, err, "decodeEntry() should not result in error")
+	assert.Equal(t, e, res, "an unversioned entry should be read as is")
+	siblings, err := decodeSiblings(encodeSiblings(e.siblings)[1:])
+	assert.Nil(t, err, "decodeSiblings() should not result in error")
+	assert.Equal(t, e.siblings, siblings, "unversioned siblings should be read as is")
+	clock, err := decodeClock(encodeClock(a)[1:])
+	assert.Nil(t, err, "decodeClock() should not result in error")
+	assert.Equal(t, a, clock, "an unversioned clock should be read as is")
+
+	// a value versioned by its leader, and a raw value
+	versioned := append([]byte{0, 0, 0, 0, 0, 0, 0, 7}, "v"...)
+	siblings, err = decodeSiblings(versioned)
+	assert.Nil(t, err, "decodeSiblings() should not result in error")
+	if assert.Equal(t, 1, len(siblings)) {
+		assert.Equal(t, "v", string(siblings[0].value))
+		assert.Equal(t, legacyVersionClock(7), siblings[0].clock)
+	}
+	clock, err = decodeClock(versioned[:8])
+	assert.Nil(t, err, "decodeClock() should not result in error")
+	assert.Equal(t, legacyVersionClock(7), clock, "the version of a deletion should be read as a clock")
+	res, err = decodeEntry([]byte("raw value"))
+	assert.Nil(t, err, "decodeEntry() should not result in error")
+	if assert.Equal(t, 1, len(res.siblings)) {
+		assert.Equal(t, "raw value", string(res.siblings[0].value))
+	}
+
+	// a later write supersedes a value versioned by its leader
+	old := entry{siblings: siblings}
+	next := old.add(entry{siblings: []sibling{{clock: old.clock().increment([]byte{1}), value: []byte("w")}}})
+	if assert.Equal(t, 1, len(next.siblings)) {
+		assert.Equal(t, "w", string(next.siblings[0].value))
+	}
+}
+
+func TestSiblings(t *testing.T) {
+	key := "sibling-key"
+
+	err := n1.put(context.Background(), key, []byte("val1"), ConsistencyAll)
+	assert.Nil(t, err, "put(k,v) with consistency ALL should not result in error")
+
+	// find the leader for the key and a replica
+	node, err := n1.locate(context.Background(), key)
+	assert.Nil(t, err, "locate(k) should not result in error")
+	var leader, replica *Node
+	for _, n := range []*Node{n1, n2, n3} {
+		if bytes.Equal(n.Id, node.Id) {
+			leader = n
+		} else if replica == nil {
+			replica = n
+		}
+	}
+	assert.NotNil(t, leader, "the key should be led by one of the nodes")
+
+	// a replica which took over the key while the leader was unreachable
+	// wrote a version without seeing the leader's
+	leader.rgsMtx.RLock()
+	base := leader.rgs[idKey(leader.Id)].entry(key).clock()
+	leader.rgsMtx.RUnlock()
+	replica.rgsMtx.Lock()
+	replica.rgs[idKey(leader.Id)].merge(key, entry{siblings: []sibling{{clock: vclock{}.increment(replica.Id), value: []byte("val2")}}})
+	replica.rgsMtx.Unlock()
+
+	val, err := n1.get(context.Background(), key, ConsistencyAll)
+	assert.Nil(t, err, "get(k) with consistency ALL should not result in error")
+	assert.Equal(t, 2, len(val.GetSiblings()), "concurrent versions should be returned as siblings")
+	assert.Equal(t, 0, len(val.GetValue()), "a key with siblings should not have a single value")
+
+	// resolving the siblings with a context which has not seen them fails
+	err = n1.putContext(context.Background(), key, []byte("val3"), base, ConsistencyAll)
+	assert.Equal(t, errStaleContext.Error(), status.Convert(err).Message(), "a write with a stale context should fail")
+
+	// resolving the siblings with the context of the read replaces both
+	err = n1.putContext(context.Background(), key, []byte("val3"), clockFromProto(val.GetContext()), ConsistencyAll)
+	assert.Nil(t, err, "put(k,v) with the context of a read should not result in error")
+
+	val, err = n1.get(context.Background(), key, ConsistencyAll)
+	assert.Nil(t, err, "get(k) with consistency ALL should not result in error")
+	assert.Equal(t, 1, len(val.GetSiblings()), "a resolved key should have a single version")
+	assert.Equal(t, 0, bytes.Compare(val.GetValue(), []byte("val3")))
+}
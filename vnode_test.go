@@ -0,0 +1,71 @@
+package chord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualNodes(t *testing.T) {
+	// Two processes, each serving three positions on the ring
+	cfg := DefaultConfig("0.0.0.0", 8031)
+	cfg.VirtualNodes = 3
+	a, err := CreateChord(cfg)
+	assert.Nil(t, err, "CreateChord() should not result in error")
+	if err != nil {
+		return
+	}
+	defer a.shutdown()
+
+	cfg = DefaultConfig("0.0.0.0", 8032)
+	cfg.VirtualNodes = 3
+	b, err := JoinChord(cfg, "0.0.0.0", 8031)
+	assert.Nil(t, err, "JoinChord() should not result in error")
+	defer b.shutdown()
+
+	assert.Equal(t, 3, len(a.VirtualNodes()), "a should serve three virtual nodes")
+	assert.Equal(t, 0, bytes.Compare(a.Id, GetPeerID("0.0.0.0:8031", cfg.KeySize)), "the first virtual node should keep the ip:port peer ID")
+	assert.Equal(t, 0, bytes.Compare(a.VirtualNodes()[1].Id, GetPeerID("0.0.0.0:8031#1", cfg.KeySize)))
+
+	// Sleep so that nodes stabilize and converge
+	time.Sleep(20 * time.Second)
+
+	// following successor pointers should visit every virtual node once
+	vnodes := make(map[string]*Node)
+	for _, n := range append(a.VirtualNodes(), b.VirtualNodes()...) {
+		vnodes[idKey(n.Id)] = n
+	}
+	seen := make(map[string]bool)
+	n := a
+	for i := 0; i < len(vnodes); i++ {
+		seen[idKey(n.Id)] = true
+		next, ok := vnodes[idKey(n.successor.Id)]
+		assert.True(t, ok, "a successor should be one of the virtual nodes")
+		if !ok {
+			return
+		}
+		n = next
+	}
+	assert.Equal(t, 0, bytes.Compare(n.Id, a.Id), "the ring should close after visiting every virtual node")
+	assert.Equal(t, len(vnodes), len(seen), "every virtual node should be on the ring")
+
+	// RPCs reach the virtual node they name
+	for _, vn := range b.VirtualNodes() {
+		pred, err := a.GetPredecessorRPC(vn.Node)
+		assert.Nil(t, err, "GetPredecessorRPC() should not result in error")
+		assert.Equal(t, 0, bytes.Compare(pred.Id, vn.predecessor.Id), "an RPC should be served by the virtual node it names")
+	}
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("vnode-key%d", i)
+		err = a.put(context.Background(), key, []byte(key), ConsistencyOne)
+		assert.Nil(t, err, "put(k,v) should not result in error")
+		val, err := b.VirtualNodes()[2].get(context.Background(), key, ConsistencyOne)
+		assert.Nil(t, err, "get(k) should not result in error")
+		assert.Equal(t, 0, bytes.Compare(val.GetValue(), []byte(key)))
+	}
+}